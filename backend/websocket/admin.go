@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Admin API для операторов:
+//
+//	GET  /admin/connections            — живые соединения;
+//	POST /admin/connections/{id}/kick  — закрыть соединение {"code":4000,"reason":"..."};
+//	POST /admin/broadcast              — системное сообщение {"room":"lobby","data":...},
+//	                                     без room — всем;
+//	GET  /admin/upgrades               — принимаются ли новые подключения;
+//	POST /admin/upgrades               — {"reject":true} на время инцидента.
+
+// TypeSystem — системное сообщение оператора.
+const TypeSystem = "system"
+
+// DefaultAdminRole — роль, которую AdminConfig требует по умолчанию.
+const DefaultAdminRole = "admin"
+
+// AdminConfig — доступ к admin API. Без Auth API не подключается:
+// открытый наружу kick хуже, чем его отсутствие.
+type AdminConfig struct {
+	Auth Authenticator
+	// Role — требуемая роль; пусто — DefaultAdminRole.
+	Role string
+}
+
+func (cfg AdminConfig) role() string {
+	if cfg.Role == "" {
+		return DefaultAdminRole
+	}
+	return cfg.Role
+}
+
+// ConnectionInfo — соединение в ответе /admin/connections.
+type ConnectionInfo struct {
+	ID          string    `json:"id"`
+	Transport   string    `json:"transport"`
+	Protocol    string    `json:"protocol"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Subject     string    `json:"subject,omitempty"`
+	Rooms       []string  `json:"rooms"`
+	ConnectedAt time.Time `json:"connected_at"`
+	UptimeSec   float64   `json:"uptime_s"`
+	QueueDepth  int       `json:"queue_depth"`
+	Dropped     uint64    `json:"dropped"`
+}
+
+// KickRequest — тело kick. Code 0 означает 1008 (policy violation).
+type KickRequest struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// SystemMessage — тело broadcast и payload TypeSystem.
+type SystemMessage struct {
+	Room string `json:"room,omitempty" proto:"1"`
+	Data Data   `json:"data" proto:"2"`
+}
+
+// UpgradesState — тело и ответ /admin/upgrades.
+type UpgradesState struct {
+	Reject bool `json:"reject"`
+}
+
+// adminHandler подключает маршруты admin API к mux.
+func (s *Server) adminHandler(mux *http.ServeMux) {
+	mux.Handle("GET /admin/connections", s.requireAdmin(s.adminConnections))
+	mux.Handle("POST /admin/connections/{id}/kick", s.requireAdmin(s.adminKick))
+	mux.Handle("POST /admin/broadcast", s.requireAdmin(s.adminBroadcast))
+	mux.Handle("GET /admin/upgrades", s.requireAdmin(s.adminUpgrades))
+	mux.Handle("POST /admin/upgrades", s.requireAdmin(s.adminUpgrades))
+	s.hub.RegisterPayload(TypeSystem, SystemMessage{})
+}
+
+// requireAdmin пропускает только субъекта с ролью оператора.
+func (s *Server) requireAdmin(next func(w http.ResponseWriter, r *http.Request, p *Principal)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := authenticate(s.cfg.Admin.Auth, r)
+		if err != nil {
+			writeHandshakeError(w, err)
+			return
+		}
+		if !p.HasRole(s.cfg.Admin.role()) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r, p)
+	})
+}
+
+func (s *Server) adminConnections(w http.ResponseWriter, r *http.Request, _ *Principal) {
+	clients := s.hub.snapshot()
+	now := time.Now()
+
+	list := make([]ConnectionInfo, 0, len(clients))
+	for _, c := range clients {
+		info := ConnectionInfo{
+			ID:          c.id,
+			Transport:   c.transport,
+			Protocol:    c.Protocol(),
+			RemoteAddr:  c.remoteAddr,
+			Rooms:       s.hub.Rooms(c),
+			ConnectedAt: c.connectedAt,
+			UptimeSec:   now.Sub(c.connectedAt).Seconds(),
+			QueueDepth:  c.QueueLen(),
+			Dropped:     c.Dropped(),
+		}
+		if p := c.Principal(); p != nil {
+			info.Subject = p.Subject
+		}
+		sort.Strings(info.Rooms)
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ConnectedAt.Before(list[j].ConnectedAt) })
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) adminKick(w http.ResponseWriter, r *http.Request, admin *Principal) {
+	// тело необязательно: без него — 1008 без причины.
+	var in KickRequest
+	if r.ContentLength != 0 && !readJSON(w, r, &in) {
+		return
+	}
+	if in.Code == 0 {
+		in.Code = websocket.ClosePolicyViolation
+	}
+	if !sendableCloseCode(in.Code) {
+		http.Error(w, "close code must be 1000, 1001, 1008, 1011, 1013 or 3000-4999", http.StatusBadRequest)
+		return
+	}
+	// причина в close‑кадре ограничена 123 байтами.
+	if len(in.Reason) > 123 {
+		http.Error(w, "reason longer than 123 bytes", http.StatusBadRequest)
+		return
+	}
+
+	c := s.hub.client(r.PathValue("id"))
+	if c == nil {
+		http.Error(w, "no such connection", http.StatusNotFound)
+		return
+	}
+	log.Printf("admin %s: kicking client %s with %d %q", admin.Subject, c.id, in.Code, in.Reason)
+	if c.session != nil && s.sessions != nil {
+		s.sessions.end(c.session)
+	}
+	c.CloseWith(in.Code, in.Reason)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendableCloseCode — коды, которые сервер вправе отправить (RFC 6455, 7.4).
+func sendableCloseCode(code int) bool {
+	switch code {
+	case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.ClosePolicyViolation,
+		websocket.CloseInternalServerErr, websocket.CloseTryAgainLater:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+func (s *Server) adminBroadcast(w http.ResponseWriter, r *http.Request, admin *Principal) {
+	var in SystemMessage
+	if !readJSON(w, r, &in) {
+		return
+	}
+	data, err := marshalEnvelope(TypeSystem, "", in)
+	if err != nil {
+		http.Error(w, "bad data", http.StatusBadRequest)
+		return
+	}
+
+	delivered := 0
+	if in.Room != "" {
+		delivered = s.hub.Broadcast(in.Room, websocket.TextMessage, data)
+	} else {
+		// всем — только локальным клиентам: backplane маршрутизирует по комнатам.
+		for _, c := range s.hub.snapshot() {
+			if c.Send(websocket.TextMessage, data) {
+				delivered++
+			}
+		}
+	}
+	log.Printf("admin %s: system message to %q delivered to %d clients", admin.Subject, in.Room, delivered)
+	writeJSON(w, http.StatusOK, PublishResult{Delivered: delivered})
+}
+
+func (s *Server) adminUpgrades(w http.ResponseWriter, r *http.Request, admin *Principal) {
+	if r.Method == http.MethodPost {
+		var in UpgradesState
+		if !readJSON(w, r, &in) {
+			return
+		}
+		s.rejectUpgrades.Store(in.Reject)
+		log.Printf("admin %s: reject new upgrades = %v", admin.Subject, in.Reject)
+	}
+	writeJSON(w, http.StatusOK, UpgradesState{Reject: s.rejectUpgrades.Load()})
+}
+
+// client ищет клиента по ID. Линейный поиск: admin API вызывается редко.
+func (h *Hub) client(id string) *Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("admin encode error:", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// adminServer — сервер с admin API: токен root — оператор, user — нет.
+func adminServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Admin: AdminConfig{Auth: &BearerAuth{
+			Sources: DefaultTokenSources(),
+			Verifier: StaticTokens{
+				"root": {Subject: "ops", Roles: []string{DefaultAdminRole}},
+				"user": {Subject: "ann", Roles: []string{"user"}},
+			},
+		}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+// adminDo выполняет запрос к admin API и возвращает статус и тело.
+func adminDo(t *testing.T, method, url, token, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestAdminAuth(t *testing.T) {
+	_, ts := adminServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"no token", http.MethodGet, "/admin/connections", "", http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/admin/connections", "nope", http.StatusUnauthorized},
+		{"no admin role", http.MethodGet, "/admin/connections", "user", http.StatusForbidden},
+		{"no admin role, kick", http.MethodPost, "/admin/connections/1/kick", "user", http.StatusForbidden},
+		{"no admin role, broadcast", http.MethodPost, "/admin/broadcast", "user", http.StatusForbidden},
+		{"no admin role, upgrades", http.MethodPost, "/admin/upgrades", "user", http.StatusForbidden},
+		{"operator", http.MethodGet, "/admin/connections", "root", http.StatusOK},
+		{"token in query", http.MethodGet, "/admin/upgrades?token=root", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status, body := adminDo(t, tt.method, ts.URL+tt.path, tt.token, ""); status != tt.status {
+			t.Errorf("%s: %d %s, want %d", tt.name, status, body, tt.status)
+		}
+	}
+}
+
+func TestAdminDisabledWithoutAuth(t *testing.T) {
+	srv := NewServer(Config{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	// без Auth маршрутов нет вовсе.
+	if status, _ := adminDo(t, http.MethodGet, ts.URL+"/admin/connections", "", ""); status != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", status)
+	}
+}
+
+func TestAdminOperations(t *testing.T) {
+	srv, ts := adminServer(t)
+	conn := dial(t, wsURL(ts, "/ws?room=news"), nil)
+	waitFor(t, "client", func() bool { return srv.Hub().Len() == 1 })
+
+	status, body := adminDo(t, http.MethodGet, ts.URL+"/admin/connections", "root", "")
+	var list []ConnectionInfo
+	if err := json.Unmarshal([]byte(body), &list); status != http.StatusOK || err != nil || len(list) != 1 {
+		t.Fatalf("connections: %d %s", status, body)
+	}
+	if c := list[0]; c.Transport != "websocket" || len(c.Rooms) != 1 || c.Rooms[0] != "news" {
+		t.Errorf("connection = %+v", c)
+	}
+
+	status, body = adminDo(t, http.MethodPost, ts.URL+"/admin/broadcast", "root", `{"room":"news","data":{"text":"maintenance"}}`)
+	if status != http.StatusOK || strings.TrimSpace(body) != `{"delivered":1}` {
+		t.Errorf("broadcast: %d %s", status, body)
+	}
+	if got := readText(t, conn); got != `{"type":"system","payload":{"room":"news","data":{"text":"maintenance"}}}` {
+		t.Errorf("system message = %s", got)
+	}
+
+	// неверные запросы на kick.
+	kick := ts.URL + "/admin/connections/" + list[0].ID + "/kick"
+	for _, tt := range []struct {
+		url, body string
+		status    int
+	}{
+		{kick, `{"code":1006}`, http.StatusBadRequest},
+		{kick, `{"reason":"` + strings.Repeat("x", 124) + `"}`, http.StatusBadRequest},
+		{kick, `{"unknown":1}`, http.StatusBadRequest},
+		{ts.URL + "/admin/connections/999/kick", "", http.StatusNotFound},
+	} {
+		if status, body := adminDo(t, http.MethodPost, tt.url, "root", tt.body); status != tt.status {
+			t.Errorf("kick %s: %d %s, want %d", tt.body, status, body, tt.status)
+		}
+	}
+
+	if status, body := adminDo(t, http.MethodPost, kick, "root", `{"code":4000,"reason":"bye"}`); status != http.StatusNoContent {
+		t.Fatalf("kick: %d %s", status, body)
+	}
+	if code := closeCode(conn); code != 4000 {
+		t.Errorf("close code = %d, want 4000", code)
+	}
+
+	// на время инцидента новые подключения получают 503.
+	if status, body := adminDo(t, http.MethodPost, ts.URL+"/admin/upgrades", "root", `{"reject":true}`); status != http.StatusOK || strings.TrimSpace(body) != `{"reject":true}` {
+		t.Fatalf("upgrades: %d %s", status, body)
+	}
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(ts, "/ws"), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial while rejecting: %v, %v", resp, err)
+	}
+	adminDo(t, http.MethodPost, ts.URL+"/admin/upgrades", "root", `{"reject":false}`)
+	dial(t, wsURL(ts, "/ws"), nil)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrNoCredentials — в запросе нет токена ни в одном из источников.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal — аутентифицированный субъект, привязанный к соединению.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Claims  map[string]any
+}
+
+// HasRole сообщает, есть ли у субъекта роль.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// Authenticator вызывается до upgrader.Upgrade и определяет, кто подключается.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc позволяет использовать обычную функцию как Authenticator.
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// AnyOf пробует аутентификаторы по порядку. Исход решает первый, нашедший
+// в запросе учётные данные: ErrNoCredentials означает «не мой случай».
+func AnyOf(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (*Principal, error) {
+		for _, a := range auths {
+			p, err := a.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			return p, err
+		}
+		return nil, ErrNoCredentials
+	})
+}
+
+// TokenVerifier проверяет токен и возвращает его владельца.
+type TokenVerifier interface {
+	Verify(token string) (*Principal, error)
+}
+
+// StaticTokens — словарь "токен -> субъект" для API‑ключей и тестов.
+type StaticTokens map[string]*Principal
+
+func (t StaticTokens) Verify(token string) (*Principal, error) {
+	if p, ok := t[token]; ok {
+		return p, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+// TokenExtractor достаёт токен из запроса на апгрейд.
+type TokenExtractor func(r *http.Request) (string, bool)
+
+// FromHeader читает "Bearer <token>" из заголовка (обычно Authorization).
+// Подходит для нативных клиентов: браузерный WebSocket заголовки не задаёт.
+func FromHeader(name string) TokenExtractor {
+	return func(r *http.Request) (string, bool) {
+		scheme, token, ok := strings.Cut(r.Header.Get(name), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return "", false
+		}
+		return strings.TrimSpace(token), true
+	}
+}
+
+// FromQuery читает токен из query‑параметра (?token=...). Токен попадёт
+// в access‑логи, поэтому лучше использовать короткоживущие тикеты.
+func FromQuery(param string) TokenExtractor {
+	return func(r *http.Request) (string, bool) {
+		token := r.URL.Query().Get(param)
+		return token, token != ""
+	}
+}
+
+// FromSubprotocol ищет в Sec-WebSocket-Protocol элемент вида
+// "<prefix><token>", например "bearer.eyJhbGciOi...". Клиент должен
+// предложить рядом и настоящий подпротокол: сервер не выбирает элемент
+// с токеном, а браузер рвёт соединение, если ни один не выбран.
+func FromSubprotocol(prefix string) TokenExtractor {
+	return func(r *http.Request) (string, bool) {
+		for _, proto := range websocket.Subprotocols(r) {
+			if token, ok := strings.CutPrefix(proto, prefix); ok && token != "" {
+				return token, true
+			}
+		}
+		return "", false
+	}
+}
+
+// BearerAuth ищет токен в Sources по порядку и проверяет его Verifier.
+type BearerAuth struct {
+	Sources  []TokenExtractor
+	Verifier TokenVerifier
+}
+
+// DefaultTokenSources — заголовок Authorization, ?token= и подпротокол "bearer.".
+func DefaultTokenSources() []TokenExtractor {
+	return []TokenExtractor{
+		FromHeader("Authorization"),
+		FromQuery("token"),
+		FromSubprotocol("bearer."),
+	}
+}
+
+func (a *BearerAuth) Authenticate(r *http.Request) (*Principal, error) {
+	for _, source := range a.Sources {
+		if token, ok := source(r); ok {
+			return a.Verifier.Verify(token)
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// authenticate запускает Authenticator и переводит ошибку в 401.
+// Без Authenticator соединение анонимное (nil Principal). Подробности
+// (подпись, срок, разбор токена) клиенту не отдаются, только в лог.
+func authenticate(auth Authenticator, r *http.Request) (*Principal, error) {
+	if auth == nil {
+		return nil, nil
+	}
+
+	p, err := auth.Authenticate(r)
+	if err != nil {
+		var herr *HandshakeError
+		if errors.As(err, &herr) {
+			return nil, herr
+		}
+		log.Printf("authentication failed from %s: %v", r.RemoteAddr, err)
+		return nil, &HandshakeError{Status: http.StatusUnauthorized, Reason: "unauthorized"}
+	}
+	return p, nil
+}
+
+// JoinAuthorizer решает, может ли субъект войти в комнату.
+// p равен nil для анонимных соединений.
+type JoinAuthorizer func(p *Principal, room string) error
@@ -0,0 +1,118 @@
+package main
+
+import "sync"
+
+// BackplaneMessage — рассылка в комнату, пересылаемая между узлами.
+type BackplaneMessage struct {
+	Room string `json:"room"`
+	Type int    `json:"mt"`
+	Data []byte `json:"data"`
+}
+
+// Backplane связывает хабы нескольких узлов: рассылка в комнату на одном
+// узле доходит до участников комнаты, подключённых к другим.
+// Хаб подписывается на комнату, когда в ней появляется первый локальный
+// участник, и отписывается, когда уходит последний.
+type Backplane interface {
+	// Start задаёт обработчик входящих сообщений; вызывается хабом один раз.
+	Start(deliver func(BackplaneMessage)) error
+	// Publish отправляет сообщение всем остальным узлам, подписанным на комнату.
+	// Собственному узлу сообщение не возвращается.
+	Publish(msg BackplaneMessage) error
+	Subscribe(room string) error
+	Unsubscribe(room string) error
+	Close() error
+}
+
+// MemoryBus — общая шина для нескольких хабов в одном процессе.
+// Полезна для тестов и для шардирования по нескольким хабам.
+type MemoryBus struct {
+	mu    sync.RWMutex
+	nodes map[*MemoryBackplane]struct{}
+}
+
+// NewMemoryBus создаёт пустую шину.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{nodes: make(map[*MemoryBackplane]struct{})}
+}
+
+// Node создаёт Backplane одного узла на этой шине.
+func (b *MemoryBus) Node() *MemoryBackplane {
+	return &MemoryBackplane{bus: b, rooms: make(map[string]struct{})}
+}
+
+func (b *MemoryBus) publish(from *MemoryBackplane, msg BackplaneMessage) {
+	// обработчики вызываем без блокировки шины: они берут блокировку
+	// своего хаба, а тот под ней может звать Subscribe.
+	b.mu.RLock()
+	targets := make([]*MemoryBackplane, 0, len(b.nodes))
+	for n := range b.nodes {
+		if n != from {
+			targets = append(targets, n)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, n := range targets {
+		n.receive(msg)
+	}
+}
+
+// MemoryBackplane — узел MemoryBus.
+type MemoryBackplane struct {
+	bus *MemoryBus
+
+	mu      sync.RWMutex
+	deliver func(BackplaneMessage)
+	rooms   map[string]struct{}
+}
+
+func (m *MemoryBackplane) Start(deliver func(BackplaneMessage)) error {
+	m.mu.Lock()
+	m.deliver = deliver
+	m.mu.Unlock()
+
+	m.bus.mu.Lock()
+	m.bus.nodes[m] = struct{}{}
+	m.bus.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryBackplane) Publish(msg BackplaneMessage) error {
+	m.bus.publish(m, msg)
+	return nil
+}
+
+func (m *MemoryBackplane) Subscribe(room string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rooms[room] = struct{}{}
+	return nil
+}
+
+func (m *MemoryBackplane) Unsubscribe(room string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.rooms, room)
+	return nil
+}
+
+func (m *MemoryBackplane) Close() error {
+	m.bus.mu.Lock()
+	delete(m.bus.nodes, m)
+	m.bus.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryBackplane) receive(msg BackplaneMessage) {
+	m.mu.RLock()
+	_, subscribed := m.rooms[msg.Room]
+	deliver := m.deliver
+	m.mu.RUnlock()
+
+	if subscribed && deliver != nil {
+		deliver(msg)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// размер очереди кадров к брокеру и от брокера к узлу.
+const backplaneQueueSize = 1024
+
+// ErrBackplaneFull — очередь к брокеру переполнена, сообщение не отправлено.
+var ErrBackplaneFull = errors.New("backplane: queue full")
+
+// backplaneFrame — кадр протокола между узлом и брокером.
+// Кадры идут JSON‑объектами подряд по одному TCP‑соединению.
+type backplaneFrame struct {
+	Op   string `json:"op"` // sub, unsub, pub
+	Room string `json:"room"`
+	Type int    `json:"mt,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// BackplaneBroker — простой TCP‑брокер: узлы подписываются на комнаты,
+// и каждая публикация пересылается остальным подписанным узлам.
+// Это локальная замена Redis Pub/Sub для запуска нескольких процессов
+// на одной машине.
+type BackplaneBroker struct {
+	mu    sync.RWMutex
+	nodes map[*brokerNode]struct{}
+}
+
+type brokerNode struct {
+	conn  net.Conn
+	out   chan backplaneFrame
+	rooms map[string]struct{} // защищено BackplaneBroker.mu
+}
+
+// NewBackplaneBroker создаёт брокер без подключённых узлов.
+func NewBackplaneBroker() *BackplaneBroker {
+	return &BackplaneBroker{nodes: make(map[*brokerNode]struct{})}
+}
+
+// Serve принимает узлы на ln, пока листенер не закрыт.
+func (b *BackplaneBroker) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *BackplaneBroker) handle(conn net.Conn) {
+	n := &brokerNode{
+		conn:  conn,
+		out:   make(chan backplaneFrame, backplaneQueueSize),
+		rooms: make(map[string]struct{}),
+	}
+
+	b.mu.Lock()
+	b.nodes[n] = struct{}{}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	defer func() {
+		b.mu.Lock()
+		delete(b.nodes, n)
+		b.mu.Unlock()
+		close(done)
+		conn.Close()
+	}()
+
+	// writer: медленный узел не тормозит пересылку остальным.
+	go func() {
+		enc := json.NewEncoder(conn)
+		for {
+			select {
+			case f := <-n.out:
+				if err := enc.Encode(f); err != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	dec := json.NewDecoder(conn)
+	for {
+		var f backplaneFrame
+		if err := dec.Decode(&f); err != nil {
+			return
+		}
+
+		switch f.Op {
+		case "sub":
+			b.mu.Lock()
+			n.rooms[f.Room] = struct{}{}
+			b.mu.Unlock()
+		case "unsub":
+			b.mu.Lock()
+			delete(n.rooms, f.Room)
+			b.mu.Unlock()
+		case "pub":
+			b.forward(n, f)
+		default:
+			log.Printf("backplane broker: unknown op %q from %s", f.Op, conn.RemoteAddr())
+		}
+	}
+}
+
+func (b *BackplaneBroker) forward(from *brokerNode, f backplaneFrame) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for n := range b.nodes {
+		if n == from {
+			continue
+		}
+		if _, ok := n.rooms[f.Room]; !ok {
+			continue
+		}
+		select {
+		case n.out <- f:
+		default:
+			log.Printf("backplane broker: node %s is slow, dropping message for %q", n.conn.RemoteAddr(), f.Room)
+		}
+	}
+}
+
+// TCPBackplane — узел, подключённый к BackplaneBroker. При обрыве
+// переподключается и заново подписывается на свои комнаты.
+type TCPBackplane struct {
+	addr string
+	out  chan backplaneFrame
+
+	mu      sync.Mutex
+	rooms   map[string]struct{}
+	deliver func(BackplaneMessage)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTCPBackplane создаёт узел для брокера по адресу addr.
+// Соединение устанавливается в Start.
+func NewTCPBackplane(addr string) *TCPBackplane {
+	return &TCPBackplane{
+		addr:  addr,
+		out:   make(chan backplaneFrame, backplaneQueueSize),
+		rooms: make(map[string]struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func (t *TCPBackplane) Start(deliver func(BackplaneMessage)) error {
+	t.mu.Lock()
+	t.deliver = deliver
+	t.mu.Unlock()
+
+	go t.run()
+	return nil
+}
+
+func (t *TCPBackplane) Publish(msg BackplaneMessage) error {
+	return t.enqueue(backplaneFrame{Op: "pub", Room: msg.Room, Type: msg.Type, Data: msg.Data})
+}
+
+func (t *TCPBackplane) Subscribe(room string) error {
+	t.mu.Lock()
+	t.rooms[room] = struct{}{}
+	t.mu.Unlock()
+
+	return t.enqueue(backplaneFrame{Op: "sub", Room: room})
+}
+
+func (t *TCPBackplane) Unsubscribe(room string) error {
+	t.mu.Lock()
+	delete(t.rooms, room)
+	t.mu.Unlock()
+
+	return t.enqueue(backplaneFrame{Op: "unsub", Room: room})
+}
+
+func (t *TCPBackplane) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return nil
+}
+
+// enqueue не блокирует: вызывается в том числе под блокировкой хаба.
+func (t *TCPBackplane) enqueue(f backplaneFrame) error {
+	select {
+	case t.out <- f:
+		return nil
+	default:
+		return ErrBackplaneFull
+	}
+}
+
+func (t *TCPBackplane) run() {
+	const maxBackoff = 5 * time.Second
+	backoff := 100 * time.Millisecond
+
+	// reconnect — было ли уже подключение или неудачная попытка.
+	reconnect := false
+	for {
+		conn, err := net.Dial("tcp", t.addr)
+		if err != nil {
+			reconnect = true
+			log.Printf("backplane: dial %s: %v, retry in %s", t.addr, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-t.done:
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+
+		backoff = 100 * time.Millisecond
+		log.Printf("backplane: connected to %s", t.addr)
+		if reconnect {
+			t.dropStale()
+		}
+		t.serve(conn)
+		reconnect = true
+
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+	}
+}
+
+// dropStale выбрасывает кадры, накопленные без соединения: публикации
+// за время обрыва устарели, а подписки serve восстановит по t.rooms.
+func (t *TCPBackplane) dropStale() {
+	dropped := 0
+	for {
+		select {
+		case f := <-t.out:
+			if f.Op == "pub" {
+				dropped++
+			}
+		default:
+			if dropped > 0 {
+				log.Printf("backplane: dropped %d messages queued while disconnected", dropped)
+			}
+			return
+		}
+	}
+}
+
+// serve обслуживает одно соединение с брокером до ошибки или Close.
+func (t *TCPBackplane) serve(conn net.Conn) {
+	defer conn.Close()
+
+	readErr := make(chan error, 1)
+	go func() {
+		dec := json.NewDecoder(conn)
+		for {
+			var f backplaneFrame
+			if err := dec.Decode(&f); err != nil {
+				readErr <- err
+				return
+			}
+			if f.Op != "pub" {
+				continue
+			}
+
+			t.mu.Lock()
+			deliver := t.deliver
+			t.mu.Unlock()
+			if deliver != nil {
+				deliver(BackplaneMessage{Room: f.Room, Type: f.Type, Data: f.Data})
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(conn)
+
+	// после (пере)подключения брокер о нас ничего не знает.
+	t.mu.Lock()
+	rooms := make([]string, 0, len(t.rooms))
+	for room := range t.rooms {
+		rooms = append(rooms, room)
+	}
+	t.mu.Unlock()
+	for _, room := range rooms {
+		if err := enc.Encode(backplaneFrame{Op: "sub", Room: room}); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case f := <-t.out:
+			if err := enc.Encode(f); err != nil {
+				log.Printf("backplane: write: %v", err)
+				return
+			}
+		case err := <-readErr:
+			log.Printf("backplane: connection lost: %v", err)
+			return
+		case <-t.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+	a, b, c := NewHub(HubConfig{Backplane: bus.Node()}), NewHub(HubConfig{Backplane: bus.Node()}), NewHub(HubConfig{Backplane: bus.Node()})
+	ca, cb, cc := a.registerStream(nil, "test", "a"), b.registerStream(nil, "test", "b"), c.registerStream(nil, "test", "c")
+	a.Join(ca, "news")
+	b.Join(cb, "news")
+	c.Join(cc, "sport")
+
+	if n := a.Broadcast("news", websocket.TextMessage, []byte("hi")); n != 1 {
+		t.Errorf("local delivery = %d, want 1", n)
+	}
+	// свой узел сообщение не получает повторно, чужая комната — не получает вовсе.
+	if got := queued(ca); !slices.Equal(got, []string{"hi"}) {
+		t.Errorf("a got %q", got)
+	}
+	if got := queued(cb); !slices.Equal(got, []string{"hi"}) {
+		t.Errorf("b got %q", got)
+	}
+	if got := queued(cc); got != nil {
+		t.Errorf("c got %q", got)
+	}
+
+	// последний участник ушёл — узел отписался.
+	b.Leave(cb, "news")
+	a.Broadcast("news", websocket.TextMessage, []byte("bye"))
+	if got := queued(cb); got != nil {
+		t.Errorf("b got %q after leaving", got)
+	}
+}
+
+// subscribers считает узлы брокера, подписанные на room.
+func subscribers(b *BackplaneBroker, room string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	n := 0
+	for node := range b.nodes {
+		if _, ok := node.rooms[room]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// startBroker запускает брокер на случайном порту.
+func startBroker(t *testing.T) (*BackplaneBroker, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	broker := NewBackplaneBroker()
+	go broker.Serve(ln)
+	return broker, ln.Addr().String()
+}
+
+// tcpHub создаёт хаб, подключённый к брокеру по addr.
+func tcpHub(t *testing.T, addr string) *Hub {
+	t.Helper()
+
+	bp := NewTCPBackplane(addr)
+	t.Cleanup(func() { bp.Close() })
+	return NewHub(HubConfig{Backplane: bp})
+}
+
+// next ждёт следующее сообщение в очереди клиента.
+func next(t *testing.T, c *Client) string {
+	t.Helper()
+
+	select {
+	case msg := <-c.send:
+		return string(msg.data)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return ""
+	}
+}
+
+func TestTCPBackplane(t *testing.T) {
+	broker, addr := startBroker(t)
+	a, b := tcpHub(t, addr), tcpHub(t, addr)
+	ca, cb := a.registerStream(nil, "test", "a"), b.registerStream(nil, "test", "b")
+	a.Join(ca, "news")
+	b.Join(cb, "news")
+	waitFor(t, "both nodes subscribed", func() bool { return subscribers(broker, "news") == 2 })
+
+	// сообщение получают и свой клиент узла, и клиент другого узла.
+	a.Broadcast("news", websocket.TextMessage, []byte("from a"))
+	if got, remote := next(t, ca), next(t, cb); got != "from a" || remote != "from a" {
+		t.Errorf("a got %q, b got %q", got, remote)
+	}
+	b.Broadcast("news", websocket.BinaryMessage, []byte{0, 1, 2})
+	if got, remote := next(t, cb), next(t, ca); got != "\x00\x01\x02" || remote != got {
+		t.Errorf("b got %q, a got %q", got, remote)
+	}
+
+	b.Leave(cb, "news")
+	waitFor(t, "b unsubscribed", func() bool { return subscribers(broker, "news") == 1 })
+	a.Broadcast("news", websocket.TextMessage, []byte("after leave"))
+	time.Sleep(50 * time.Millisecond)
+	if got := queued(cb); got != nil {
+		t.Errorf("b got %q after leaving", got)
+	}
+}
+
+// proxy пересылает соединения с ln на addr; Close рвёт их все.
+type proxy struct {
+	ln    net.Listener
+	conns chan net.Conn
+}
+
+func startProxy(t *testing.T, ln net.Listener, addr string) *proxy {
+	p := &proxy{ln: ln, conns: make(chan net.Conn, 16)}
+	go func() {
+		for {
+			in, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", addr)
+			if err != nil {
+				in.Close()
+				continue
+			}
+			p.conns <- in
+			p.conns <- out
+			go io.Copy(in, out)
+			go io.Copy(out, in)
+		}
+	}()
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *proxy) Close() {
+	p.ln.Close()
+	for {
+		select {
+		case c := <-p.conns:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+func TestTCPBackplaneDropsStale(t *testing.T) {
+	broker, addr := startBroker(t)
+	b := tcpHub(t, addr)
+	cb := b.registerStream(nil, "test", "b")
+	b.Join(cb, "news")
+	waitFor(t, "b subscribed", func() bool { return subscribers(broker, "news") == 1 })
+
+	// узел a ходит к брокеру через прокси, которую можно оборвать.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := startProxy(t, ln, addr)
+	a := tcpHub(t, ln.Addr().String())
+	ca := a.registerStream(nil, "test", "a")
+	a.Join(ca, "news")
+	waitFor(t, "a subscribed", func() bool { return subscribers(broker, "news") == 2 })
+
+	p.Close()
+	waitFor(t, "a disconnected", func() bool { return subscribers(broker, "news") == 1 })
+	// эти публикации копятся в очереди a, пока брокер недоступен.
+	for range 3 {
+		a.Broadcast("news", websocket.TextMessage, []byte("stale"))
+	}
+
+	ln, err = net.Listen("tcp", ln.Addr().String())
+	if err != nil {
+		t.Skipf("cannot reuse the proxy port: %v", err)
+	}
+	startProxy(t, ln, addr)
+	waitFor(t, "a resubscribed", func() bool { return subscribers(broker, "news") == 2 })
+
+	a.Broadcast("news", websocket.TextMessage, []byte("fresh"))
+	if got := next(t, cb); got != "fresh" {
+		t.Errorf("b got %q first, want fresh (stale publishes must be dropped)", got)
+	}
+}
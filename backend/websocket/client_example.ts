@@ -1,19 +1,96 @@
 const WS_URL = "ws://localhost:8080/ws";
+const REQUEST_TIMEOUT_MS = 5000;
+const RECONNECT_BASE_MS = 500;
+const RECONNECT_MAX_MS = 30000;
+
+// Конверт сообщения — тот же, что Envelope в protocol.go.
+interface Envelope<T = unknown> {
+    seq?: number;
+    type: string;
+    id?: string;
+    payload?: T;
+    reply_to?: string;
+}
+
+// Первое сообщение сервера — SessionInfo из session.go.
+interface SessionInfo {
+    id: string;
+    resumed: boolean;
+    replayed: number;
+    seq: number;
+}
+
+interface ProtocolError {
+    code: string;
+    message: string;
+}
+
+interface Pending {
+    resolve: (payload: unknown) => void;
+    reject: (err: ProtocolError) => void;
+    timer: ReturnType<typeof setTimeout>;
+}
+
+type MessageHandler = (payload: unknown) => void;
 
 class SimpleWsClient {
     private socket: WebSocket | null = null;
+    private nextId = 1;
+    private pending = new Map<string, Pending>();
+    private handlers = new Map<string, MessageHandler>();
+
+    // состояние для resume после обрыва
+    private sessionId: string | null = null;
+    private lastSeq = 0;
+    private reconnectAttempt = 0;
+    private closedByUser = false;
 
     connect() {
-        this.socket = new WebSocket(WS_URL);
+        this.closedByUser = false;
+
+        // при переподключении просим сервер докачать всё после lastSeq
+        let url = WS_URL;
+        if (this.sessionId) {
+            url += `?session=${encodeURIComponent(this.sessionId)}&last_seq=${this.lastSeq}`;
+        }
+        this.socket = new WebSocket(url);
 
         this.socket.onopen = () => {
             console.log("WS connected");
-            // Отправляем тестовое сообщение
-            this.send("hello from TS client");
+            this.reconnectAttempt = 0;
         };
 
         this.socket.onmessage = (event: MessageEvent) => {
-            console.log("message from server:", event.data);
+            const env = JSON.parse(event.data) as Envelope;
+
+            if (env.seq) {
+                this.lastSeq = env.seq;
+            }
+
+            if (env.type === "session") {
+                this.onSession(env.payload as SessionInfo);
+                return;
+            }
+
+            // ответ на наш запрос — ищем его по reply_to
+            if (env.reply_to && this.pending.has(env.reply_to)) {
+                const p = this.pending.get(env.reply_to)!;
+                this.pending.delete(env.reply_to);
+                clearTimeout(p.timer);
+                if (env.type === "error") {
+                    p.reject(env.payload as ProtocolError);
+                } else {
+                    p.resolve(env.payload);
+                }
+                return;
+            }
+
+            const handler = this.handlers.get(env.type);
+            if (handler) {
+                handler(env.payload);
+            } else {
+                console.log("message from server:", env);
+            }
         };
 
         this.socket.onerror = (event: Event) => {
@@ -22,24 +99,92 @@ class SimpleWsClient {
 
         this.socket.onclose = (event: CloseEvent) => {
             console.log("WS closed:", event.code, event.reason);
+            this.failPending({ code: "closed", message: "connection closed" });
+            if (!this.closedByUser) {
+                this.scheduleReconnect();
+            }
         };
     }
 
-    send(message: string) {
-        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
-            this.socket.send(message);
-        } else {
-            console.warn("WS not open, cannot send");
-        }
+    // on подписывает обработчик на серверные сообщения определённого типа.
+    on(type: string, handler: MessageHandler) {
+        this.handlers.set(type, handler);
+    }
+
+    // send — fire-and-forget без ожидания ответа.
+    send(type: string, payload?: unknown) {
+        this.write({ type, payload });
+    }
+
+    // request отправляет сообщение с id и ждёт ack/error с тем же reply_to.
+    request<T = unknown>(type: string, payload?: unknown): Promise<T> {
+        const id = String(this.nextId++);
+        return new Promise<T>((resolve, reject) => {
+            const timer = setTimeout(() => {
+                this.pending.delete(id);
+                reject({ code: "timeout", message: `no reply to ${type}` });
+            }, REQUEST_TIMEOUT_MS);
+
+            this.pending.set(id, { resolve: resolve as (p: unknown) => void, reject, timer });
+            if (!this.write({ type, id, payload })) {
+                clearTimeout(timer);
+                this.pending.delete(id);
+                reject({ code: "not_open", message: "WS not open" });
+            }
+        });
     }
 
     disconnect() {
+        this.closedByUser = true;
         if (this.socket) {
             this.socket.close(1000, "client disconnect");
             this.socket = null;
         }
     }
+
+    private async onSession(info: SessionInfo) {
+        if (info.resumed) {
+            console.log(`session ${info.id} resumed, replayed ${info.replayed} messages`);
+            return;
+        }
+
+        // новая сессия: старую докачать не удалось, начинаем с нуля
+        this.sessionId = info.id;
+        this.lastSeq = 0;
+
+        // Входим в комнату и отправляем тестовое сообщение
+        await this.request("join", { room: "lobby" });
+        const res = await this.request("publish", { room: "lobby", data: "hello from TS client" });
+        console.log("publish ack:", res);
+    }
+
+    // экспоненциальная задержка с джиттером, чтобы клиенты не ломились разом
+    private scheduleReconnect() {
+        const base = Math.min(RECONNECT_MAX_MS, RECONNECT_BASE_MS * 2 ** this.reconnectAttempt);
+        const delay = base / 2 + Math.random() * (base / 2);
+        this.reconnectAttempt++;
+        console.log(`WS reconnect in ${Math.round(delay)} ms`);
+        setTimeout(() => this.connect(), delay);
+    }
+
+    private write(env: Envelope): boolean {
+        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+            this.socket.send(JSON.stringify(env));
+            return true;
+        }
+        console.warn("WS not open, cannot send");
+        return false;
+    }
+
+    private failPending(err: ProtocolError) {
+        for (const p of this.pending.values()) {
+            clearTimeout(p.timer);
+            p.reject(err);
+        }
+        this.pending.clear();
+    }
 }
 
 const client = new SimpleWsClient();
-client.connect();
\ No newline at end of file
+client.on("message", (payload) => console.log("room message:", payload));
+client.connect();
@@ -0,0 +1,296 @@
+// wsbench — нагрузочный тест WebSocket‑сервера через эхо: открывает N
+// соединений с плавным разгоном, шлёт сообщения с заданной общей
+// частотой и меряет время до возврата эха.
+//
+//	wsbench -url ws://localhost:8080/echo -conns 500 -ramp 10s -rate 5000 -duration 30s
+//
+// Все соединения идут с одного IP, поэтому сервер для прогона запускают
+// без лимитов: -ip-msg-rate 0 -ip-byte-rate 0 -msg-rate 0 -max-conns-per-ip 0.
+// Иначе в результатах будут rate_limited и отказы 429.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsclient"
+)
+
+// typeBench — тип конверта; эхо‑обработчик возвращает его как есть.
+const typeBench = "bench"
+
+// типы ответов сервера, которые считаются ошибками.
+const typeRateLimited = "rate_limited"
+
+type benchPayload struct {
+	Seq  uint64 `json:"seq"`
+	Sent int64  `json:"sent"` // наносекунды от начала прогона
+	Pad  string `json:"pad,omitempty"`
+}
+
+// Latency — перцентили задержки в миллисекундах.
+type Latency struct {
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+	Mean float64 `json:"mean_ms"`
+}
+
+// Result — итог прогона; с -json печатается как есть, чтобы сравнивать
+// прогоны между коммитами.
+type Result struct {
+	URL        string         `json:"url"`
+	Conns      int            `json:"conns"`
+	Connected  int            `json:"connected"`
+	Rate       float64        `json:"target_rate"`
+	Seconds    float64        `json:"duration_s"`
+	Sent       int64          `json:"sent"`
+	Received   int64          `json:"received"`
+	Lost       int64          `json:"lost"`
+	Throughput float64        `json:"throughput"`
+	Latency    Latency        `json:"latency"`
+	Errors     map[string]int `json:"errors"`
+	CloseCodes map[string]int `json:"close_codes"`
+}
+
+// stats — общие счётчики всех соединений.
+type stats struct {
+	mu         sync.Mutex
+	latencies  []time.Duration
+	sent       int64
+	connected  int
+	errors     map[string]int
+	closeCodes map[string]int
+}
+
+func (s *stats) error(kind string) {
+	s.mu.Lock()
+	s.errors[kind]++
+	s.mu.Unlock()
+}
+
+func (s *stats) disconnect(err error) {
+	code := "none"
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		code = strconv.Itoa(closeErr.Code)
+	}
+	s.mu.Lock()
+	s.errors["disconnect"]++
+	s.closeCodes[code]++
+	s.mu.Unlock()
+}
+
+type bench struct {
+	url      string
+	conns    int
+	ramp     time.Duration
+	rate     float64
+	duration time.Duration
+	grace    time.Duration
+	pad      string
+
+	start time.Time
+	stats stats
+}
+
+// worker — одно соединение: подключается в свою очередь разгона, шлёт
+// сообщения до конца прогона и ждёт опоздавшие эха.
+func (b *bench) worker(i int, end time.Time) {
+	time.Sleep(time.Until(b.start.Add(b.ramp * time.Duration(i) / time.Duration(b.conns))))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	c, err := wsclient.Dial(ctx, wsclient.Config{
+		URL:          b.url,
+		OnDisconnect: b.stats.disconnect,
+		Logf:         func(string, ...any) {},
+	})
+	cancel()
+	if err != nil {
+		b.stats.error("dial")
+		return
+	}
+
+	b.stats.mu.Lock()
+	b.stats.connected++
+	b.stats.mu.Unlock()
+
+	wsclient.On(c, typeBench, func(p benchPayload) {
+		rtt := time.Since(b.start) - time.Duration(p.Sent)
+		b.stats.mu.Lock()
+		b.stats.latencies = append(b.stats.latencies, rtt)
+		b.stats.mu.Unlock()
+	})
+	c.Handle(typeRateLimited, func(wsclient.Envelope) { b.stats.error(typeRateLimited) })
+
+	// частота на соединение; разгон и так разносит тикеры во времени.
+	interval := max(time.Duration(float64(time.Second)*float64(b.conns)/b.rate), time.Microsecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var seq uint64
+	for now := time.Now(); now.Before(end); now = <-ticker.C {
+		seq++
+		ctx, cancel := context.WithDeadline(context.Background(), end)
+		err := c.Send(ctx, typeBench, benchPayload{Seq: seq, Sent: int64(time.Since(b.start)), Pad: b.pad})
+		cancel()
+		if err != nil {
+			b.stats.error("send")
+			continue
+		}
+		b.stats.mu.Lock()
+		b.stats.sent++
+		b.stats.mu.Unlock()
+	}
+
+	time.Sleep(b.grace)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		b.stats.error("close")
+	}
+}
+
+func (b *bench) run() Result {
+	b.stats.errors = make(map[string]int)
+	b.stats.closeCodes = make(map[string]int)
+	b.start = time.Now()
+	end := b.start.Add(b.ramp + b.duration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.conns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b.worker(i, end)
+		}(i)
+	}
+	wg.Wait()
+
+	st := &b.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	elapsed := b.ramp + b.duration
+	received := int64(len(st.latencies))
+	return Result{
+		URL:        b.url,
+		Conns:      b.conns,
+		Connected:  st.connected,
+		Rate:       b.rate,
+		Seconds:    elapsed.Seconds(),
+		Sent:       st.sent,
+		Received:   received,
+		Lost:       max(st.sent-received, 0),
+		Throughput: float64(received) / elapsed.Seconds(),
+		Latency:    percentiles(st.latencies),
+		Errors:     st.errors,
+		CloseCodes: st.closeCodes,
+	}
+}
+
+// percentiles считает перцентили методом ближайшего ранга.
+func percentiles(d []time.Duration) Latency {
+	if len(d) == 0 {
+		return Latency{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+
+	ms := func(v time.Duration) float64 { return float64(v) / float64(time.Millisecond) }
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(d)))) - 1
+		return ms(d[max(i, 0)])
+	}
+
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return Latency{
+		P50:  rank(50),
+		P95:  rank(95),
+		P99:  rank(99),
+		Max:  ms(d[len(d)-1]),
+		Mean: ms(sum / time.Duration(len(d))),
+	}
+}
+
+func printText(w io.Writer, r Result) {
+	fmt.Fprintf(w, "target      %s\n", r.URL)
+	fmt.Fprintf(w, "conns       %d/%d connected\n", r.Connected, r.Conns)
+	fmt.Fprintf(w, "messages    sent %d, received %d, lost %d\n", r.Sent, r.Received, r.Lost)
+	fmt.Fprintf(w, "throughput  %.1f msg/s (target %.1f)\n", r.Throughput, r.Rate)
+	fmt.Fprintf(w, "latency     p50 %.2fms  p95 %.2fms  p99 %.2fms  max %.2fms  mean %.2fms\n",
+		r.Latency.P50, r.Latency.P95, r.Latency.P99, r.Latency.Max, r.Latency.Mean)
+	fmt.Fprintf(w, "errors      %s\n", formatCounts(r.Errors))
+	fmt.Fprintf(w, "close codes %s\n", formatCounts(r.CloseCodes))
+}
+
+func formatCounts(m map[string]int) string {
+	if len(m) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %d", k, m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/echo", "echo endpoint to benchmark")
+	conns := flag.Int("conns", 100, "concurrent connections")
+	ramp := flag.Duration("ramp", 5*time.Second, "time over which connections are opened")
+	rate := flag.Float64("rate", 1000, "target total messages per second across all connections")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send after ramp-up")
+	size := flag.Int("size", 64, "approximate message size in bytes")
+	grace := flag.Duration("grace", 2*time.Second, "how long to wait for late echoes before closing")
+	asJSON := flag.Bool("json", false, "print the result as JSON")
+	flag.Parse()
+
+	if *conns <= 0 || *rate <= 0 {
+		log.Fatal("conns and rate must be positive")
+	}
+
+	b := &bench{
+		url:      *url,
+		conns:    *conns,
+		ramp:     *ramp,
+		rate:     *rate,
+		duration: *duration,
+		grace:    *grace,
+		// конверт без pad занимает около 60 байт.
+		pad: strings.Repeat("x", max(*size-60, 0)),
+	}
+	r := b.run()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printText(os.Stdout, r)
+}
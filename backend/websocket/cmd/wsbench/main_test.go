@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPercentiles(t *testing.T) {
+	if got := percentiles(nil); got != (Latency{}) {
+		t.Errorf("empty: %+v", got)
+	}
+
+	// 1..100 мс в обратном порядке: percentiles сам сортирует.
+	d := make([]time.Duration, 100)
+	for i := range d {
+		d[i] = time.Duration(100-i) * time.Millisecond
+	}
+	want := Latency{P50: 50, P95: 95, P99: 99, Max: 100, Mean: 50.5}
+	if got := percentiles(d); got != want {
+		t.Errorf("percentiles = %+v, want %+v", got, want)
+	}
+	if got := percentiles([]time.Duration{3 * time.Millisecond}); got.P50 != 3 || got.P99 != 3 {
+		t.Errorf("single sample: %+v", got)
+	}
+}
+
+func TestFormatCounts(t *testing.T) {
+	if got := formatCounts(nil); got != "none" {
+		t.Errorf("empty = %q", got)
+	}
+	if got := formatCounts(map[string]int{"send": 2, "dial": 1}); got != "dial: 1, send: 2" {
+		t.Errorf("counts = %q", got)
+	}
+}
+
+func TestDisconnectCodes(t *testing.T) {
+	var st stats
+	st.errors, st.closeCodes = map[string]int{}, map[string]int{}
+	st.disconnect(&websocket.CloseError{Code: websocket.ClosePolicyViolation})
+	st.disconnect(errors.New("reset"))
+	if st.errors["disconnect"] != 2 || st.closeCodes["1008"] != 1 || st.closeCodes["none"] != 1 {
+		t.Errorf("errors %v, close codes %v", st.errors, st.closeCodes)
+	}
+}
+
+// echoServer возвращает каждый кадр как есть, как /echo сервера.
+func echoServer(t *testing.T) *httptest.Server {
+	var upgrader websocket.Upgrader
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRun(t *testing.T) {
+	ts := echoServer(t)
+	b := &bench{
+		url:      "ws" + strings.TrimPrefix(ts.URL, "http"),
+		conns:    3,
+		ramp:     30 * time.Millisecond,
+		rate:     100,
+		duration: 200 * time.Millisecond,
+		grace:    100 * time.Millisecond,
+		pad:      "xx",
+	}
+	r := b.run()
+
+	if r.Connected != 3 || r.Sent == 0 || r.Received != r.Sent || r.Lost != 0 {
+		t.Errorf("result = %+v", r)
+	}
+	if len(r.Errors) != 0 {
+		t.Errorf("errors = %v", r.Errors)
+	}
+	if r.Latency.Max <= 0 || r.Latency.P50 > r.Latency.Max {
+		t.Errorf("latency = %+v", r.Latency)
+	}
+
+	var out bytes.Buffer
+	printText(&out, r)
+	if !strings.Contains(out.String(), "conns       3/3 connected") {
+		t.Errorf("report:\n%s", out.String())
+	}
+}
+
+func TestRunUnreachable(t *testing.T) {
+	b := &bench{url: "ws://127.0.0.1:1/echo", conns: 2, rate: 10, duration: 10 * time.Millisecond}
+	r := b.run()
+	if r.Connected != 0 || r.Errors["dial"] != 2 {
+		t.Errorf("result = %+v", r)
+	}
+}
@@ -0,0 +1,270 @@
+// wsreplay воспроизводит запись трафика (сервер с флагом -record) против
+// живого сервера: открывает те же соединения с теми же ?room= и шлёт
+// входящие кадры в исходном порядке с исходными интервалами, ускоренными
+// в -speed раз. Ответы сервера считаются и сравниваются с записью.
+//
+//	wsreplay -file traffic.wsrec -url ws://localhost:8080/ws -speed 10
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
+)
+
+// replayConn — воспроизводимое соединение.
+type replayConn struct {
+	id   string
+	conn *websocket.Conn
+
+	sent     int
+	recorded atomic.Int64 // исходящих кадров сервера в записи
+	received atomic.Int64 // кадров, полученных при воспроизведении
+	done     chan struct{}
+}
+
+// readLoop считает ответы сервера, пока соединение не закроется.
+func (rc *replayConn) readLoop(verbose bool) {
+	defer close(rc.done)
+	for {
+		_, msg, err := rc.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		rc.received.Add(1)
+		if verbose {
+			log.Printf("conn %s <- %s", rc.id, msg)
+		}
+	}
+}
+
+type replayer struct {
+	target  *url.URL
+	header  map[string][]string
+	speed   float64
+	only    string
+	verbose bool
+
+	conns map[string]*replayConn
+	order []string
+	skip  map[string]bool // соединения, которые не удалось открыть
+}
+
+// open подключается к серверу с query исходного запроса: путь берётся из
+// -url, так что записи /sse и /poll воспроизводятся через WebSocket.
+func (r *replayer) open(id, uri string) (*replayConn, error) {
+	u := *r.target
+	if uri != "" {
+		orig, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		for k, vs := range orig.Query() {
+			// сессии из записи на новом сервере не существуют.
+			if k == "session" || k == "last_seq" {
+				continue
+			}
+			q[k] = vs
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), r.header)
+	if err != nil {
+		return nil, err
+	}
+	rc := &replayConn{id: id, conn: conn, done: make(chan struct{})}
+	r.conns[id] = rc
+	r.order = append(r.order, id)
+	go rc.readLoop(r.verbose)
+	return rc, nil
+}
+
+// conn возвращает соединение для кадра, открывая его лениво, если
+// запись началась посреди соединения и OpOpen для него нет.
+func (r *replayer) conn(id string) *replayConn {
+	if rc, ok := r.conns[id]; ok {
+		return rc
+	}
+	if r.skip[id] {
+		return nil
+	}
+	rc, err := r.open(id, "")
+	if err != nil {
+		log.Printf("conn %s: dial: %v", id, err)
+		r.skip[id] = true
+		return nil
+	}
+	return rc
+}
+
+func (r *replayer) play(rd *wsrecord.Reader) error {
+	var start, first time.Time
+
+	for {
+		f, err := rd.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Println("recording ends with a truncated frame, stopping there")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if r.only != "" && f.Conn != r.only {
+			continue
+		}
+
+		// выдерживаем исходные интервалы между кадрами.
+		if first.IsZero() {
+			start, first = time.Now(), f.Time
+		} else if r.speed > 0 {
+			due := start.Add(time.Duration(float64(f.Time.Sub(first)) / r.speed))
+			time.Sleep(time.Until(due))
+		}
+
+		if f.Dir == wsrecord.Out {
+			if rc := r.conns[f.Conn]; rc != nil && f.Opcode != wsrecord.OpClose {
+				rc.recorded.Add(1)
+			}
+			continue
+		}
+
+		switch f.Opcode {
+		case wsrecord.OpOpen:
+			if _, ok := r.conns[f.Conn]; ok {
+				continue
+			}
+			if _, err := r.open(f.Conn, string(f.Payload)); err != nil {
+				log.Printf("conn %s: dial: %v", f.Conn, err)
+				r.skip[f.Conn] = true
+			}
+		case wsrecord.OpClose:
+			if rc := r.conn(f.Conn); rc != nil {
+				rc.conn.WriteControl(websocket.CloseMessage, f.Payload, time.Now().Add(time.Second))
+			}
+		case wsrecord.OpText, wsrecord.OpBinary:
+			rc := r.conn(f.Conn)
+			if rc == nil {
+				continue
+			}
+			if err := rc.conn.WriteMessage(int(f.Opcode), f.Payload); err != nil {
+				log.Printf("conn %s: write: %v", f.Conn, err)
+				continue
+			}
+			rc.sent++
+			if r.verbose {
+				log.Printf("conn %s -> %s", f.Conn, f.Payload)
+			}
+		}
+	}
+}
+
+// finish ждёт оставшиеся ответы и закрывает соединения.
+func (r *replayer) finish(linger time.Duration) {
+	time.Sleep(linger)
+
+	var wg sync.WaitGroup
+	for _, rc := range r.conns {
+		wg.Add(1)
+		go func(rc *replayConn) {
+			defer wg.Done()
+			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay finished")
+			rc.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			select {
+			case <-rc.done:
+			case <-time.After(time.Second):
+			}
+			rc.conn.Close()
+		}(rc)
+	}
+	wg.Wait()
+}
+
+// report печатает по соединению: отправлено, получено и записано.
+// Расхождение received и recorded — первый признак регрессии.
+func (r *replayer) report(w io.Writer) (mismatches int) {
+	ids := append([]string(nil), r.order...)
+	sort.SliceStable(ids, func(i, j int) bool { return len(ids[i]) < len(ids[j]) || len(ids[i]) == len(ids[j]) && ids[i] < ids[j] })
+
+	fmt.Fprintf(w, "%-8s %8s %8s %8s\n", "conn", "sent", "received", "recorded")
+	for _, id := range ids {
+		rc := r.conns[id]
+		got, want := rc.received.Load(), rc.recorded.Load()
+		mark := ""
+		if got != want {
+			mark = " !"
+			mismatches++
+		}
+		fmt.Fprintf(w, "%-8s %8d %8d %8d%s\n", id, rc.sent, got, want, mark)
+	}
+	return mismatches
+}
+
+func main() {
+	file := flag.String("file", "", "recording to replay")
+	target := flag.String("url", "ws://localhost:8080/ws", "server to replay against; query from the recording is merged in")
+	speed := flag.Float64("speed", 1, "playback speed multiplier, 0 sends as fast as possible")
+	only := flag.String("conn", "", "replay only this connection, as recorded: epoch/ID")
+	token := flag.String("token", "", "bearer token sent with every connection")
+	linger := flag.Duration("linger", time.Second, "how long to wait for replies after the last frame")
+	verbose := flag.Bool("v", false, "log every frame")
+	strict := flag.Bool("strict", false, "exit with status 1 if reply counts differ from the recording")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	u, err := url.Parse(*target)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	rd, err := wsrecord.NewReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := &replayer{
+		target:  u,
+		header:  map[string][]string{},
+		speed:   *speed,
+		only:    *only,
+		verbose: *verbose,
+		conns:   make(map[string]*replayConn),
+		skip:    make(map[string]bool),
+	}
+	if *token != "" {
+		r.header["Authorization"] = []string{"Bearer " + *token}
+	}
+
+	if err := r.play(rd); err != nil {
+		log.Println("replay:", err)
+	}
+	r.finish(*linger)
+
+	if r.report(os.Stdout) > 0 && *strict {
+		os.Exit(1)
+	}
+}
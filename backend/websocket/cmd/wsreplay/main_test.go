@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
+)
+
+// echoServer возвращает каждый кадр как есть и запоминает query
+// подключений.
+func echoServer(t *testing.T) (*httptest.Server, func() []string) {
+	var (
+		upgrader websocket.Upgrader
+		mu       sync.Mutex
+		queries  []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, msg)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+// record пишет кадры в файл через wsrecord, как сервер с -record.
+func record(t *testing.T, frames []wsrecord.Frame) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "traffic.wsrec")
+	w, err := wsrecord.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i, f := range frames {
+		f.Time = start.Add(time.Duration(i) * time.Millisecond)
+		if err := w.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	ts, queries := echoServer(t)
+	in := func(conn string, op byte, payload string) wsrecord.Frame {
+		return wsrecord.Frame{Dir: wsrecord.In, Opcode: op, Conn: conn, Payload: []byte(payload)}
+	}
+	out := func(conn, payload string) wsrecord.Frame {
+		return wsrecord.Frame{Dir: wsrecord.Out, Opcode: wsrecord.OpText, Conn: conn, Payload: []byte(payload)}
+	}
+	path := record(t, []wsrecord.Frame{
+		in("e/1", wsrecord.OpOpen, "/ws?room=news&session=old&last_seq=3"),
+		in("e/1", wsrecord.OpText, "one"),
+		out("e/1", "one"),
+		// e/2 записан с середины: OpOpen нет, соединение открывается лениво.
+		in("e/2", wsrecord.OpText, "two"),
+		in("e/1", wsrecord.OpBinary, "\x00\x01"),
+		wsrecord.Frame{Dir: wsrecord.Out, Opcode: wsrecord.OpBinary, Conn: "e/1", Payload: []byte{0, 1}},
+		in("e/1", wsrecord.OpClose, ""),
+	})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rd, err := wsrecord.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("ws" + strings.TrimPrefix(ts.URL, "http") + "/ws")
+	r := &replayer{
+		target: u,
+		header: map[string][]string{},
+		conns:  make(map[string]*replayConn),
+		skip:   make(map[string]bool),
+	}
+	if err := r.play(rd); err != nil {
+		t.Fatal(err)
+	}
+	r.finish(100 * time.Millisecond)
+
+	// query из записи переносится, кроме сессии: на новом сервере её нет.
+	if got := queries(); len(got) != 2 || got[0] != "room=news" || got[1] != "" {
+		t.Errorf("queries = %q", got)
+	}
+
+	var report bytes.Buffer
+	// у e/2 в записи нет ответа на "two", а эхо‑сервер ответил.
+	if n := r.report(&report); n != 1 {
+		t.Errorf("mismatches = %d, want 1\n%s", n, &report)
+	}
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	want := [][]string{
+		{"conn", "sent", "received", "recorded"},
+		{"e/1", "2", "2", "2"},
+		{"e/2", "1", "1", "0", "!"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("report:\n%s", &report)
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("line %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsproto"
+)
+
+// Подпротоколы сообщений. Клиент выбирает кодек через
+// Sec-WebSocket-Protocol; без подпротокола соединение работает в json.v1.
+//
+//	json.v1  — конверт JSON в текстовом кадре;
+//	proto.v1 — конверт protobuf в бинарном кадре с uvarint‑префиксом длины.
+//
+// Логически сообщения одни и те же: обработчики получают и возвращают
+// Go‑типы, а кодек соединения переводит их в кадры. Номера полей
+// protobuf задаются тегом proto (см. пакет wsproto), схема конверта:
+//
+//	message Envelope {
+//	  uint64 seq = 1;
+//	  string type = 2;
+//	  string id = 3;
+//	  bytes payload = 4;   // payload, закодированный тем же protobuf
+//	  string reply_to = 5;
+//	}
+const (
+	ProtocolJSON  = "json.v1"
+	ProtocolProto = "proto.v1"
+)
+
+// Subprotocols — подпротоколы кодеков в порядке предпочтения сервера:
+// клиент, предложивший оба, получит protobuf.
+var Subprotocols = []string{ProtocolProto, ProtocolJSON}
+
+// errBadFrame — префикс длины protobuf‑кадра не совпадает с его размером.
+var errBadFrame = errors.New("length prefix does not match frame size")
+
+// codec переводит конверты в кадры и обратно.
+type codec interface {
+	name() string
+	// frameType — websocket.TextMessage или websocket.BinaryMessage.
+	frameType() int
+	// encode собирает конверт. Payload типа json.RawMessage или []byte
+	// считается уже закодированным и вставляется как есть.
+	encode(typ, replyTo string, payload any) ([]byte, error)
+	decode(data []byte) (Envelope, error)
+	// bind разбирает payload конверта в v.
+	bind(payload []byte, v any) error
+	// stampSeq вписывает номер сессии в готовый кадр.
+	stampSeq(data []byte, seq uint64) []byte
+}
+
+// codecFor выбирает кодек по согласованному подпротоколу. Прочие
+// подпротоколы (и их отсутствие) означают JSON.
+func codecFor(subprotocol string) codec {
+	if subprotocol == ProtocolProto {
+		return protoCodec{}
+	}
+	return jsonCodec{}
+}
+
+// Protocol возвращает подпротокол сообщений соединения.
+func (c *Client) Protocol() string { return c.codec.name() }
+
+// checkFrame отклоняет кадр не того типа, что у кодека соединения.
+func (c *Client) checkFrame(mt int) error {
+	if mt == c.codec.frameType() {
+		return nil
+	}
+	want := "text"
+	if c.codec.frameType() == websocket.BinaryMessage {
+		want = "binary"
+	}
+	return Errorf(CodeBadRequest, "%s expects %s frames", c.codec.name(), want)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) name() string   { return ProtocolJSON }
+func (jsonCodec) frameType() int { return websocket.TextMessage }
+
+func (jsonCodec) encode(typ, replyTo string, payload any) ([]byte, error) {
+	return marshalEnvelope(typ, replyTo, payload)
+}
+
+func (jsonCodec) decode(data []byte) (Envelope, error) {
+	var env Envelope
+	err := json.Unmarshal(data, &env)
+	return env, err
+}
+
+func (jsonCodec) bind(payload []byte, v any) error {
+	return json.Unmarshal(payload, v)
+}
+
+func (jsonCodec) stampSeq(data []byte, seq uint64) []byte {
+	return stampSeq(websocket.TextMessage, data, seq)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) name() string   { return ProtocolProto }
+func (protoCodec) frameType() int { return websocket.BinaryMessage }
+
+func (protoCodec) encode(typ, replyTo string, payload any) ([]byte, error) {
+	var raw []byte
+	switch p := payload.(type) {
+	case nil:
+	case json.RawMessage:
+		raw = p
+	case []byte:
+		raw = p
+	default:
+		var err error
+		if raw, err = wsproto.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	return marshalProtoEnvelope(Envelope{Type: typ, ReplyTo: replyTo, Payload: raw})
+}
+
+func (protoCodec) decode(data []byte) (Envelope, error) {
+	n, k := binary.Uvarint(data)
+	if k <= 0 || n != uint64(len(data)-k) {
+		return Envelope{}, errBadFrame
+	}
+	var env Envelope
+	err := wsproto.Unmarshal(data[k:], &env)
+	return env, err
+}
+
+func (protoCodec) bind(payload []byte, v any) error {
+	// обработчик может взять payload целиком, не разбирая его.
+	switch p := v.(type) {
+	case *json.RawMessage:
+		*p = append((*p)[:0], payload...)
+		return nil
+	case *[]byte:
+		*p = append((*p)[:0], payload...)
+		return nil
+	}
+	return wsproto.Unmarshal(payload, v)
+}
+
+// stampSeq дописывает поле seq в начало конверта: в protobuf порядок
+// полей не важен, а сервер сам seq никогда не заполняет.
+func (protoCodec) stampSeq(data []byte, seq uint64) []byte {
+	n, k := binary.Uvarint(data)
+	if k <= 0 || n != uint64(len(data)-k) {
+		return data
+	}
+	env := data[k:]
+
+	// ключ поля 1 с wire type varint.
+	field := binary.AppendUvarint([]byte{1 << 3}, seq)
+	out := make([]byte, 0, len(data)+len(field)+1)
+	out = binary.AppendUvarint(out, uint64(len(field)+len(env)))
+	out = append(out, field...)
+	return append(out, env...)
+}
+
+// marshalProtoEnvelope кодирует конверт и ставит перед ним длину.
+func marshalProtoEnvelope(env Envelope) ([]byte, error) {
+	body, err := wsproto.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(body)+binary.MaxVarintLen32)
+	out = binary.AppendUvarint(out, uint64(len(body)))
+	return append(out, body...), nil
+}
+
+// protoEnvelopeType достаёт type из protobuf‑конверта для метрик.
+func protoEnvelopeType(data []byte) (string, bool) {
+	var env struct {
+		Type string `proto:"2"`
+	}
+	n, k := binary.Uvarint(data)
+	if k <= 0 || n != uint64(len(data)-k) {
+		return "", false
+	}
+	if err := wsproto.Unmarshal(data[k:], &env); err != nil || env.Type == "" {
+		return "", false
+	}
+	return env.Type, true
+}
+
+// Рассылки кодируются в JSON один раз на всех получателей (в том же виде
+// они идут через backplane), а клиентам proto.v1 перекодируются при
+// постановке в очередь. Для этого хаб знает Go‑тип payload каждого
+// рассылаемого типа сообщения; payload незарегистрированного типа уходит
+// как есть, JSON‑байтами.
+
+// payloadTypes — Go‑типы payload'ов рассылок по типу сообщения.
+type payloadTypes struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+}
+
+// RegisterPayload сообщает хабу Go‑тип payload рассылок типа typ,
+// например RegisterPayload(TypeMessage, RoomMessage{}).
+func (h *Hub) RegisterPayload(typ string, sample any) {
+	h.payloads.mu.Lock()
+	defer h.payloads.mu.Unlock()
+
+	if h.payloads.types == nil {
+		h.payloads.types = make(map[string]reflect.Type)
+	}
+	h.payloads.types[typ] = reflect.TypeOf(sample)
+}
+
+func (pt *payloadTypes) lookup(typ string) (reflect.Type, bool) {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+
+	t, ok := pt.types[typ]
+	return t, ok
+}
+
+// transcoded — результат перекодирования, общий для всех получателей
+// одной рассылки: комната из тысячи proto.v1 клиентов перекодируется раз.
+type transcoded struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// toProto перекодирует JSON‑кадр для клиента proto.v1.
+func (h *Hub) toProto(msg outbound) (outbound, bool) {
+	t := msg.proto
+	if t == nil {
+		t = new(transcoded)
+	}
+	t.once.Do(func() { t.data, t.err = h.jsonToProto(msg.data) })
+	if t.err != nil {
+		log.Printf("transcode to %s: %v", ProtocolProto, t.err)
+		return msg, false
+	}
+	return outbound{mt: websocket.BinaryMessage, data: t.data, room: msg.room}, true
+}
+
+func (h *Hub) jsonToProto(data []byte) ([]byte, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("not an envelope: %w", err)
+	}
+
+	if t, ok := h.payloads.lookup(env.Type); ok && len(env.Payload) > 0 {
+		v := reflect.New(t)
+		if err := json.Unmarshal(env.Payload, v.Interface()); err != nil {
+			return nil, fmt.Errorf("%s payload: %w", env.Type, err)
+		}
+		raw, err := wsproto.Marshal(v.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("%s payload: %w", env.Type, err)
+		}
+		env.Payload = raw
+	}
+	return marshalProtoEnvelope(env)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsproto"
+)
+
+// dialProto подключается в proto.v1.
+func dialProto(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	d := websocket.Dialer{Subprotocols: []string{ProtocolProto}}
+	conn, _, err := d.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if conn.Subprotocol() != ProtocolProto {
+		t.Fatalf("subprotocol = %q", conn.Subprotocol())
+	}
+	return conn
+}
+
+// sendProto отправляет запрос в proto.v1.
+func sendProto(t *testing.T, conn *websocket.Conn, typ, id string, payload any) {
+	t.Helper()
+
+	raw, err := wsproto.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := marshalProtoEnvelope(Envelope{Type: typ, ID: id, Payload: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readProto читает конверт proto.v1.
+func readProto(t *testing.T, conn *websocket.Conn) Envelope {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	mt, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if mt != websocket.BinaryMessage {
+		t.Fatalf("frame type %d, want binary", mt)
+	}
+	env, err := protoCodec{}.decode(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return env
+}
+
+// sendJSON отправляет запрос в json.v1.
+func sendJSON(t *testing.T, conn *websocket.Conn, typ, id string, payload any) {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(Envelope{Type: typ, ID: id, Payload: data}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCrossCodecPublish(t *testing.T) {
+	srv := NewServer(Config{Handshake: HandshakePolicy{Subprotocols: Subprotocols, AllowMissingOrigin: true}})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	url := wsURL(ts, "/ws")
+
+	pa, pb, js := dialProto(t, url), dialProto(t, url), dial(t, url, nil)
+	for _, conn := range []*websocket.Conn{pa, pb} {
+		sendProto(t, conn, TypeJoin, "j", RoomRequest{Room: "mix"})
+		if env := readProto(t, conn); env.Type != TypeAck {
+			t.Fatalf("join: %+v", env)
+		}
+	}
+	sendJSON(t, js, TypeJoin, "j", RoomRequest{Room: "mix"})
+	if env := readEnvelope(t, js); env.Type != TypeAck {
+		t.Fatalf("join: %+v", env)
+	}
+
+	tests := []struct {
+		name      string
+		fromProto bool
+		proto     string // данные на стороне proto.v1
+		json      string // data на стороне json.v1
+	}{
+		{"proto binary", true, "\x08\x96\x01", `{"base64":"CJYB"}`},
+		{"proto text", true, "hi", `"hi"`},
+		{"proto json", true, `{"a":1}`, `{"a":1}`},
+		{"proto quoted", true, `"hi"`, `"\"hi\""`},
+		{"json string", false, "hi", `"hi"`},
+		{"json number string", false, "123", `"123"`},
+		{"json object", false, `{"a":1}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		if tt.fromProto {
+			// как клиент со схемой .proto: data — просто bytes.
+			sendProto(t, pa, TypePublish, "p", struct {
+				Room string `proto:"1"`
+				Data []byte `proto:"2"`
+			}{"mix", []byte(tt.proto)})
+		} else {
+			sendJSON(t, js, TypePublish, "p", PublishRequest{Room: "mix", Data: Data(tt.json)})
+		}
+
+		for _, conn := range []*websocket.Conn{pa, pb} {
+			if got := protoMessage(t, conn); string(got) != tt.proto {
+				t.Errorf("%s: proto client got %q, want %q", tt.name, got, tt.proto)
+			}
+		}
+		if got := jsonMessage(t, js); got != tt.json {
+			t.Errorf("%s: json client got %s, want %s", tt.name, got, tt.json)
+		}
+	}
+}
+
+// protoMessage читает в proto.v1 следующее TypeMessage, пропуская ack, и
+// возвращает его data как байты protobuf.
+func protoMessage(t *testing.T, conn *websocket.Conn) []byte {
+	t.Helper()
+
+	for {
+		env := readProto(t, conn)
+		switch env.Type {
+		case TypeAck:
+			continue
+		case TypeMessage:
+		default:
+			t.Fatalf("got %+v", env)
+		}
+		var msg struct {
+			Room string `proto:"1"`
+			Data []byte `proto:"3"`
+		}
+		if err := wsproto.Unmarshal(env.Payload, &msg); err != nil || msg.Room != "mix" {
+			t.Fatalf("message %+v: %v", msg, err)
+		}
+		return msg.Data
+	}
+}
+
+// jsonMessage читает в json.v1 следующее TypeMessage, пропуская ack, и
+// возвращает его data как есть.
+func jsonMessage(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	for {
+		env := readEnvelope(t, conn)
+		switch env.Type {
+		case TypeAck:
+			continue
+		case TypeMessage:
+		default:
+			t.Fatalf("got %+v", env)
+		}
+		var msg struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(env.Payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return string(msg.Data)
+	}
+}
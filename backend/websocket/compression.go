@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultMaxMessageSize — лимит размера входящего сообщения по умолчанию.
+// Без него один кадр на гигабайт целиком оказался бы в памяти.
+const DefaultMaxMessageSize = 64 << 10
+
+// errMessageTooBig — сообщение больше MaxMessageSize после распаковки.
+var errMessageTooBig = errors.New("message exceeds size limit")
+
+// readMessage читает сообщение целиком с учётом MaxMessageSize.
+// SetReadLimit в gorilla считает байты кадров на проводе, то есть уже
+// сжатые: сотня байт deflate распаковывается в мегабайты. Поэтому
+// распакованный поток дополнительно ограничивается здесь.
+func (c *Client) readMessage() (int, []byte, error) {
+	mt, r, err := c.conn.NextReader()
+	if err != nil {
+		return mt, nil, err
+	}
+	limit := c.hub.cfg.maxMessageSize()
+	if limit < 0 {
+		data, err := io.ReadAll(r)
+		return mt, data, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		return mt, nil, fmt.Errorf("%w: more than %d bytes", errMessageTooBig, limit)
+	}
+	return mt, data, err
+}
+
+// CompressionMode — политика сжатия для комнаты.
+type CompressionMode int
+
+const (
+	// CompressAuto сжимает сообщения не меньше MinSize.
+	CompressAuto CompressionMode = iota
+	// CompressAlways сжимает всё (текстовые комнаты с длинными сообщениями).
+	CompressAlways
+	// CompressNever не сжимает (уже сжатые бинарные данные, котировки).
+	CompressNever
+)
+
+// CompressionConfig — настройки permessage-deflate (RFC 7692).
+type CompressionConfig struct {
+	// Enabled разрешает согласование расширения при рукопожатии.
+	Enabled bool
+	// MinSize — сообщения короче не сжимаются: на маленьких deflate
+	// тратит CPU и почти ничего не выигрывает.
+	MinSize int
+	// Level — уровень flate от -2 до 9; 0 — уровень gorilla по умолчанию.
+	Level int
+	// Rooms — политика по комнатам; для остальных CompressAuto.
+	// Личные сообщения (не из комнаты) тоже идут по CompressAuto.
+	Rooms map[string]CompressionMode
+}
+
+// shouldCompress решает, сжимать ли конкретное сообщение.
+func (cc CompressionConfig) shouldCompress(room string, size int) bool {
+	if !cc.Enabled {
+		return false
+	}
+	switch cc.Rooms[room] {
+	case CompressAlways:
+		return true
+	case CompressNever:
+		return false
+	default:
+		return size >= cc.MinSize
+	}
+}
+
+// CompressionStats — сколько байт payload ушло и сколько это заняло на
+// проводе (с заголовками кадров). Ratio = WireBytes / PayloadBytes:
+// чем меньше, тем выгоднее сжатие.
+type CompressionStats struct {
+	Messages     uint64  `json:"messages"`
+	PayloadBytes uint64  `json:"payload_bytes"`
+	WireBytes    uint64  `json:"wire_bytes"`
+	Ratio        float64 `json:"ratio"`
+}
+
+// compressionCounters — счётчики для сжатых и несжатых сообщений.
+type compressionCounters struct {
+	messages, payload, wire atomic.Uint64
+}
+
+func (cc *compressionCounters) observe(payload, wire int) {
+	cc.messages.Add(1)
+	cc.payload.Add(uint64(payload))
+	cc.wire.Add(uint64(wire))
+}
+
+func (cc *compressionCounters) snapshot() CompressionStats {
+	s := CompressionStats{
+		Messages:     cc.messages.Load(),
+		PayloadBytes: cc.payload.Load(),
+		WireBytes:    cc.wire.Load(),
+	}
+	if s.PayloadBytes > 0 {
+		s.Ratio = float64(s.WireBytes) / float64(s.PayloadBytes)
+	}
+	return s
+}
+
+// wireConn — net.Conn соединения после hijack: считает байты, реально
+// записанные в сокет, и помнит, согласован ли permessage-deflate.
+type wireConn struct {
+	net.Conn
+	written atomic.Uint64
+	deflate bool
+}
+
+func (c *wireConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(uint64(n))
+	return n, err
+}
+
+// countingWriter подменяет Hijack, чтобы gorilla/websocket работал
+// поверх wireConn.
+type countingWriter struct {
+	http.ResponseWriter
+	deflate bool
+}
+
+func (w *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &wireConn{Conn: conn, deflate: w.deflate}, brw, nil
+}
+
+// offersDeflate сообщает, что клиент предложил permessage-deflate.
+// gorilla принимает расширение ровно в этом случае, если сжатие включено.
+func offersDeflate(r *http.Request) bool {
+	for _, v := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, ext := range strings.Split(v, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(ext), ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
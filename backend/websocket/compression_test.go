@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShouldCompress(t *testing.T) {
+	cc := CompressionConfig{
+		Enabled: true,
+		MinSize: 100,
+		Rooms:   map[string]CompressionMode{"logs": CompressAlways, "quotes": CompressNever},
+	}
+	tests := []struct {
+		room string
+		size int
+		want bool
+	}{
+		{"news", 99, false},
+		{"news", 100, true},
+		{"", 500, true}, // личное сообщение — CompressAuto
+		{"logs", 1, true},
+		{"quotes", 1 << 20, false},
+	}
+	for _, tt := range tests {
+		if got := cc.shouldCompress(tt.room, tt.size); got != tt.want {
+			t.Errorf("shouldCompress(%q, %d) = %v, want %v", tt.room, tt.size, got, tt.want)
+		}
+	}
+	if (CompressionConfig{Rooms: cc.Rooms}).shouldCompress("logs", 1000) {
+		t.Error("compressed with compression disabled")
+	}
+}
+
+func TestOffersDeflate(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"permessage-deflate"}, true},
+		{[]string{"x-webkit-deflate-frame, permessage-deflate; client_max_window_bits"}, true},
+		{[]string{"foo", "Permessage-Deflate ;server_no_context_takeover"}, true},
+		{[]string{"permessage-deflate-x"}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		for _, v := range tt.header {
+			r.Header.Add("Sec-WebSocket-Extensions", v)
+		}
+		if got := offersDeflate(r); got != tt.want {
+			t.Errorf("offersDeflate(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompressionPolicy(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Hub: HubConfig{Compression: CompressionConfig{
+			Enabled: true,
+			MinSize: 100,
+			Rooms:   map[string]CompressionMode{"quotes": CompressNever},
+		}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	d := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := d.Dial(wsURL(ts, "/ws?room=news&room=quotes"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("deflate not negotiated: %q", ext)
+	}
+	waitFor(t, "client", func() bool { return srv.Hub().Len() == 1 })
+
+	big := strings.Repeat("compress me ", 100)
+	sent := []struct{ room, msg string }{
+		{"news", big},     // сжимается
+		{"news", "short"}, // меньше MinSize
+		{"quotes", big},   // CompressNever
+	}
+	for _, s := range sent {
+		srv.Hub().Broadcast(s.room, websocket.TextMessage, []byte(s.msg))
+		if got := readText(t, conn); got != s.msg {
+			t.Fatalf("got %d bytes, want %d", len(got), len(s.msg))
+		}
+	}
+
+	compressed, uncompressed := srv.Hub().Compression()
+	if compressed.Messages != 1 || compressed.PayloadBytes != uint64(len(big)) || compressed.Ratio >= 0.5 {
+		t.Errorf("compressed = %+v", compressed)
+	}
+	if uncompressed.Messages != 2 || uncompressed.PayloadBytes != uint64(len(big)+len("short")) || uncompressed.Ratio < 1 {
+		t.Errorf("uncompressed = %+v", uncompressed)
+	}
+}
+
+func TestCompressionNotOffered(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Hub:       HubConfig{Compression: CompressionConfig{Enabled: true, Rooms: map[string]CompressionMode{"news": CompressAlways}}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	// клиент без расширения получает несжатые кадры даже из CompressAlways.
+	conn := dial(t, wsURL(ts, "/ws?room=news"), nil)
+	waitFor(t, "client", func() bool { return srv.Hub().Len() == 1 })
+	srv.Hub().Broadcast("news", websocket.TextMessage, []byte("plain"))
+	if got := readText(t, conn); got != "plain" {
+		t.Fatalf("got %q", got)
+	}
+	if compressed, uncompressed := srv.Hub().Compression(); compressed.Messages != 0 || uncompressed.Messages != 1 {
+		t.Errorf("compressed = %+v, uncompressed = %+v", compressed, uncompressed)
+	}
+}
+
+func TestDecompressedSizeLimit(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Hub:       HubConfig{Compression: CompressionConfig{Enabled: true}, MaxMessageSize: 1024},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	d := websocket.Dialer{EnableCompression: true}
+	conn, _, err := d.Dial(wsURL(ts, "/ws"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 64 КиБ нулей сжимаются в сотню байт: лимит кадра на проводе не сработал
+	// бы, поэтому проверяется распакованный размер.
+	conn.EnableWriteCompression(true)
+	if err := conn.WriteMessage(websocket.TextMessage, make([]byte, 64<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if code := closeCode(conn); code != websocket.CloseMessageTooBig {
+		t.Errorf("close code = %d, want %d", code, websocket.CloseMessageTooBig)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Data — прикладные данные сообщения: data в publish, message и system.
+//
+// Внутри сервера и в json.v1 это JSON‑значение, как json.RawMessage:
+// клиенты json.v1 получают ровно то, что отправили. В proto.v1 это поле
+// bytes, и значение переводится на границе кодека:
+//
+//   - JSON‑строка — её текст без кавычек ("hi" ⇄ hi);
+//   - прочие JSON‑значения — их текст ({"a":1} ⇄ {"a":1});
+//   - байты, которые не являются текстом UTF‑8, — {"base64":"..."}.
+//
+// Текст от клиента proto.v1, который не переживает json.Marshal без
+// изменений (не JSON, JSON‑строка, JSON с пробелами), становится
+// JSON‑строкой. Поэтому байты клиента proto.v1 доходят до других клиентов
+// proto.v1 как есть, хотя рассылка идёт через JSON и backplane.
+type Data []byte
+
+// MarshalJSON возвращает JSON‑значение; пустые данные — null.
+func (d Data) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+// UnmarshalJSON сохраняет JSON‑значение как есть.
+func (d *Data) UnmarshalJSON(b []byte) error {
+	*d = append((*d)[:0], b...)
+	return nil
+}
+
+// MarshalProto переводит JSON‑значение в байты proto.v1.
+func (d Data) MarshalProto() ([]byte, error) {
+	if len(d) == 0 || string(d) == "null" {
+		return nil, nil
+	}
+	if bin, ok := binaryData(d); ok {
+		return bin, nil
+	}
+	if d[0] == '"' {
+		var s string
+		if err := json.Unmarshal(d, &s); err != nil {
+			return nil, fmt.Errorf("data: %w", err)
+		}
+		return []byte(s), nil
+	}
+	return d, nil
+}
+
+// UnmarshalProto переводит байты proto.v1 в JSON‑значение.
+func (d *Data) UnmarshalProto(b []byte) error {
+	var err error
+	switch {
+	case len(b) == 0:
+		*d = nil
+	case !utf8.Valid(b):
+		*d, err = json.Marshal(struct {
+			Base64 []byte `json:"base64"`
+		}{b})
+	case verbatim(b):
+		*d = append((*d)[:0], b...)
+	default:
+		*d, err = json.Marshal(string(b))
+	}
+	return err
+}
+
+// verbatim — b можно отдать в JSON как есть: это JSON‑значение (не
+// строка), json.Marshal его не переписывает и оно не читается как
+// обёртка base64.
+func verbatim(b []byte) bool {
+	if b[0] == '"' {
+		return false
+	}
+	if _, ok := binaryData(b); ok {
+		return false
+	}
+	out, err := json.Marshal(json.RawMessage(b))
+	return err == nil && string(out) == string(b)
+}
+
+// binaryData раскрывает {"base64":"..."} — единственный ключ со строкой
+// в base64.
+func binaryData(d []byte) ([]byte, bool) {
+	if d[0] != '{' {
+		return nil, false
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(d, &obj) != nil || len(obj) != 1 {
+		return nil, false
+	}
+	var s string
+	if json.Unmarshal(obj["base64"], &s) != nil {
+		return nil, false
+	}
+	bin, err := base64.StdEncoding.DecodeString(s)
+	return bin, err == nil
+}
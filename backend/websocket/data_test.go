@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDataFromJSON(t *testing.T) {
+	tests := []struct {
+		json, proto string
+	}{
+		{`"hi"`, `hi`},
+		{`"\"quoted\""`, `"quoted"`},
+		{`"123"`, `123`},
+		{`{"a":1}`, `{"a":1}`},
+		{`[1,2]`, `[1,2]`},
+		{`42`, `42`},
+		{`null`, ``},
+		{`{"base64":"CJYB"}`, "\x08\x96\x01"},
+	}
+	for _, tt := range tests {
+		var d Data
+		if err := json.Unmarshal([]byte(tt.json), &d); err != nil {
+			t.Fatal(err)
+		}
+		got, err := d.MarshalProto()
+		if err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if string(got) != tt.proto {
+			t.Errorf("%s: proto %q, want %q", tt.json, got, tt.proto)
+		}
+		// json.v1 получает значение без изменений.
+		if out, _ := json.Marshal(d); string(out) != tt.json {
+			t.Errorf("%s: json %s", tt.json, out)
+		}
+	}
+}
+
+func TestDataFromProto(t *testing.T) {
+	tests := []struct {
+		proto, json string
+	}{
+		{"\x08\x96\x01", `{"base64":"CJYB"}`},
+		{`hi`, `"hi"`},
+		{`{"a":1}`, `{"a":1}`},
+		{`{ "a": 1 }`, `"{ \"a\": 1 }"`},
+		{`"hi"`, `"\"hi\""`},
+		{`<b>`, `"\u003cb\u003e"`},
+		{`{"a":"<"}`, `"{\"a\":\"\u003c\"}"`},
+		{`{"base64":"aGk="}`, `"{\"base64\":\"aGk=\"}"`},
+		{`7`, `7`},
+		{``, `null`},
+	}
+	for _, tt := range tests {
+		var d Data
+		if err := d.UnmarshalProto([]byte(tt.proto)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(d)
+		if err != nil {
+			t.Errorf("%q: %v", tt.proto, err)
+			continue
+		}
+		if string(out) != tt.json {
+			t.Errorf("%q: json %s, want %s", tt.proto, out, tt.json)
+		}
+
+		// через JSON байты возвращаются к proto.v1 без изменений.
+		var back Data
+		if err := json.Unmarshal(out, &back); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := back.MarshalProto(); !bytes.Equal(got, []byte(tt.proto)) {
+			t.Errorf("%q: round trip %q", tt.proto, got)
+		}
+	}
+}
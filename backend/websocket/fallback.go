@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
+)
+
+// Запасные транспорты для клиентов за прокси, которые вырезают Upgrade:
+//
+//	GET  /sse?room=a        — поток Server-Sent Events (сервер -> клиент);
+//	GET  /poll?room=a       — открыть long-poll соединение, ответ {"conn": ...};
+//	GET  /poll?conn=ID      — забрать накопленные сообщения (JSON‑массив);
+//	POST /send?conn=ID      — отправить один конверт (клиент -> сервер).
+//
+// Клиент за ними — обычный *Client хаба без WebSocket‑соединения: он
+// входит в комнаты, получает рассылки, а его конверты разбирает тот же
+// Router, что и на /ws. Обработчики разницы не видят.
+
+// значения FallbackConfig по умолчанию.
+const (
+	DefaultPollTimeout  = 25 * time.Second
+	DefaultSSEKeepAlive = 15 * time.Second
+)
+
+// TypeOpen — первое сообщение SSE и ответ на открытие long-poll.
+const TypeOpen = "open"
+
+// FallbackConfig — настройки HTTP‑транспортов.
+type FallbackConfig struct {
+	Enabled bool
+	// PollTimeout — сколько long-poll запрос ждёт сообщений.
+	// 0 — DefaultPollTimeout.
+	PollTimeout time.Duration
+	// IdleTimeout — long-poll клиент, не опрашивавший сервер дольше,
+	// отключается. 0 — два PollTimeout.
+	IdleTimeout time.Duration
+	// KeepAlive — период комментариев в SSE‑потоке, чтобы прокси не
+	// закрывали простаивающее соединение. 0 — DefaultSSEKeepAlive.
+	KeepAlive time.Duration
+}
+
+func (cfg FallbackConfig) pollTimeout() time.Duration {
+	if cfg.PollTimeout <= 0 {
+		return DefaultPollTimeout
+	}
+	return cfg.PollTimeout
+}
+
+func (cfg FallbackConfig) idleTimeout() time.Duration {
+	if cfg.IdleTimeout <= 0 {
+		return 2 * cfg.pollTimeout()
+	}
+	return cfg.IdleTimeout
+}
+
+func (cfg FallbackConfig) keepAlive() time.Duration {
+	if cfg.KeepAlive <= 0 {
+		return DefaultSSEKeepAlive
+	}
+	return cfg.KeepAlive
+}
+
+// OpenInfo — payload TypeOpen: идентификатор для /send и /poll.
+type OpenInfo struct {
+	Conn string `json:"conn"`
+}
+
+// streamClient — клиент HTTP‑транспорта.
+type streamClient struct {
+	client *Client
+	limit  *connLimit
+	// sendMu сериализует /send одного клиента: лимиты соединения и
+	// Dispatch рассчитаны на одного читателя, как readPump у WebSocket.
+	sendMu sync.Mutex
+	// lastPoll — время последнего long-poll запроса (unix nano).
+	lastPoll atomic.Int64
+}
+
+// streamRegistry находит клиента по непредсказуемому идентификатору:
+// последовательный ID из хаба для этого не годится, его легко подобрать.
+type streamRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*streamClient
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{clients: make(map[string]*streamClient)}
+}
+
+func (sr *streamRegistry) get(token string) (*streamClient, bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sc, ok := sr.clients[token]
+	return sc, ok
+}
+
+// openStream регистрирует клиента в хабе и входит в комнаты.
+func (s *Server) openStream(r *http.Request, p *Principal, limit *connLimit, rooms []string, transport string) (string, *streamClient) {
+	sc := &streamClient{client: s.hub.registerStream(p, transport, r.RemoteAddr), limit: limit}
+	sc.lastPoll.Store(time.Now().UnixNano())
+	token := newSessionID()
+
+	s.streams.mu.Lock()
+	s.streams.clients[token] = sc
+	s.streams.mu.Unlock()
+
+	for _, room := range rooms {
+		s.hub.Join(sc.client, room)
+	}
+	s.cfg.Metrics.upgradeAccepted()
+	return token, sc
+}
+
+func (s *Server) closeStream(token string, sc *streamClient) {
+	s.streams.mu.Lock()
+	delete(s.streams.clients, token)
+	s.streams.mu.Unlock()
+
+	s.hub.Unregister(sc.client)
+	sc.limit.release()
+}
+
+// sseHandler отдаёт рассылки комнат как text/event-stream.
+func (s *Server) sseHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	rooms := requestRooms(r)
+	limit, ok := s.limiter.Admit(w, r)
+	if !ok {
+		return
+	}
+	principal, ok := s.admit(w, r, rooms...)
+	if !ok {
+		limit.release()
+		return
+	}
+
+	token, sc := s.openStream(r, principal, limit, rooms, "sse")
+	defer s.closeStream(token, sc)
+	c := sc.client
+	c.recordOpen(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx иначе буферизует поток целиком.
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	open, err := marshalEnvelope(TypeOpen, "", OpenInfo{Conn: token})
+	if err != nil {
+		log.Println("sse open:", err)
+		return
+	}
+	writeSSE(w, outbound{mt: websocket.TextMessage, data: open})
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(s.cfg.Fallback.keepAlive())
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case msg := <-c.send:
+			start := time.Now()
+			if err := writeSSE(w, msg); err != nil {
+				log.Printf("client %s: sse write error: %v", c.id, err)
+				return
+			}
+			flusher.Flush()
+			s.cfg.Metrics.observeWrite(time.Since(start))
+			s.cfg.Metrics.observeMessage("out", msg.mt, msg.data)
+			c.record(wsrecord.Out, msg.mt, msg.data)
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE пишет одно событие. Бинарные кадры уходят как event: binary
+// в base64: в text/event-stream можно передать только текст.
+func writeSSE(w io.Writer, msg outbound) error {
+	var b bytes.Buffer
+	data := msg.data
+	if msg.mt == websocket.BinaryMessage {
+		b.WriteString("event: binary\n")
+		data = []byte(base64.StdEncoding.EncodeToString(data))
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// pollHandler открывает long-poll соединение или отдаёт накопленное.
+func (s *Server) pollHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("conn")
+	if token == "" {
+		s.openPoll(w, r)
+		return
+	}
+
+	sc, ok := s.streams.get(token)
+	if !ok || !sc.samePrincipal(s, r) {
+		http.Error(w, "unknown connection", http.StatusGone)
+		return
+	}
+	sc.lastPoll.Store(time.Now().UnixNano())
+	c := sc.client
+
+	timer := time.NewTimer(s.cfg.Fallback.pollTimeout())
+	defer timer.Stop()
+
+	var batch []outbound
+	select {
+	case msg := <-c.send:
+		batch = append(batch, msg)
+		// забираем всё, что уже накопилось, не дожидаясь следующего опроса.
+	drain:
+		for len(batch) < cap(c.send) {
+			select {
+			case msg := <-c.send:
+				batch = append(batch, msg)
+			default:
+				break drain
+			}
+		}
+	case <-timer.C:
+	case <-c.done:
+		http.Error(w, "connection closed", http.StatusGone)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	sc.lastPoll.Store(time.Now().UnixNano())
+
+	out := make([]json.RawMessage, 0, len(batch))
+	for _, msg := range batch {
+		s.cfg.Metrics.observeMessage("out", msg.mt, msg.data)
+		c.record(wsrecord.Out, msg.mt, msg.data)
+		out = append(out, pollMessage(msg))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("client %s: poll write error: %v", c.id, err)
+	}
+}
+
+// pollMessage приводит кадр к элементу JSON‑массива; бинарный кадр
+// становится base64‑строкой.
+func pollMessage(msg outbound) json.RawMessage {
+	if msg.mt == websocket.BinaryMessage {
+		data, _ := json.Marshal(msg.data)
+		return data
+	}
+	return msg.data
+}
+
+func (s *Server) openPoll(w http.ResponseWriter, r *http.Request) {
+	rooms := requestRooms(r)
+	limit, ok := s.limiter.Admit(w, r)
+	if !ok {
+		return
+	}
+	principal, ok := s.admit(w, r, rooms...)
+	if !ok {
+		limit.release()
+		return
+	}
+
+	token, sc := s.openStream(r, principal, limit, rooms, "longpoll")
+	sc.client.recordOpen(r)
+	go s.reapPoll(token, sc)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(OpenInfo{Conn: token}); err != nil {
+		log.Println("poll open:", err)
+	}
+}
+
+// reapPoll отключает long-poll клиента, который перестал опрашивать сервер.
+func (s *Server) reapPoll(token string, sc *streamClient) {
+	idle := s.cfg.Fallback.idleTimeout()
+	ticker := time.NewTicker(idle / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(time.Unix(0, sc.lastPoll.Load())) > idle {
+				log.Printf("client %s: no poll for %s, reaping", sc.client.id, idle)
+				sc.client.markReaped()
+				s.closeStream(token, sc)
+				return
+			}
+		case <-sc.client.done:
+			s.closeStream(token, sc)
+			return
+		}
+	}
+}
+
+// sendHandler принимает конверт от SSE или long-poll клиента.
+func (s *Server) sendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sc, ok := s.streams.get(r.URL.Query().Get("conn"))
+	if !ok || !sc.samePrincipal(s, r) {
+		http.Error(w, "unknown connection", http.StatusGone)
+		return
+	}
+	c := sc.client
+
+	if limit := s.cfg.Hub.maxMessageSize(); limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	msg, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, fmt.Sprintf("message exceeds %d bytes", tooBig.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "read error", http.StatusBadRequest)
+		return
+	}
+
+	sc.sendMu.Lock()
+	s.cfg.Metrics.observeMessage("in", websocket.TextMessage, msg)
+	c.record(wsrecord.In, websocket.TextMessage, msg)
+	if sc.limit.allow(c, len(msg)) {
+		// ответ (ack или error) уйдёт в поток клиента, как и на /ws.
+		s.router.Dispatch(c, msg)
+	}
+	sc.sendMu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// samePrincipal не даёт отправлять от имени чужого соединения, даже
+// зная его идентификатор.
+func (sc *streamClient) samePrincipal(s *Server, r *http.Request) bool {
+	p, err := authenticate(s.cfg.Auth, r)
+	if err != nil {
+		return false
+	}
+	owner := sc.client.Principal()
+	if owner == nil || p == nil {
+		return owner == nil && p == nil
+	}
+	return owner.Subject == p.Subject
+}
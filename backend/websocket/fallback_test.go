@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sseStream читает события text/event-stream по одному.
+type sseStream struct {
+	t  *testing.T
+	rd *bufio.Reader
+}
+
+func openSSE(t *testing.T, url string) (*sseStream, *http.Response) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", url, resp.Status)
+	}
+	return &sseStream{t: t, rd: bufio.NewReader(resp.Body)}, resp
+}
+
+// next возвращает data следующего события; комментарии пропускает.
+func (s *sseStream) next() string {
+	s.t.Helper()
+
+	var data []string
+	for {
+		line, err := s.rd.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("sse read: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != nil:
+			return strings.Join(data, "\n")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+// open читает TypeOpen и возвращает идентификатор соединения.
+func (s *sseStream) open() string {
+	s.t.Helper()
+
+	var env Envelope
+	var info OpenInfo
+	if err := json.Unmarshal([]byte(s.next()), &env); err != nil || env.Type != TypeOpen || json.Unmarshal(env.Payload, &info) != nil {
+		s.t.Fatalf("first event is not %s: %+v", TypeOpen, env)
+	}
+	return info.Conn
+}
+
+func TestSSESend(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Fallback:  FallbackConfig{Enabled: true},
+	})
+	srv.Handle("shout", func(req *Request) (any, error) {
+		var s string
+		if err := req.Bind(&s); err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(s), nil
+	})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close) // после потока SSE: Close ждёт активные запросы
+
+	sse, _ := openSSE(t, ts.URL+"/sse?room=news")
+	conn := sse.open()
+
+	// многострочный кадр уходит несколькими строками data.
+	srv.Hub().Broadcast("news", websocket.TextMessage, []byte("line1\nline2"))
+	if got := sse.next(); got != "line1\nline2" {
+		t.Errorf("broadcast = %q", got)
+	}
+
+	resp, err := http.Post(ts.URL+"/send?conn="+conn, "application/json", strings.NewReader(`{"type":"shout","id":"1","payload":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("send: %s", resp.Status)
+	}
+	// ответ приходит в поток, а не в ответ на POST.
+	if got := sse.next(); got != `{"type":"ack","payload":"HI","reply_to":"1"}` {
+		t.Errorf("ack = %s", got)
+	}
+
+	resp, err = http.Post(ts.URL+"/send?conn=nope", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("unknown conn: %s", resp.Status)
+	}
+}
+
+func TestConcurrentSend(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Fallback:  FallbackConfig{Enabled: true},
+		RateLimit: RateLimitConfig{
+			PerConn: MessageLimits{Messages: Rate{PerSecond: 1, Burst: 1}},
+			Action:  LimitWarn,
+		},
+	})
+	srv.Handle("noop", func(*Request) (any, error) { return nil, nil })
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close) // после потока SSE: Close ждёт активные запросы
+
+	sse, _ := openSSE(t, ts.URL+"/sse")
+	conn := sse.open()
+
+	// параллельные /send одного клиента делят lastWarn и ведра соединения
+	// и всё равно получают одно предупреждение.
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(ts.URL+"/send?conn="+conn, "application/json", strings.NewReader(`{"type":"noop"}`))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// предупреждение о лимите — не чаще раза в секунду.
+	var env Envelope
+	if err := json.Unmarshal([]byte(sse.next()), &env); err != nil || env.Type != TypeRateLimited {
+		t.Fatalf("got %+v, want %s", env, TypeRateLimited)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(srv.Hub().snapshot()[0].send); n != 0 {
+		t.Errorf("%d more warnings queued", n)
+	}
+}
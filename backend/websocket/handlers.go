@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// типы сообщений, которые сервер понимает из коробки.
+const (
+	TypeJoin    = "join"
+	TypeLeave   = "leave"
+	TypePublish = "publish"
+	TypeMessage = "message"
+	TypeEcho    = "echo"
+)
+
+// RoomRequest — payload для join/leave.
+type RoomRequest struct {
+	Room string `json:"room" proto:"1"`
+}
+
+// PublishRequest — payload для publish.
+type PublishRequest struct {
+	Room string `json:"room" proto:"1"`
+	Data Data   `json:"data" proto:"2"`
+}
+
+// RoomMessage — то, что получают участники комнаты.
+type RoomMessage struct {
+	Room string `json:"room" proto:"1"`
+	From string `json:"from" proto:"2"`
+	Data Data   `json:"data" proto:"3"`
+}
+
+// PublishResult — ответ на publish.
+type PublishResult struct {
+	Delivered int `json:"delivered" proto:"1"`
+}
+
+// registerBuiltins подключает стандартные обработчики к роутеру.
+func (s *Server) registerBuiltins() {
+	s.router.Handle(TypeJoin, s.handleJoin)
+	s.router.Handle(TypeLeave, s.handleLeave)
+	s.router.Handle(TypePublish, s.handlePublish)
+	s.router.Handle(TypeEcho, func(req *Request) (any, error) {
+		return req.Envelope.Payload, nil
+	})
+	s.hub.RegisterPayload(TypeMessage, RoomMessage{})
+}
+
+func (s *Server) handleJoin(req *Request) (any, error) {
+	var in RoomRequest
+	if err := req.Bind(&in); err != nil {
+		return nil, err
+	}
+	if in.Room == "" {
+		return nil, Errorf(CodeBadRequest, "room is required")
+	}
+	if err := s.Join(req.Client, in.Room); err != nil {
+		return nil, Errorf(CodeForbidden, "cannot join %q", in.Room)
+	}
+	return in, nil
+}
+
+func (s *Server) handleLeave(req *Request) (any, error) {
+	var in RoomRequest
+	if err := req.Bind(&in); err != nil {
+		return nil, err
+	}
+	s.hub.Leave(req.Client, in.Room)
+	return in, nil
+}
+
+func (s *Server) handlePublish(req *Request) (any, error) {
+	var in PublishRequest
+	if err := req.Bind(&in); err != nil {
+		return nil, err
+	}
+	if !s.hub.InRoom(req.Client, in.Room) {
+		return nil, Errorf(CodeForbidden, "not a member of %q", in.Room)
+	}
+
+	data, err := marshalEnvelope(TypeMessage, "", RoomMessage{
+		Room: in.Room,
+		From: senderName(req.Client),
+		Data: in.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return PublishResult{Delivered: s.hub.Broadcast(in.Room, websocket.TextMessage, data)}, nil
+}
+
+// senderName — как отправитель виден остальным: субъект токена или ID соединения.
+func senderName(c *Client) string {
+	if p := c.Principal(); p != nil && p.Subject != "" {
+		return p.Subject
+	}
+	return c.ID()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// причины отказа, которые выставляет сама HandshakePolicy.
+const (
+	ReasonOrigin      = "origin not allowed"
+	ReasonSubprotocol = "unsupported subprotocol"
+)
+
+// HandshakeError — отказ в апгрейде с HTTP‑статусом и причиной,
+// которые уходят клиенту до переключения протокола.
+type HandshakeError struct {
+	Status int
+	Reason string
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake rejected: %d %s", e.Status, e.Reason)
+}
+
+// Reject создаёт HandshakeError; удобно возвращать из HandshakeHook.
+func Reject(status int, reason string) error {
+	return &HandshakeError{Status: status, Reason: reason}
+}
+
+// HandshakeHook вызывается перед апгрейдом. Ошибка типа *HandshakeError
+// отдаётся клиенту как есть, любая другая превращается в 403.
+type HandshakeHook func(r *http.Request) error
+
+// HandshakePolicy описывает, какие запросы на апгрейд принимаются.
+type HandshakePolicy struct {
+	// AllowedOrigins — белый список Origin. Поддерживаются точные значения
+	// ("https://app.example.com") и wildcard по поддоменам
+	// ("https://*.example.com"). Пустой список означает same‑origin.
+	AllowedOrigins []string
+	// AllowMissingOrigin пропускает запросы без Origin (не‑браузерные клиенты).
+	AllowMissingOrigin bool
+
+	// Subprotocols — поддерживаемые подпротоколы в порядке предпочтения.
+	Subprotocols []string
+	// RequireSubprotocol отклоняет клиентов, не предложивших ни одного
+	// из Subprotocols.
+	RequireSubprotocol bool
+
+	// BeforeUpgrade — дополнительные проверки (IP, заголовки, лимиты).
+	BeforeUpgrade []HandshakeHook
+}
+
+// Check применяет политику к запросу. Возвращает nil или *HandshakeError.
+func (p *HandshakePolicy) Check(r *http.Request) error {
+	if !p.originAllowed(r) {
+		return Reject(http.StatusForbidden, ReasonOrigin)
+	}
+
+	if p.RequireSubprotocol && p.negotiate(r) == "" {
+		return Reject(http.StatusBadRequest, ReasonSubprotocol)
+	}
+
+	for _, hook := range p.BeforeUpgrade {
+		if err := hook(r); err != nil {
+			var herr *HandshakeError
+			if errors.As(err, &herr) {
+				return herr
+			}
+			return &HandshakeError{Status: http.StatusForbidden, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Upgrader собирает websocket.Upgrader с учётом политики.
+func (p *HandshakePolicy) Upgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    p.Subprotocols,
+		CheckOrigin:     p.originAllowed,
+	}
+}
+
+func (p *HandshakePolicy) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return p.AllowMissingOrigin
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	// без белого списка ведём себя как gorilla по умолчанию: same‑origin.
+	if len(p.AllowedOrigins) == 0 {
+		return strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, allowed := range p.AllowedOrigins {
+		if matchOrigin(allowed, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin сравнивает Origin с шаблоном из белого списка.
+// "*" разрешает любой Origin, "https://*.example.com" — любые поддомены
+// example.com (но не сам example.com).
+func matchOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	p, err := url.Parse(pattern)
+	if err != nil || !strings.EqualFold(p.Scheme, origin.Scheme) {
+		return false
+	}
+
+	host := strings.ToLower(origin.Host)
+	want := strings.ToLower(p.Host)
+
+	if suffix, ok := strings.CutPrefix(want, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == want
+}
+
+// negotiate возвращает первый поддерживаемый подпротокол из предложенных клиентом.
+func (p *HandshakePolicy) negotiate(r *http.Request) string {
+	offered := websocket.Subprotocols(r)
+	for _, want := range p.Subprotocols {
+		for _, got := range offered {
+			if got == want {
+				return want
+			}
+		}
+	}
+	return ""
+}
+
+// writeHandshakeError отвечает клиенту обычным HTTP‑ответом до апгрейда.
+func writeHandshakeError(w http.ResponseWriter, err error) {
+	var herr *HandshakeError
+	if !errors.As(err, &herr) {
+		herr = &HandshakeError{Status: http.StatusForbidden, Reason: err.Error()}
+	}
+	http.Error(w, herr.Reason, herr.Status)
+}
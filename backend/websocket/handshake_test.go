@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	policy := HandshakePolicy{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"}}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"http://app.example.com", false}, // другая схема
+		{"https://app.example.com:8443", false},
+		{"https://evil.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false}, // wildcard — только поддомены
+		{"https://notexample.org", false},
+		{"null", false},
+		{"", false}, // AllowMissingOrigin выключен
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://ws.example.com/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := policy.originAllowed(r); got != tt.want {
+			t.Errorf("origin %q: allowed = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	// без списка — same-origin, как у gorilla.
+	same := HandshakePolicy{}
+	r := httptest.NewRequest(http.MethodGet, "http://ws.example.com/ws", nil)
+	r.Header.Set("Origin", "https://ws.example.com")
+	if !same.originAllowed(r) {
+		t.Error("same origin rejected")
+	}
+	r.Header.Set("Origin", "https://other.example.com")
+	if same.originAllowed(r) {
+		t.Error("cross origin allowed without a list")
+	}
+}
+
+func TestHandshakePolicy(t *testing.T) {
+	m := NewMetrics()
+	srv := NewServer(Config{Metrics: m, Handshake: HandshakePolicy{
+		AllowedOrigins:     []string{"https://*.example.com"},
+		Subprotocols:       []string{"json.v1"},
+		RequireSubprotocol: true,
+		BeforeUpgrade: []HandshakeHook{
+			func(r *http.Request) error {
+				if r.Header.Get("X-Blocked") != "" {
+					return Reject(http.StatusTooManyRequests, "slow down")
+				}
+				return nil
+			},
+			func(r *http.Request) error {
+				if r.Header.Get("X-Banned") != "" {
+					return errors.New("banned")
+				}
+				return nil
+			},
+		},
+	}})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	url := wsURL(ts, "/ws")
+
+	good := http.Header{"Origin": {"https://app.example.com"}}
+	tests := []struct {
+		name      string
+		header    http.Header
+		protocols []string
+		status    int
+		reason    string
+	}{
+		{"origin", http.Header{"Origin": {"https://evil.com"}}, []string{"json.v1"}, http.StatusForbidden, ReasonOrigin},
+		{"no subprotocol", good, nil, http.StatusBadRequest, ReasonSubprotocol},
+		{"unknown subprotocol", good, []string{"xml.v1"}, http.StatusBadRequest, ReasonSubprotocol},
+		{"hook status", http.Header{"Origin": good["Origin"], "X-Blocked": {"1"}}, []string{"json.v1"}, http.StatusTooManyRequests, "slow down"},
+		{"hook error", http.Header{"Origin": good["Origin"], "X-Banned": {"1"}}, []string{"json.v1"}, http.StatusForbidden, "banned"},
+	}
+	for _, tt := range tests {
+		d := websocket.Dialer{Subprotocols: tt.protocols}
+		_, resp, err := d.Dial(url, tt.header)
+		if err == nil || resp == nil {
+			t.Errorf("%s: dial succeeded", tt.name)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || strings.TrimSpace(string(body)) != tt.reason {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, resp.StatusCode, body, tt.status, tt.reason)
+		}
+	}
+
+	d := websocket.Dialer{Subprotocols: []string{"xml.v1", "json.v1"}}
+	conn, _, err := d.Dial(url, good)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "json.v1" {
+		t.Errorf("subprotocol = %q, want json.v1", conn.Subprotocol())
+	}
+	if got := m.rejected.get("origin"); got != 1 {
+		t.Errorf("origin rejections counted = %v, want 1", got)
+	}
+}
+
+func TestAuthRejectHidesDetail(t *testing.T) {
+	key := []byte("secret")
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Auth:      &BearerAuth{Sources: DefaultTokenSources(), Verifier: &JWTVerifier{Key: key}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	expired := signAlg(t, key, "HS256", map[string]any{"sub": "alice", "exp": 1})
+	for _, token := range []string{"", "garbage", expired, expired[:len(expired)-2]} {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL(ts, "/ws?token="+token), nil)
+		if err == nil || resp == nil {
+			t.Fatalf("token %q: dial succeeded", token)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// причина отказа остаётся в логе сервера, клиенту — только 401.
+		if resp.StatusCode != http.StatusUnauthorized || strings.TrimSpace(string(body)) != "unauthorized" {
+			t.Errorf("token %q: %d %q", token, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// HeartbeatConfig — настройки серверного ping/pong.
+type HeartbeatConfig struct {
+	// PingInterval — период отправки ping. 0 отключает heartbeat.
+	PingInterval time.Duration
+	// WriteWait — дедлайн на запись одного кадра.
+	// 0 — DefaultHeartbeat.WriteWait.
+	WriteWait time.Duration
+	// MaxMissedPongs — сколько ping подряд могут остаться без ответа,
+	// прежде чем соединение будет признано мёртвым.
+	// 0 — DefaultHeartbeat.MaxMissedPongs.
+	MaxMissedPongs int
+}
+
+// DefaultHeartbeat — разумные значения для большинства балансировщиков,
+// у которых idle‑таймаут 60 секунд и больше.
+var DefaultHeartbeat = HeartbeatConfig{
+	PingInterval:   30 * time.Second,
+	WriteWait:      10 * time.Second,
+	MaxMissedPongs: 2,
+}
+
+func (hb HeartbeatConfig) enabled() bool { return hb.PingInterval > 0 }
+
+// writeWait — дедлайн записи; без него ping с дедлайном «сейчас»
+// не уходил бы никогда.
+func (hb HeartbeatConfig) writeWait() time.Duration {
+	if hb.WriteWait <= 0 {
+		return DefaultHeartbeat.WriteWait
+	}
+	return hb.WriteWait
+}
+
+// maxMissedPongs — допустимое число ping без ответа; при нуле клиента
+// снимал бы уже первый тик.
+func (hb HeartbeatConfig) maxMissedPongs() int {
+	if hb.MaxMissedPongs <= 0 {
+		return DefaultHeartbeat.MaxMissedPongs
+	}
+	return hb.MaxMissedPongs
+}
+
+// readTimeout — сколько ждать любой кадр от клиента. Чуть больше окна,
+// за которое writer успевает отправить MaxMissedPongs+1 ping, чтобы
+// мёртвое соединение чаще снималось по счётчику pong, а не по дедлайну.
+func (hb HeartbeatConfig) readTimeout() time.Duration {
+	return hb.PingInterval*time.Duration(hb.maxMissedPongs()+1) + hb.writeWait()
+}
+
+// startHeartbeat настраивает read‑дедлайн и pong‑обработчик соединения.
+func (c *Client) startHeartbeat() {
+	hb := c.hub.cfg.Heartbeat
+	if !hb.enabled() {
+		return
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(hb.readTimeout()))
+	c.conn.SetPongHandler(func(string) error {
+		c.missedPongs.Store(0)
+		return c.conn.SetReadDeadline(time.Now().Add(hb.readTimeout()))
+	})
+}
+
+// ping вызывается writer‑горутиной по тику. Возвращает false, если
+// клиент пропустил слишком много pong или ping не удалось записать.
+func (c *Client) ping() bool {
+	hb := c.hub.cfg.Heartbeat
+
+	if int(c.missedPongs.Load()) >= hb.maxMissedPongs() {
+		log.Printf("client %s: missed %d pongs, reaping", c.id, hb.maxMissedPongs())
+		c.markReaped()
+		return false
+	}
+	c.missedPongs.Add(1)
+
+	deadline := time.Now().Add(hb.writeWait())
+	if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+		log.Printf("client %s: ping error: %v", c.id, err)
+		return false
+	}
+	return true
+}
+
+// markReaped учитывает клиента в счётчике снятых по heartbeat ровно один раз.
+func (c *Client) markReaped() {
+	if c.reaped.CompareAndSwap(false, true) {
+		c.hub.reaped.Add(1)
+	}
+}
+
+// isTimeout сообщает, что чтение прервано по read‑дедлайну.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
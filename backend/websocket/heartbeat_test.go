@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatDefaults(t *testing.T) {
+	hb := HeartbeatConfig{PingInterval: time.Second}
+	if hb.writeWait() != DefaultHeartbeat.WriteWait || hb.maxMissedPongs() != DefaultHeartbeat.MaxMissedPongs {
+		t.Errorf("zero values: writeWait = %v, maxMissedPongs = %d", hb.writeWait(), hb.maxMissedPongs())
+	}
+	if got, want := hb.readTimeout(), 3*time.Second+DefaultHeartbeat.WriteWait; got != want {
+		t.Errorf("readTimeout = %v, want %v", got, want)
+	}
+	hb = HeartbeatConfig{PingInterval: time.Second, WriteWait: time.Second, MaxMissedPongs: 5}
+	if hb.writeWait() != time.Second || hb.maxMissedPongs() != 5 {
+		t.Errorf("explicit values overridden: %v, %d", hb.writeWait(), hb.maxMissedPongs())
+	}
+}
+
+func TestHeartbeatReap(t *testing.T) {
+	// WriteWait и MaxMissedPongs не заданы: работают значения по умолчанию.
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Hub:       HubConfig{Heartbeat: HeartbeatConfig{PingInterval: 20 * time.Millisecond}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	// живой клиент читает соединение, и gorilla сама отвечает pong.
+	alive := dial(t, wsURL(ts, "/ws"), nil)
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	// молчащий клиент не читает, поэтому pong не отправляет.
+	dial(t, wsURL(ts, "/ws"), nil)
+
+	waitFor(t, "silent client reaped", func() bool { return srv.Hub().Reaped() == 1 })
+	waitFor(t, "one client left", func() bool { return srv.Hub().Len() == 1 })
+
+	// несколько интервалов спустя живой клиент всё ещё на месте.
+	time.Sleep(200 * time.Millisecond)
+	if srv.Hub().Len() != 1 || srv.Hub().Reaped() != 1 {
+		t.Errorf("Len = %d, Reaped = %d, want 1 and 1", srv.Hub().Len(), srv.Hub().Reaped())
+	}
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
+)
+
+// размер исходящего буфера одного клиента (в сообщениях).
+const sendBufferSize = 256
+
+// дедлайн на запись close‑кадра.
+const closeWriteWait = time.Second
+
+// outbound — кадр, ожидающий отправки writer‑горутиной.
+type outbound struct {
+	mt   int
+	data []byte
+	// room — комната рассылки, по ней выбирается политика сжатия;
+	// пусто для личных сообщений.
+	room string
+	// proto — общий кэш перекодирования рассылки для клиентов proto.v1.
+	proto *transcoded
+}
+
+// Client — одно WebSocket‑соединение, зарегистрированное в хабе.
+// Писать в conn может только writePump: gorilla/websocket не допускает
+// конкурентных вызовов WriteMessage. У клиентов HTTP‑транспортов conn
+// nil, а очередь send разбирает обработчик SSE или long-poll.
+type Client struct {
+	id   string
+	hub  *Hub
+	conn *websocket.Conn
+	send chan outbound
+
+	// principal — владелец соединения; nil для анонимных.
+	principal *Principal
+	// codec — формат конвертов, согласованный через подпротокол.
+	codec codec
+	// transport, remoteAddr и connectedAt нужны admin API.
+	transport   string
+	remoteAddr  string
+	connectedAt time.Time
+	// session задаётся один раз до входа в комнаты; nil без сессий.
+	session *Session
+
+	// rooms защищено hub.mu.
+	rooms map[string]struct{}
+
+	// sendMu сериализует вытеснение при DropOldest.
+	sendMu  sync.Mutex
+	dropped atomic.Uint64
+	shed    atomic.Bool
+
+	missedPongs atomic.Int32
+	reaped      atomic.Bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ID возвращает идентификатор соединения внутри хаба.
+func (c *Client) ID() string { return c.id }
+
+// Principal возвращает аутентифицированного владельца соединения.
+func (c *Client) Principal() *Principal { return c.principal }
+
+// Send ставит кадр в очередь на отправку. Возвращает false, если клиент
+// уже отключён или сообщение отброшено политикой переполнения.
+func (c *Client) Send(mt int, data []byte) bool {
+	return c.deliver(outbound{mt: mt, data: data})
+}
+
+func (c *Client) deliver(msg outbound) bool {
+	if msg.mt == websocket.TextMessage && c.codec.frameType() == websocket.BinaryMessage {
+		var ok bool
+		if msg, ok = c.hub.toProto(msg); !ok {
+			return false
+		}
+	}
+
+	// сессия нумерует кадр и буферизует его даже после обрыва.
+	if c.session != nil {
+		return c.session.deliver(c, msg)
+	}
+
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	return c.enqueue(msg)
+}
+
+// Close отключает клиента: останавливает writer и закрывает сокет.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	})
+}
+
+// CloseWith отправляет close‑кадр с кодом и причиной и закрывает соединение.
+func (c *Client) CloseWith(code int, reason string) {
+	if err := c.sendCloseFrame(code, reason); err != nil {
+		log.Printf("client %s: close frame error: %v", c.id, err)
+	}
+	c.Close()
+}
+
+// sendCloseFrame пишет close‑кадр, не закрывая сокет.
+// WriteControl в gorilla/websocket безопасен параллельно с writePump.
+func (c *Client) sendCloseFrame(code int, reason string) error {
+	// у HTTP‑транспортов close‑кадра нет: поток завершает закрытие клиента.
+	if c.conn == nil {
+		c.hub.cfg.Metrics.observeClose("server", code)
+		c.recordClose(wsrecord.Out, code, reason)
+		c.Close()
+		return nil
+	}
+
+	deadline := time.Now().Add(closeWriteWait)
+	msg := websocket.FormatCloseMessage(code, reason)
+	err := c.conn.WriteControl(websocket.CloseMessage, msg, deadline)
+	if err == nil {
+		c.hub.cfg.Metrics.observeClose("server", code)
+		c.recordClose(wsrecord.Out, code, reason)
+	}
+	if err == websocket.ErrCloseSent {
+		return nil
+	}
+	return err
+}
+
+// writePump — единственная горутина, которая пишет в conn.
+// Она же по тику отправляет ping, если heartbeat включён.
+func (c *Client) writePump() {
+	defer c.Close()
+
+	hb := c.hub.cfg.Heartbeat
+	var tick <-chan time.Time
+	if hb.enabled() {
+		ticker := time.NewTicker(hb.PingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(hb.writeWait()))
+			start := time.Now()
+			if err := c.write(msg); err != nil {
+				log.Printf("client %s: write error: %v", c.id, err)
+				return
+			}
+			c.hub.cfg.Metrics.observeWrite(time.Since(start))
+			c.hub.cfg.Metrics.observeMessage("out", msg.mt, msg.data)
+			c.record(wsrecord.Out, msg.mt, msg.data)
+		case <-tick:
+			if !c.ping() {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// write отправляет кадр, решая по политике, сжимать ли его, и учитывает
+// байты на проводе в статистике сжатия.
+func (c *Client) write(msg outbound) error {
+	wire, _ := c.conn.NetConn().(*wireConn)
+	compress := wire != nil && wire.deflate &&
+		c.hub.cfg.Compression.shouldCompress(msg.room, len(msg.data))
+	c.conn.EnableWriteCompression(compress)
+
+	var before uint64
+	if wire != nil {
+		before = wire.written.Load()
+	}
+	if err := c.conn.WriteMessage(msg.mt, msg.data); err != nil {
+		return err
+	}
+	if wire != nil {
+		// close‑кадр из другой горутины может вклиниться между замерами;
+		// на статистику это влияет в пределах нескольких байт.
+		sent := int(wire.written.Load() - before)
+		if compress {
+			c.hub.compressed.observe(len(msg.data), sent)
+		} else {
+			c.hub.uncompressed.observe(len(msg.data), sent)
+		}
+	}
+	return nil
+}
+
+// readPump читает кадры и передаёт их в onMessage, пока соединение живо.
+// При выходе клиент снимается с регистрации в хабе.
+func (c *Client) readPump(onMessage func(mt int, msg []byte)) {
+	defer c.hub.disconnect(c)
+
+	c.startHeartbeat()
+
+	for {
+		mt, msg, err := c.readMessage()
+		if errors.Is(err, errMessageTooBig) {
+			log.Printf("client %s: %v", c.id, err)
+			c.CloseWith(websocket.CloseMessageTooBig, "message too big")
+			return
+		}
+		if err != nil {
+			if isTimeout(err) {
+				log.Printf("client %s: read deadline exceeded, reaping", c.id)
+				c.markReaped()
+				return
+			}
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				c.hub.cfg.Metrics.observeClose("client", closeErr.Code)
+				c.recordClose(wsrecord.In, closeErr.Code, closeErr.Text)
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("client %s: read error: %v", c.id, err)
+			}
+			return
+		}
+		c.hub.cfg.Metrics.observeMessage("in", mt, msg)
+		c.record(wsrecord.In, mt, msg)
+		onMessage(mt, msg)
+	}
+}
+
+// HubConfig — настройки хаба и его клиентов.
+type HubConfig struct {
+	Heartbeat HeartbeatConfig
+	Queue     QueueConfig
+	// Backplane пересылает рассылки между узлами; nil — один процесс.
+	Backplane Backplane
+	// Metrics собирает счётчики сообщений и задержек; может быть nil.
+	Metrics *Metrics
+	// Compression — политика permessage-deflate для исходящих сообщений.
+	Compression CompressionConfig
+	// Recorder пишет весь трафик в файл для wsreplay; nil — без записи.
+	Recorder *wsrecord.Writer
+	// MaxMessageSize — предел входящего сообщения в байтах после распаковки;
+	// при превышении соединение закрывается с кодом 1009.
+	// 0 — DefaultMaxMessageSize, отрицательное значение снимает лимит.
+	MaxMessageSize int64
+}
+
+func (cfg HubConfig) maxMessageSize() int64 {
+	if cfg.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+	return cfg.MaxMessageSize
+}
+
+// Hub хранит подключённых клиентов и их членство в комнатах.
+type Hub struct {
+	cfg HubConfig
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	rooms   map[string]map[*Client]struct{}
+
+	nextID atomic.Uint64
+	reaped atomic.Uint64
+	shed   ShedCounters
+
+	compressed, uncompressed compressionCounters
+	// payloads — Go‑типы рассылок для перекодирования в proto.v1.
+	payloads payloadTypes
+
+	// presence задаётся сервером до первого Register; nil — без присутствия.
+	presence *Presence
+
+	recordFailed atomic.Bool
+}
+
+// NewHub создаёт пустой хаб и подключает его к backplane, если он задан.
+func NewHub(cfg HubConfig) *Hub {
+	h := &Hub{
+		cfg:     cfg,
+		clients: make(map[*Client]struct{}),
+		rooms:   make(map[string]map[*Client]struct{}),
+	}
+	if cfg.Backplane != nil {
+		if err := cfg.Backplane.Start(h.deliverRemote); err != nil {
+			log.Printf("backplane start error: %v", err)
+		}
+	}
+	return h
+}
+
+// Register регистрирует соединение и запускает его writer‑горутину.
+// p может быть nil, если аутентификация не настроена.
+func (h *Hub) Register(conn *websocket.Conn, p *Principal) *Client {
+	c := h.add(conn, p, "websocket", conn.RemoteAddr().String())
+	// несжатые кадры сверх лимита gorilla отбрасывает сама, не читая их.
+	if limit := h.cfg.maxMessageSize(); limit > 0 {
+		conn.SetReadLimit(limit)
+	}
+
+	go c.writePump()
+	return c
+}
+
+// registerStream регистрирует клиента без WebSocket‑соединения: его
+// очередь send разбирает HTTP‑транспорт (SSE или long-poll).
+func (h *Hub) registerStream(p *Principal, transport, remoteAddr string) *Client {
+	return h.add(nil, p, transport, remoteAddr)
+}
+
+func (h *Hub) add(conn *websocket.Conn, p *Principal, transport, remoteAddr string) *Client {
+	// HTTP‑транспорты всегда говорят JSON.
+	subprotocol := ""
+	if conn != nil {
+		subprotocol = conn.Subprotocol()
+	}
+	c := &Client{
+		id:          strconv.FormatUint(h.nextID.Add(1), 10),
+		hub:         h,
+		conn:        conn,
+		send:        make(chan outbound, h.cfg.Queue.size()),
+		principal:   p,
+		codec:       codecFor(subprotocol),
+		transport:   transport,
+		remoteAddr:  remoteAddr,
+		connectedAt: time.Now(),
+		rooms:       make(map[string]struct{}),
+		done:        make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+// Unregister удаляет клиента из всех комнат и закрывает соединение.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+		h.leaveLocked(c, room)
+	}
+	h.mu.Unlock()
+
+	for _, room := range rooms {
+		h.presence.remove(c, room)
+	}
+	c.Close()
+}
+
+// disconnect вызывается при обрыве соединения. Клиент с сессией остаётся
+// в комнатах, чтобы рассылки копились в буфере докачки до resume или TTL.
+func (h *Hub) disconnect(c *Client) {
+	if c.session == nil || !c.session.detach(c) {
+		h.Unregister(c)
+		return
+	}
+
+	h.mu.Lock()
+	delete(h.clients, c)
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+
+	// для присутствия ждущее resume соединение уже не устройство.
+	for _, room := range rooms {
+		h.presence.remove(c, room)
+	}
+	c.Close()
+}
+
+// transfer переносит членство в комнатах со старого клиента на новый и
+// возвращает перенесённые комнаты. Присутствие не трогает: announce идёт
+// через Broadcast, а transfer вызывают под блокировкой сессии — после неё
+// вызывающий обязан сделать movePresence.
+func (h *Hub) transfer(from, to *Client) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// комнату не удаляем и не пересоздаём, чтобы не дёргать подписку backplane.
+	rooms := make([]string, 0, len(from.rooms))
+	for room := range from.rooms {
+		rooms = append(rooms, room)
+		members := h.rooms[room]
+		delete(members, from)
+		delete(from.rooms, room)
+		members[to] = struct{}{}
+		to.rooms[room] = struct{}{}
+	}
+	return rooms
+}
+
+// movePresence переносит присутствие в rooms со старого клиента на новый.
+func (h *Hub) movePresence(from, to *Client, rooms []string) {
+	// сначала новое устройство, потом старое: иначе мелькнёт offline.
+	for _, room := range rooms {
+		h.presence.add(to, room)
+		h.presence.remove(from, room)
+	}
+}
+
+// Join добавляет клиента в комнату. Комната создаётся при первом входе.
+func (h *Hub) Join(c *Client, room string) {
+	h.mu.Lock()
+	if _, ok := h.clients[c]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[*Client]struct{})
+		h.rooms[room] = members
+		h.subscribe(room)
+	}
+	members[c] = struct{}{}
+	c.rooms[room] = struct{}{}
+	h.mu.Unlock()
+
+	h.presence.add(c, room)
+}
+
+// Leave убирает клиента из комнаты. Пустая комната удаляется.
+func (h *Hub) Leave(c *Client, room string) {
+	h.mu.Lock()
+	h.leaveLocked(c, room)
+	h.mu.Unlock()
+
+	h.presence.remove(c, room)
+}
+
+func (h *Hub) leaveLocked(c *Client, room string) {
+	delete(c.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+			h.unsubscribe(room)
+		}
+	}
+}
+
+// Rooms возвращает комнаты, в которых состоит клиент.
+func (h *Hub) Rooms(c *Client) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// InRoom сообщает, состоит ли клиент в комнате.
+func (h *Hub) InRoom(c *Client, room string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, ok := c.rooms[room]
+	return ok
+}
+
+// Broadcast рассылает кадр всем участникам комнаты, в том числе на других
+// узлах через backplane. Возвращает число локальных клиентов, в чью
+// очередь сообщение было поставлено.
+func (h *Hub) Broadcast(room string, mt int, data []byte) int {
+	if bp := h.cfg.Backplane; bp != nil {
+		if err := bp.Publish(BackplaneMessage{Room: room, Type: mt, Data: data}); err != nil {
+			log.Printf("backplane publish to %q: %v", room, err)
+		}
+	}
+	return h.broadcastLocal(room, mt, data)
+}
+
+// deliverRemote доставляет рассылку с другого узла только локальным клиентам.
+func (h *Hub) deliverRemote(msg BackplaneMessage) {
+	h.broadcastLocal(msg.Room, msg.Type, msg.Data)
+}
+
+// subscribe и unsubscribe вызываются под h.mu; реализации Backplane
+// обязаны не блокироваться в них.
+func (h *Hub) subscribe(room string) {
+	if bp := h.cfg.Backplane; bp != nil {
+		if err := bp.Subscribe(room); err != nil {
+			log.Printf("backplane subscribe %q: %v", room, err)
+		}
+	}
+}
+
+func (h *Hub) unsubscribe(room string) {
+	if bp := h.cfg.Backplane; bp != nil {
+		if err := bp.Unsubscribe(room); err != nil {
+			log.Printf("backplane unsubscribe %q: %v", room, err)
+		}
+	}
+}
+
+func (h *Hub) broadcastLocal(room string, mt int, data []byte) int {
+	h.mu.RLock()
+	members := make([]*Client, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] {
+		members = append(members, c)
+	}
+	h.mu.RUnlock()
+
+	shared := new(transcoded)
+	delivered := 0
+	for _, c := range members {
+		if c.deliver(outbound{mt: mt, data: data, room: room, proto: shared}) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// Len возвращает количество подключённых клиентов.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients)
+}
+
+// Reaped возвращает, сколько соединений было снято по heartbeat
+// (пропущенные pong или истёкший read‑дедлайн) за время жизни хаба.
+func (h *Hub) Reaped() uint64 {
+	return h.reaped.Load()
+}
+
+// Shed возвращает счётчики политик переполнения очередей.
+func (h *Hub) Shed() ShedStats {
+	return h.shed.snapshot()
+}
+
+// Compression возвращает статистику исходящих сообщений: отдельно
+// сжатых и отправленных как есть.
+func (h *Hub) Compression() (compressed, uncompressed CompressionStats) {
+	return h.compressed.snapshot(), h.uncompressed.snapshot()
+}
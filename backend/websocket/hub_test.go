@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsURL — адрес path тестового сервера со схемой ws://.
+func wsURL(ts *httptest.Server, path string) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + path
+}
+
+// dial подключается к серверу и закрывает соединение в конце теста.
+func dial(t *testing.T, url string, header http.Header) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readText читает следующий кадр с таймаутом в секунду.
+func readText(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(msg)
+}
+
+// waitFor ждёт, пока cond станет истинным, не дольше секунды.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// queued забирает из очереди клиента без writer‑горутины всё, что там есть.
+func queued(c *Client) []string {
+	var out []string
+	for {
+		select {
+		case msg := <-c.send:
+			out = append(out, string(msg.data))
+		default:
+			return out
+		}
+	}
+}
+
+func TestHubRooms(t *testing.T) {
+	h := NewHub(HubConfig{})
+	// клиенты без сокета: очередь send читает тест.
+	a := h.registerStream(nil, "test", "a")
+	b := h.registerStream(nil, "test", "b")
+
+	h.Join(a, "r1")
+	h.Join(a, "r2")
+	h.Join(b, "r1")
+	rooms := h.Rooms(a)
+	slices.Sort(rooms)
+	if !slices.Equal(rooms, []string{"r1", "r2"}) || !h.InRoom(b, "r1") || h.InRoom(b, "r2") {
+		t.Fatalf("rooms: a=%v, b in r1=%v, b in r2=%v", rooms, h.InRoom(b, "r1"), h.InRoom(b, "r2"))
+	}
+
+	if n := h.Broadcast("r1", websocket.TextMessage, []byte("one")); n != 2 {
+		t.Errorf("Broadcast(r1) delivered to %d, want 2", n)
+	}
+	if n := h.Broadcast("r2", websocket.TextMessage, []byte("two")); n != 1 {
+		t.Errorf("Broadcast(r2) delivered to %d, want 1", n)
+	}
+	if n := h.Broadcast("nobody", websocket.TextMessage, []byte("x")); n != 0 {
+		t.Errorf("Broadcast to an unknown room delivered to %d", n)
+	}
+	if got := queued(a); !slices.Equal(got, []string{"one", "two"}) {
+		t.Errorf("a got %q", got)
+	}
+	if got := queued(b); !slices.Equal(got, []string{"one"}) {
+		t.Errorf("b got %q", got)
+	}
+
+	// пустая комната удаляется.
+	h.Leave(a, "r2")
+	if _, ok := h.rooms["r2"]; ok || h.InRoom(a, "r2") {
+		t.Error("empty room r2 was not removed")
+	}
+
+	h.Unregister(b)
+	if h.Len() != 1 || h.InRoom(b, "r1") {
+		t.Errorf("after Unregister: Len = %d, b in r1 = %v", h.Len(), h.InRoom(b, "r1"))
+	}
+	if b.Send(websocket.TextMessage, []byte("late")) {
+		t.Error("Send to an unregistered client succeeded")
+	}
+	// в комнату, где никого нет, отключённого клиента не вернуть.
+	h.Join(b, "r1")
+	if h.InRoom(b, "r1") {
+		t.Error("unregistered client joined a room")
+	}
+}
+
+func TestHubWebSocket(t *testing.T) {
+	srv := NewServer(Config{Handshake: HandshakePolicy{AllowMissingOrigin: true}})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	a := dial(t, wsURL(ts, "/ws?room=news&room=sport"), nil)
+	b := dial(t, wsURL(ts, "/ws?room=news"), nil)
+	waitFor(t, "two clients", func() bool { return srv.Hub().Len() == 2 })
+
+	// рассылку пишет writer‑горутина каждого клиента.
+	if n := srv.Hub().Broadcast("news", websocket.TextMessage, []byte("hello")); n != 2 {
+		t.Fatalf("delivered to %d, want 2", n)
+	}
+	srv.Hub().Broadcast("sport", websocket.TextMessage, []byte("goal"))
+	if got := readText(t, a); got != "hello" {
+		t.Errorf("a got %q", got)
+	}
+	if got := readText(t, a); got != "goal" {
+		t.Errorf("a got %q", got)
+	}
+	if got := readText(t, b); got != "hello" {
+		t.Errorf("b got %q", got)
+	}
+
+	// обрыв снимает клиента с регистрации.
+	b.Close()
+	waitFor(t, "unregister", func() bool { return srv.Hub().Len() == 1 })
+	if n := srv.Hub().Broadcast("news", websocket.TextMessage, []byte("bye")); n != 1 {
+		t.Errorf("after disconnect delivered to %d, want 1", n)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	srv := NewServer(Config{Handshake: HandshakePolicy{AllowMissingOrigin: true}})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn := dial(t, wsURL(ts, "/echo"), nil)
+	for _, msg := range []string{"ping", "привет"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if got := readText(t, conn); got != msg {
+			t.Errorf("echo = %q, want %q", got, msg)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken = errors.New("jwt: malformed token")
+	ErrBadSignature   = errors.New("jwt: signature mismatch")
+	ErrTokenExpired   = errors.New("jwt: token expired")
+)
+
+// JWTVerifier проверяет HMAC‑подписанные JWT (HS256/HS384/HS512)
+// локальным секретом, без обращения к внешнему IdP.
+type JWTVerifier struct {
+	Key []byte
+	// Algorithms — допустимые alg; пусто означает только HS256.
+	// "none" не поддерживается никогда.
+	Algorithms []string
+	Issuer     string // если задан, iss обязан совпасть
+	Audience   string // если задан, должен входить в aud
+	// Leeway — допуск рассинхронизации часов для exp/nbf.
+	Leeway time.Duration
+
+	now func() time.Time // для тестов
+}
+
+// jwtClaims — зарегистрированные claims, которые нас интересуют.
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	Roles     []string `json:"roles"`
+}
+
+// audience — aud может быть строкой или массивом строк.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	newHash, err := v.hashFor(header.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	mac := hmac.New(newHash, v.Key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrBadSignature
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, err
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Roles:   claims.Roles,
+		Claims:  raw,
+	}, nil
+}
+
+func (v *JWTVerifier) hashFor(alg string) (func() hash.Hash, error) {
+	allowed := v.Algorithms
+	if len(allowed) == 0 {
+		allowed = []string{"HS256"}
+	}
+	if !slices.Contains(allowed, alg) {
+		return nil, fmt.Errorf("jwt: algorithm %q not allowed", alg)
+	}
+
+	switch alg {
+	case "HS256":
+		return sha256.New, nil
+	case "HS384":
+		return sha512.New384, nil
+	case "HS512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", alg)
+	}
+}
+
+func (v *JWTVerifier) validate(c jwtClaims) error {
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	t := now()
+
+	if c.ExpiresAt != nil && t.After(time.Unix(*c.ExpiresAt, 0).Add(v.Leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != nil && t.Add(v.Leeway).Before(time.Unix(*c.NotBefore, 0)) {
+		return errors.New("jwt: token not valid yet")
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return errors.New("jwt: unexpected issuer")
+	}
+	if v.Audience != "" && !slices.Contains(c.Audience, v.Audience) {
+		return errors.New("jwt: unexpected audience")
+	}
+	if c.Subject == "" {
+		return errors.New("jwt: missing sub")
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrMalformedToken
+	}
+	return nil
+}
+
+// SignJWT выпускает HS256‑токен. Нужен для тестов и локальной отладки,
+// в проде токены выдаёт сервис авторизации.
+func SignJWT(key []byte, claims map[string]any) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	body := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
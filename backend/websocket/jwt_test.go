@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signAlg выпускает токен с произвольным alg; для none подпись пустая.
+func signAlg(t *testing.T, key []byte, alg string, claims map[string]any) string {
+	t.Helper()
+
+	if alg == "HS256" {
+		tok, err := SignJWT(key, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if alg == "none" {
+		return body + "."
+	}
+	mac := hmac.New(sha512.New384, key)
+	mac.Write([]byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTVerifier(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1_700_000_000, 0)
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"sub": "alice", "iss": "auth", "roles": []string{"admin"}}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		verify  JWTVerifier
+		token   func() string
+		wantErr string // пусто — токен валиден
+	}{
+		{"valid", JWTVerifier{}, func() string { return signAlg(t, key, "HS256", claims(nil)) }, ""},
+		{"tampered payload", JWTVerifier{}, func() string {
+			tok := signAlg(t, key, "HS256", claims(nil))
+			parts := strings.Split(tok, ".")
+			forged := signAlg(t, key, "HS256", claims(map[string]any{"sub": "mallory"}))
+			return parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+		}, ErrBadSignature.Error()},
+		{"wrong key", JWTVerifier{}, func() string { return signAlg(t, []byte("other"), "HS256", claims(nil)) }, ErrBadSignature.Error()},
+		{"alg none", JWTVerifier{Algorithms: []string{"none", "HS256"}}, func() string { return signAlg(t, key, "none", claims(nil)) }, `unsupported algorithm "none"`},
+		{"alg none by default", JWTVerifier{}, func() string { return signAlg(t, key, "none", claims(nil)) }, `algorithm "none" not allowed`},
+		{"disallowed alg", JWTVerifier{}, func() string { return signAlg(t, key, "HS384", claims(nil)) }, `algorithm "HS384" not allowed`},
+		{"allowed HS384", JWTVerifier{Algorithms: []string{"HS384"}}, func() string { return signAlg(t, key, "HS384", claims(nil)) }, ""},
+		{"malformed", JWTVerifier{}, func() string { return "a.b" }, ErrMalformedToken.Error()},
+		{"expired", JWTVerifier{}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"exp": at(-time.Minute)}))
+		}, ErrTokenExpired.Error()},
+		{"expired within leeway", JWTVerifier{Leeway: 2 * time.Minute}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"exp": at(-time.Minute)}))
+		}, ""},
+		{"not yet valid", JWTVerifier{}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"nbf": at(time.Minute)}))
+		}, "not valid yet"},
+		{"nbf within leeway", JWTVerifier{Leeway: 2 * time.Minute}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"nbf": at(time.Minute)}))
+		}, ""},
+		{"wrong issuer", JWTVerifier{Issuer: "other"}, func() string { return signAlg(t, key, "HS256", claims(nil)) }, "unexpected issuer"},
+		{"right issuer", JWTVerifier{Issuer: "auth"}, func() string { return signAlg(t, key, "HS256", claims(nil)) }, ""},
+		{"aud as string", JWTVerifier{Audience: "ws"}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"aud": "ws"}))
+		}, ""},
+		{"aud as array", JWTVerifier{Audience: "ws"}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"aud": []string{"api", "ws"}}))
+		}, ""},
+		{"aud mismatch", JWTVerifier{Audience: "ws"}, func() string {
+			return signAlg(t, key, "HS256", claims(map[string]any{"aud": []string{"api"}}))
+		}, "unexpected audience"},
+		{"aud missing", JWTVerifier{Audience: "ws"}, func() string { return signAlg(t, key, "HS256", claims(nil)) }, "unexpected audience"},
+		{"missing sub", JWTVerifier{}, func() string {
+			return signAlg(t, key, "HS256", map[string]any{"iss": "auth"})
+		}, "missing sub"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.verify
+			v.Key = key
+			v.now = func() time.Time { return now }
+
+			p, err := v.Verify(tt.token())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if p.Subject != "alice" || !slices.Equal(p.Roles, []string{"admin"}) || p.Claims["iss"] != "auth" {
+					t.Errorf("principal = %+v", p)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// сигнальные ошибки различимы через errors.Is.
+	v := JWTVerifier{Key: key, now: func() time.Time { return now }}
+	if _, err := v.Verify(signAlg(t, key, "HS256", claims(map[string]any{"exp": at(-time.Hour)}))); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Минимальная реализация Prometheus text exposition format (0.0.4) без
+// зависимости от client_golang: счётчики с метками, гистограмма и gauge
+// через функцию. Для одного сервиса этого достаточно.
+
+// границы гистограммы задержки записи, в секундах.
+var writeLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// counterVec — счётчик с фиксированным набором меток.
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64 // ключ — значения меток через \xff
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+func (c *counterVec) inc(labelValues ...string) { c.add(1, labelValues...) }
+
+// get возвращает текущее значение; нужно тестам и Stats.
+func (c *counterVec) get(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, strings.Split(k, "\xff")), formatFloat(c.values[k]))
+	}
+	c.mu.Unlock()
+}
+
+// histogram — гистограмма без меток.
+type histogram struct {
+	name, help string
+	bounds     []float64
+
+	mu     sync.Mutex
+	counts []uint64 // не кумулятивные, по одному на границу + +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(name, help string, bounds []float64) *histogram {
+	return &histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+func (h *histogram) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(bound), cumulative)
+	}
+	cumulative += h.counts[len(h.bounds)]
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, cumulative)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+// gaugeFunc — значение, вычисляемое в момент скрейпа.
+type gaugeFunc struct {
+	name, help, kind string
+	fn               func() float64
+}
+
+func (g gaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", g.name, g.help, g.name, g.kind, g.name, formatFloat(g.fn()))
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// Metrics — метрики WebSocket‑сервера. Все методы безопасны для nil,
+// чтобы хаб можно было использовать и без метрик.
+type Metrics struct {
+	upgrades     *counterVec
+	rejected     *counterVec
+	messages     *counterVec
+	bytes        *counterVec
+	closes       *counterVec
+	limited      *counterVec
+	writeLatency *histogram
+
+	mu sync.RWMutex
+	// knownTypes ограничивает значения метки type, иначе клиент мог бы
+	// раздуть кардинальность произвольными типами сообщений.
+	knownTypes map[string]struct{}
+	gauges     []gaugeFunc
+}
+
+// NewMetrics создаёт пустой набор метрик.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		upgrades:     newCounterVec("ws_upgrades_accepted_total", "WebSocket upgrades accepted."),
+		rejected:     newCounterVec("ws_upgrades_rejected_total", "WebSocket upgrades rejected, by reason.", "reason"),
+		messages:     newCounterVec("ws_messages_total", "WebSocket messages, by direction and message type.", "direction", "type"),
+		bytes:        newCounterVec("ws_bytes_total", "WebSocket payload bytes, by direction and message type.", "direction", "type"),
+		closes:       newCounterVec("ws_close_codes_total", "Close frames, by side that initiated and close code.", "initiator", "code"),
+		limited:      newCounterVec("ws_rate_limited_total", "Inbound messages over rate limit, by scope and action taken.", "scope", "action"),
+		writeLatency: newHistogram("ws_write_duration_seconds", "Time spent writing one frame to the socket.", writeLatencyBuckets),
+		knownTypes:   make(map[string]struct{}),
+	}
+	// счётчик без меток виден в скрейпе сразу, а не после первого апгрейда.
+	m.upgrades.add(0)
+	return m
+}
+
+// RegisterType разрешает использовать тип сообщения как значение метки.
+func (m *Metrics) RegisterType(types ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range types {
+		m.knownTypes[t] = struct{}{}
+	}
+}
+
+// Gauge добавляет значение, вычисляемое при каждом скрейпе.
+func (m *Metrics) Gauge(name, help string, fn func() float64) {
+	m.addFunc(gaugeFunc{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// CounterFunc добавляет счётчик, значение которого хранится в другом месте.
+func (m *Metrics) CounterFunc(name, help string, fn func() float64) {
+	m.addFunc(gaugeFunc{name: name, help: help, kind: "counter", fn: fn})
+}
+
+func (m *Metrics) addFunc(g gaugeFunc) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.gauges = append(m.gauges, g)
+	m.mu.Unlock()
+}
+
+func (m *Metrics) upgradeAccepted() {
+	if m != nil {
+		m.upgrades.inc()
+	}
+}
+
+func (m *Metrics) upgradeRejected(reason string) {
+	if m != nil {
+		m.rejected.inc(reason)
+	}
+}
+
+func (m *Metrics) observeMessage(direction string, mt int, data []byte) {
+	if m == nil {
+		return
+	}
+	typ := m.messageType(mt, data)
+	m.messages.inc(direction, typ)
+	m.bytes.add(float64(len(data)), direction, typ)
+}
+
+func (m *Metrics) observeWrite(d time.Duration) {
+	if m != nil {
+		m.writeLatency.observe(d.Seconds())
+	}
+}
+
+func (m *Metrics) observeClose(initiator string, code int) {
+	if m != nil {
+		m.closes.inc(initiator, strconv.Itoa(code))
+	}
+}
+
+func (m *Metrics) rateLimited(scope string, action LimitAction) {
+	if m != nil {
+		m.limited.inc(scope, action.String())
+	}
+}
+
+// messageType достаёт type из конверта JSON или protobuf. Незарегистрированные
+// типы и бинарные кадры не‑конверты сводятся к общим меткам.
+func (m *Metrics) messageType(mt int, data []byte) string {
+	var typ string
+	var ok bool
+	if mt == websocket.BinaryMessage {
+		if typ, ok = protoEnvelopeType(data); !ok {
+			return "binary"
+		}
+	} else if typ, ok = sniffType(data); !ok {
+		return "raw"
+	}
+
+	m.mu.RLock()
+	_, known := m.knownTypes[typ]
+	m.mu.RUnlock()
+	if !known {
+		return "other"
+	}
+	return typ
+}
+
+// sniffType ищет "type":"..." в начале конверта (seq может идти раньше).
+func sniffType(data []byte) (string, bool) {
+	const key = `"type":"`
+	head := data[:min(len(data), 64)]
+	i := bytes.Index(head, []byte(key))
+	if i < 0 {
+		return "", false
+	}
+	rest := data[i+len(key):]
+	j := bytes.IndexByte(rest, '"')
+	if j < 0 {
+		return "", false
+	}
+	return string(rest[:j]), true
+}
+
+// WriteTo пишет все метрики в text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	m.mu.RLock()
+	gauges := append([]gaugeFunc(nil), m.gauges...)
+	m.mu.RUnlock()
+	for _, g := range gauges {
+		g.write(&buf)
+	}
+
+	m.upgrades.write(&buf)
+	m.rejected.write(&buf)
+	m.messages.write(&buf)
+	m.bytes.write(&buf)
+	m.closes.write(&buf)
+	m.limited.write(&buf)
+	m.writeLatency.write(&buf)
+
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP отдаёт метрики для скрейпа Prometheus.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// policyRejectReason сводит отказ HandshakePolicy к значению метки reason.
+func policyRejectReason(err error) string {
+	var herr *HandshakeError
+	if !errors.As(err, &herr) {
+		return "policy"
+	}
+	switch herr.Reason {
+	case ReasonOrigin:
+		return "origin"
+	case ReasonSubprotocol:
+		return "subprotocol"
+	default:
+		return "policy"
+	}
+}
+
+// registerMetrics подключает к метрикам состояние хаба и известные типы.
+func (s *Server) registerMetrics() {
+	m := s.cfg.Metrics
+
+	m.RegisterType(TypeAck, TypeError, TypeMessage, TypeSession, TypeRateLimited, TypePresence, TypeSystem)
+	s.router.mu.RLock()
+	for typ := range s.router.handlers {
+		m.RegisterType(typ)
+	}
+	s.router.mu.RUnlock()
+
+	m.Gauge("ws_active_connections", "Open WebSocket connections.", func() float64 {
+		return float64(s.hub.Len())
+	})
+	m.CounterFunc("ws_reaped_total", "Connections reaped by heartbeat.", func() float64 {
+		return float64(s.hub.Reaped())
+	})
+	m.CounterFunc("ws_shed_dropped_newest_total", "Messages dropped by drop_newest overflow policy.", func() float64 {
+		return float64(s.hub.Shed().DroppedNewest)
+	})
+	m.CounterFunc("ws_shed_dropped_oldest_total", "Messages dropped by drop_oldest overflow policy.", func() float64 {
+		return float64(s.hub.Shed().DroppedOldest)
+	})
+	m.CounterFunc("ws_shed_disconnected_total", "Clients disconnected by overflow policy.", func() float64 {
+		return float64(s.hub.Shed().Disconnected)
+	})
+	m.CounterFunc("ws_compressed_payload_bytes_total", "Payload bytes of outbound messages sent compressed.", func() float64 {
+		c, _ := s.hub.Compression()
+		return float64(c.PayloadBytes)
+	})
+	m.CounterFunc("ws_compressed_wire_bytes_total", "Bytes on the wire for outbound messages sent compressed.", func() float64 {
+		c, _ := s.hub.Compression()
+		return float64(c.WireBytes)
+	})
+	if s.sessions != nil {
+		m.Gauge("ws_sessions", "Resumable sessions, including detached ones.", func() float64 {
+			return float64(s.sessions.Len())
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// scrape забирает /metrics и возвращает значения по полному имени серии,
+// например `ws_messages_total{direction="in",type="publish"}`.
+func scrape(t *testing.T, url string) map[string]float64 {
+	t.Helper()
+
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	series := make(map[string]float64)
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample %q: %v", line, err)
+		}
+		series[line[:i]] = v
+	}
+	return series
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowedOrigins: []string{"https://app.example.com"}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=r"
+
+	// отказ по Origin
+	_, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://evil.example.org"}})
+	if err == nil {
+		t.Fatal("expected origin rejection")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://app.example.com"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	msg := `{"type":"publish","id":"1","payload":{"room":"r","data":"hi"}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	// рассылка самому себе и ack
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]float64{
+		`ws_active_connections`:                             1,
+		`ws_upgrades_accepted_total`:                        1,
+		`ws_upgrades_rejected_total{reason="origin"}`:       1,
+		`ws_messages_total{direction="in",type="publish"}`:  1,
+		`ws_bytes_total{direction="in",type="publish"}`:     float64(len(msg)),
+		`ws_messages_total{direction="out",type="message"}`: 1,
+		`ws_messages_total{direction="out",type="ack"}`:     1,
+		`ws_write_duration_seconds_count`:                   2,
+		`ws_write_duration_seconds_bucket{le="+Inf"}`:       2,
+	}
+	// счётчики записи обновляются после WriteMessage, поэтому клиент может
+	// прочитать кадр чуть раньше, чем они изменятся.
+	var got map[string]float64
+	for deadline := time.Now().Add(time.Second); ; {
+		got = scrape(t, ts.URL)
+		if matches(got, want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+	conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for (srv.Metrics().closes.get("client", "1000") == 0 || srv.Hub().Len() > 0) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got = scrape(t, ts.URL)
+	if got[`ws_close_codes_total{initiator="client",code="1000"}`] != 1 {
+		t.Errorf("close code 1000 not counted: %v", got)
+	}
+	if got[`ws_active_connections`] != 0 {
+		t.Errorf("ws_active_connections = %v after close", got[`ws_active_connections`])
+	}
+}
+
+func matches(got, want map[string]float64) bool {
+	for name, v := range want {
+		if got[name] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSniffType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`{"type":"ack","reply_to":"1"}`, "ack", true},
+		{`{"seq":42,"type":"message","payload":{}}`, "message", true},
+		{`hello`, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := sniffType([]byte(tt.in))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("sniffType(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
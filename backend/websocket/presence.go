@@ -0,0 +1,265 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// типы сообщений присутствия.
+const (
+	// TypePresence — событие сервера: пользователь появился или ушёл.
+	TypePresence = "presence"
+	// TypeWho — запрос списка пользователей в комнате.
+	TypeWho = "who"
+	// TypeTyping — клиент печатает; сервер пересылает его участникам комнаты.
+	TypeTyping = "typing"
+)
+
+// статусы PresenceEvent.
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+// DefaultPresenceGrace — сколько ждать переподключения, прежде чем
+// объявить пользователя ушедшим.
+const DefaultPresenceGrace = 5 * time.Second
+
+// PresenceConfig — настройки присутствия в комнатах.
+type PresenceConfig struct {
+	Enabled bool
+	// Grace — задержка перед offline: переподключение в этот промежуток
+	// не порождает пары leave/join. 0 — DefaultPresenceGrace,
+	// отрицательное значение — offline сразу.
+	Grace time.Duration
+}
+
+func (cfg PresenceConfig) grace() time.Duration {
+	if cfg.Grace == 0 {
+		return DefaultPresenceGrace
+	}
+	return max(cfg.Grace, 0)
+}
+
+// PresenceEvent — payload TypePresence.
+type PresenceEvent struct {
+	Room    string `json:"room" proto:"1"`
+	User    string `json:"user" proto:"2"`
+	Status  string `json:"status" proto:"3"`
+	Devices int    `json:"devices" proto:"4"`
+}
+
+// PresenceUser — пользователь в ответе на who.
+type PresenceUser struct {
+	User string `json:"user" proto:"1"`
+	// Devices — число живых соединений; 0 — пользователь в grace‑периоде.
+	Devices int `json:"devices" proto:"2"`
+}
+
+// WhoResult — ответ на who.
+type WhoResult struct {
+	Room  string         `json:"room" proto:"1"`
+	Users []PresenceUser `json:"users" proto:"2"`
+}
+
+// TypingRequest — payload TypeTyping от клиента.
+type TypingRequest struct {
+	Room   string `json:"room" proto:"1"`
+	Typing bool   `json:"typing" proto:"2"`
+}
+
+// TypingEvent — payload TypeTyping, который получают участники комнаты.
+type TypingEvent struct {
+	Room   string `json:"room" proto:"1"`
+	User   string `json:"user" proto:"2"`
+	Typing bool   `json:"typing" proto:"3"`
+}
+
+// presenceEntry — устройства одного пользователя в одной комнате.
+type presenceEntry struct {
+	devices map[*Client]struct{}
+	// pending — идёт grace‑период; gen отличает актуальный таймер
+	// от сработавшего после переподключения.
+	pending bool
+	gen     uint64
+}
+
+// Presence считает, кто из пользователей в какой комнате. Пользователь
+// онлайн, пока у него есть хотя бы одно живое соединение в комнате.
+// Соединение с сессией, ждущее resume, живым не считается.
+//
+// Счёт ведётся по узлу: с backplane события уходят во все узлы, но
+// пользователь с устройствами на разных узлах может получить offline
+// от одного узла, оставаясь онлайн на другом.
+type Presence struct {
+	hub   *Hub
+	grace time.Duration
+
+	mu    sync.Mutex
+	rooms map[string]map[string]*presenceEntry
+}
+
+func newPresence(hub *Hub, cfg PresenceConfig) *Presence {
+	return &Presence{hub: hub, grace: cfg.grace(), rooms: make(map[string]map[string]*presenceEntry)}
+}
+
+// add учитывает соединение в комнате. Вызывается без блокировки хаба:
+// announce рассылает через него же.
+func (p *Presence) add(c *Client, room string) {
+	if p == nil {
+		return
+	}
+	user := senderName(c)
+
+	p.mu.Lock()
+	users, ok := p.rooms[room]
+	if !ok {
+		users = make(map[string]*presenceEntry)
+		p.rooms[room] = users
+	}
+	e, ok := users[user]
+	if !ok {
+		e = &presenceEntry{devices: make(map[*Client]struct{})}
+		users[user] = e
+	}
+	_, known := e.devices[c]
+	e.devices[c] = struct{}{}
+	// вернулся в grace‑период — для остальных он и не уходил.
+	announce := !known && len(e.devices) == 1 && !e.pending
+	if e.pending {
+		e.pending = false
+		e.gen++
+	}
+	devices := len(e.devices)
+	p.mu.Unlock()
+
+	if announce {
+		p.announce(PresenceEvent{Room: room, User: user, Status: StatusOnline, Devices: devices})
+	}
+}
+
+// remove снимает соединение; повторный вызов безвреден.
+func (p *Presence) remove(c *Client, room string) {
+	if p == nil {
+		return
+	}
+	user := senderName(c)
+
+	p.mu.Lock()
+	e := p.rooms[room][user]
+	if e == nil {
+		p.mu.Unlock()
+		return
+	}
+	if _, ok := e.devices[c]; !ok {
+		p.mu.Unlock()
+		return
+	}
+	delete(e.devices, c)
+	if len(e.devices) > 0 {
+		p.mu.Unlock()
+		return
+	}
+
+	if p.grace == 0 {
+		p.dropLocked(room, user)
+		p.mu.Unlock()
+		p.announce(PresenceEvent{Room: room, User: user, Status: StatusOffline})
+		return
+	}
+
+	e.pending = true
+	e.gen++
+	gen := e.gen
+	p.mu.Unlock()
+
+	time.AfterFunc(p.grace, func() { p.expire(room, user, gen) })
+}
+
+// expire объявляет offline, если за grace‑период никто не вернулся.
+func (p *Presence) expire(room, user string, gen uint64) {
+	p.mu.Lock()
+	e := p.rooms[room][user]
+	if e == nil || !e.pending || e.gen != gen {
+		p.mu.Unlock()
+		return
+	}
+	p.dropLocked(room, user)
+	p.mu.Unlock()
+
+	p.announce(PresenceEvent{Room: room, User: user, Status: StatusOffline})
+}
+
+func (p *Presence) dropLocked(room, user string) {
+	users := p.rooms[room]
+	delete(users, user)
+	if len(users) == 0 {
+		delete(p.rooms, room)
+	}
+}
+
+// Who возвращает пользователей комнаты, включая тех, кто в grace‑периоде.
+func (p *Presence) Who(room string) []PresenceUser {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	users := make([]PresenceUser, 0, len(p.rooms[room]))
+	for user, e := range p.rooms[room] {
+		users = append(users, PresenceUser{User: user, Devices: len(e.devices)})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].User < users[j].User })
+	return users
+}
+
+func (p *Presence) announce(ev PresenceEvent) {
+	data, err := marshalEnvelope(TypePresence, "", ev)
+	if err != nil {
+		log.Printf("presence %s/%s: %v", ev.Room, ev.User, err)
+		return
+	}
+	p.hub.Broadcast(ev.Room, websocket.TextMessage, data)
+}
+
+// registerPresence подключает who и typing к роутеру.
+func (s *Server) registerPresence() {
+	s.router.Handle(TypeWho, s.handleWho)
+	s.router.Handle(TypeTyping, s.handleTyping)
+	s.hub.RegisterPayload(TypePresence, PresenceEvent{})
+	s.hub.RegisterPayload(TypeTyping, TypingEvent{})
+}
+
+func (s *Server) handleWho(req *Request) (any, error) {
+	var in RoomRequest
+	if err := req.Bind(&in); err != nil {
+		return nil, err
+	}
+	if !s.hub.InRoom(req.Client, in.Room) {
+		return nil, Errorf(CodeForbidden, "not a member of %q", in.Room)
+	}
+	return WhoResult{Room: in.Room, Users: s.hub.presence.Who(in.Room)}, nil
+}
+
+func (s *Server) handleTyping(req *Request) (any, error) {
+	var in TypingRequest
+	if err := req.Bind(&in); err != nil {
+		return nil, err
+	}
+	if !s.hub.InRoom(req.Client, in.Room) {
+		return nil, Errorf(CodeForbidden, "not a member of %q", in.Room)
+	}
+
+	data, err := marshalEnvelope(TypeTyping, "", TypingEvent{
+		Room:   in.Room,
+		User:   senderName(req.Client),
+		Typing: in.Typing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.hub.Broadcast(in.Room, websocket.TextMessage, data)
+	return nil, nil
+}
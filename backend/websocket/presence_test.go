@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// presenceEvents разбирает события присутствия из очереди клиента
+// в вид "user status devices".
+func presenceEvents(t *testing.T, c *Client) []string {
+	t.Helper()
+
+	var out []string
+	for _, env := range replies(t, c) {
+		if env.Type != TypePresence {
+			continue
+		}
+		var ev PresenceEvent
+		if err := json.Unmarshal(env.Payload, &ev); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, fmt.Sprintf("%s %s %d", ev.User, ev.Status, ev.Devices))
+	}
+	return out
+}
+
+// presenceHub — хаб с присутствием и наблюдателем bob в комнате chat.
+func presenceHub(t *testing.T, grace time.Duration) (*Hub, *Client) {
+	t.Helper()
+
+	h := NewHub(HubConfig{})
+	h.presence = newPresence(h, PresenceConfig{Enabled: true, Grace: grace})
+	bob := h.registerStream(&Principal{Subject: "bob"}, "test", "bob")
+	h.Join(bob, "chat")
+	if got := presenceEvents(t, bob); !slices.Equal(got, []string{"bob online 1"}) {
+		t.Fatalf("bob's own join: %q", got)
+	}
+	return h, bob
+}
+
+func TestPresenceJoinLeave(t *testing.T) {
+	h, bob := presenceHub(t, -1)
+	alice := &Principal{Subject: "alice"}
+	phone := h.registerStream(alice, "test", "phone")
+	laptop := h.registerStream(alice, "test", "laptop")
+
+	// второе устройство того же пользователя события не порождает.
+	h.Join(phone, "chat")
+	h.Join(laptop, "chat")
+	if got := presenceEvents(t, bob); !slices.Equal(got, []string{"alice online 1"}) {
+		t.Errorf("joins: %q", got)
+	}
+	want := []PresenceUser{{User: "alice", Devices: 2}, {User: "bob", Devices: 1}}
+	if got := h.presence.Who("chat"); !slices.Equal(got, want) {
+		t.Errorf("Who = %+v, want %+v", got, want)
+	}
+
+	// offline — только когда ушло последнее устройство.
+	h.Leave(phone, "chat")
+	if got := presenceEvents(t, bob); got != nil {
+		t.Errorf("one device left: %q", got)
+	}
+	h.Unregister(laptop)
+	if got := presenceEvents(t, bob); !slices.Equal(got, []string{"alice offline 0"}) {
+		t.Errorf("last device left: %q", got)
+	}
+	if got := h.presence.Who("chat"); len(got) != 1 {
+		t.Errorf("Who after leave = %+v", got)
+	}
+}
+
+func TestPresenceGrace(t *testing.T) {
+	const grace = 50 * time.Millisecond
+	h, bob := presenceHub(t, grace)
+	alice := &Principal{Subject: "alice"}
+	c := h.registerStream(alice, "test", "alice")
+	h.Join(c, "chat")
+	presenceEvents(t, bob)
+
+	// переподключение в grace‑период для остальных незаметно.
+	h.Unregister(c)
+	if got := h.presence.Who("chat"); !slices.Contains(got, PresenceUser{User: "alice"}) {
+		t.Errorf("Who during grace = %+v", got)
+	}
+	c = h.registerStream(alice, "test", "alice")
+	h.Join(c, "chat")
+	time.Sleep(2 * grace)
+	if got := presenceEvents(t, bob); got != nil {
+		t.Errorf("reconnect within grace: %q", got)
+	}
+
+	// не вернулась — offline по истечении grace.
+	h.Unregister(c)
+	if got := presenceEvents(t, bob); got != nil {
+		t.Errorf("offline before grace ended: %q", got)
+	}
+	waitFor(t, "offline", func() bool { return len(h.presence.Who("chat")) == 1 })
+	if got := presenceEvents(t, bob); !slices.Equal(got, []string{"alice offline 0"}) {
+		t.Errorf("after grace: %q", got)
+	}
+}
+
+func TestPresenceHandlers(t *testing.T) {
+	srv := NewServer(Config{Presence: PresenceConfig{Enabled: true, Grace: -1}})
+	h := srv.Hub()
+	alice := h.registerStream(&Principal{Subject: "alice"}, "test", "alice")
+	bob := h.registerStream(&Principal{Subject: "bob"}, "test", "bob")
+	h.Join(alice, "chat")
+	h.Join(bob, "chat")
+	queued(alice)
+	queued(bob)
+
+	srv.router.Dispatch(alice, []byte(`{"type":"who","id":"1","payload":{"room":"chat"}}`))
+	got := replies(t, alice)
+	if len(got) != 1 || got[0].Type != TypeAck ||
+		string(got[0].Payload) != `{"room":"chat","users":[{"user":"alice","devices":1},{"user":"bob","devices":1}]}` {
+		t.Errorf("who: %+v", got)
+	}
+
+	srv.router.Dispatch(alice, []byte(`{"type":"typing","payload":{"room":"chat","typing":true}}`))
+	got = replies(t, bob)
+	if len(got) != 1 || got[0].Type != TypeTyping || string(got[0].Payload) != `{"room":"chat","user":"alice","typing":true}` {
+		t.Errorf("typing: %+v", got)
+	}
+
+	queued(alice) // своё typing отправитель тоже получает
+
+	// чужая комната — forbidden.
+	srv.router.Dispatch(alice, []byte(`{"type":"who","id":"2","payload":{"room":"admins"}}`))
+	if got := replies(t, alice); len(got) != 1 || got[0].Type != TypeError ||
+		string(got[0].Payload) != `{"code":"forbidden","message":"not a member of \"admins\""}` {
+		t.Errorf("who in a foreign room: %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// служебные типы ответов сервера.
+const (
+	TypeAck   = "ack"
+	TypeError = "error"
+)
+
+// коды ошибок протокола.
+const (
+	CodeBadRequest  = "bad_request"
+	CodeUnknownType = "unknown_type"
+	CodeForbidden   = "forbidden"
+	CodeInternal    = "internal"
+)
+
+// Envelope — конверт каждого сообщения поверх WebSocket.
+// ID задаёт клиент, если ждёт ответа; ответ приходит с ReplyTo = ID.
+// Seq проставляет сервер на исходящих сообщениях сессии (см. session.go).
+// Payload закодирован кодеком соединения: JSON или protobuf (codec.go).
+type Envelope struct {
+	Seq     uint64          `json:"seq,omitempty" proto:"1"`
+	Type    string          `json:"type" proto:"2"`
+	ID      string          `json:"id,omitempty" proto:"3"`
+	Payload json.RawMessage `json:"payload,omitempty" proto:"4"`
+	ReplyTo string          `json:"reply_to,omitempty" proto:"5"`
+}
+
+// ProtocolError — ошибка, которую обработчик хочет показать клиенту.
+// Остальные ошибки уходят клиенту как CodeInternal без подробностей.
+type ProtocolError struct {
+	Code    string `json:"code" proto:"1"`
+	Message string `json:"message" proto:"2"`
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Errorf создаёт ProtocolError с форматированным сообщением.
+func Errorf(code, format string, args ...any) error {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Request — входящее сообщение, адресованное обработчику.
+type Request struct {
+	Client   *Client
+	Envelope Envelope
+}
+
+// Bind разбирает Payload в v тем кодеком, которым пришёл запрос.
+func (r *Request) Bind(v any) error {
+	if len(r.Envelope.Payload) == 0 {
+		return Errorf(CodeBadRequest, "empty payload")
+	}
+	if err := r.Client.codec.bind(r.Envelope.Payload, v); err != nil {
+		return Errorf(CodeBadRequest, "invalid payload: %v", err)
+	}
+	return nil
+}
+
+// Handler обрабатывает сообщение одного типа. Результат (может быть nil)
+// отправляется клиенту как ack, если у запроса был ID.
+type Handler func(req *Request) (any, error)
+
+// Router выбирает обработчик по Envelope.Type.
+type Router struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewRouter создаёт пустой роутер.
+func NewRouter() *Router {
+	return &Router{handlers: make(map[string]Handler)}
+}
+
+// Handle регистрирует обработчик для типа сообщения.
+func (rt *Router) Handle(typ string, h Handler) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.handlers[typ] = h
+}
+
+// Dispatch разбирает кадр, вызывает обработчик и отвечает клиенту
+// ack или error, скоррелированными по ID.
+func (rt *Router) Dispatch(c *Client, data []byte) {
+	env, err := c.codec.decode(data)
+	if err != nil || env.Type == "" {
+		c.SendError("", Errorf(CodeBadRequest, "malformed envelope"))
+		return
+	}
+
+	rt.mu.RLock()
+	h, ok := rt.handlers[env.Type]
+	rt.mu.RUnlock()
+	if !ok {
+		c.SendError(env.ID, Errorf(CodeUnknownType, "unknown message type %q", env.Type))
+		return
+	}
+
+	result, err := h(&Request{Client: c, Envelope: env})
+	if err != nil {
+		c.SendError(env.ID, err)
+		return
+	}
+	if env.ID != "" {
+		c.SendEnvelope(TypeAck, env.ID, result)
+	}
+}
+
+// SendEnvelope сериализует payload в конверт кодеком соединения и ставит
+// его в очередь.
+func (c *Client) SendEnvelope(typ, replyTo string, payload any) bool {
+	data, err := c.codec.encode(typ, replyTo, payload)
+	if err != nil {
+		log.Printf("client %s: marshal %s: %v", c.id, typ, err)
+		return false
+	}
+	return c.Send(c.codec.frameType(), data)
+}
+
+// SendError отправляет ошибку. Неизвестные ошибки логируются,
+// а клиент видит только CodeInternal.
+func (c *Client) SendError(replyTo string, err error) bool {
+	var perr *ProtocolError
+	if !errors.As(err, &perr) {
+		log.Printf("client %s: handler error: %v", c.id, err)
+		perr = &ProtocolError{Code: CodeInternal, Message: "internal error"}
+	}
+	return c.SendEnvelope(TypeError, replyTo, perr)
+}
+
+func marshalEnvelope(typ, replyTo string, payload any) ([]byte, error) {
+	env := Envelope{Type: typ, ReplyTo: replyTo}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		env.Payload = raw
+	}
+	return json.Marshal(env)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// replies разбирает ответы из очереди клиента.
+func replies(t *testing.T, c *Client) []Envelope {
+	t.Helper()
+
+	var out []Envelope
+	for _, msg := range queued(c) {
+		var env Envelope
+		if err := json.Unmarshal([]byte(msg), &env); err != nil {
+			t.Fatalf("reply %s: %v", msg, err)
+		}
+		out = append(out, env)
+	}
+	return out
+}
+
+func TestRouterDispatch(t *testing.T) {
+	type sum struct {
+		A, B int
+	}
+	rt := NewRouter()
+	rt.Handle("sum", func(req *Request) (any, error) {
+		var in sum
+		if err := req.Bind(&in); err != nil {
+			return nil, err
+		}
+		return map[string]int{"sum": in.A + in.B}, nil
+	})
+	rt.Handle("deny", func(req *Request) (any, error) {
+		return nil, Errorf(CodeForbidden, "not for %s", "you")
+	})
+	rt.Handle("crash", func(req *Request) (any, error) {
+		return nil, errors.New("db password is hunter2")
+	})
+
+	tests := []struct {
+		name    string
+		in      string
+		typ     string // пусто — ответа нет
+		replyTo string
+		payload string
+	}{
+		{"ack with result", `{"type":"sum","id":"1","payload":{"A":2,"B":3}}`, TypeAck, "1", `{"sum":5}`},
+		{"no id, no ack", `{"type":"sum","payload":{"A":2,"B":3}}`, "", "", ""},
+		{"empty payload", `{"type":"sum","id":"2"}`, TypeError, "2", `{"code":"bad_request","message":"empty payload"}`},
+		{"unknown type", `{"type":"nope","id":"3"}`, TypeError, "3", `{"code":"unknown_type","message":"unknown message type \"nope\""}`},
+		{"malformed json", `{"type":`, TypeError, "", `{"code":"bad_request","message":"malformed envelope"}`},
+		{"missing type", `{"id":"4"}`, TypeError, "", `{"code":"bad_request","message":"malformed envelope"}`},
+		{"protocol error", `{"type":"deny","id":"5"}`, TypeError, "5", `{"code":"forbidden","message":"not for you"}`},
+		// подробности внутренней ошибки клиенту не показываются.
+		{"internal error", `{"type":"crash","id":"6"}`, TypeError, "6", `{"code":"internal","message":"internal error"}`},
+	}
+	h := NewHub(HubConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := h.registerStream(nil, "test", tt.name)
+			defer h.Unregister(c)
+
+			rt.Dispatch(c, []byte(tt.in))
+			got := replies(t, c)
+			if tt.typ == "" {
+				if len(got) != 0 {
+					t.Fatalf("unexpected replies %+v", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d replies, want 1", len(got))
+			}
+			if got[0].Type != tt.typ || got[0].ReplyTo != tt.replyTo || string(got[0].Payload) != tt.payload {
+				t.Errorf("reply = %s %q %s, want %s %q %s",
+					got[0].Type, got[0].ReplyTo, got[0].Payload, tt.typ, tt.replyTo, tt.payload)
+			}
+		})
+	}
+}
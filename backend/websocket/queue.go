@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// OverflowPolicy — что делать, когда исходящая очередь клиента заполнена.
+type OverflowPolicy int
+
+const (
+	// DropNewest выбрасывает сообщение, которое не поместилось.
+	DropNewest OverflowPolicy = iota
+	// DropOldest вытесняет самое старое сообщение из очереди.
+	DropOldest
+	// Disconnect закрывает соединение с кодом 1008 (policy violation):
+	// клиент переподключится и заново получит актуальное состояние.
+	Disconnect
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropNewest:
+		return "drop_newest"
+	case DropOldest:
+		return "drop_oldest"
+	case Disconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// ParseOverflowPolicy разбирает значение флага/конфига.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	for _, p := range []OverflowPolicy{DropNewest, DropOldest, Disconnect} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// QueueConfig — размер и политика исходящей очереди каждого клиента.
+type QueueConfig struct {
+	// Size — ёмкость очереди в сообщениях; 0 означает sendBufferSize.
+	Size     int
+	Overflow OverflowPolicy
+}
+
+func (q QueueConfig) size() int {
+	if q.Size > 0 {
+		return q.Size
+	}
+	return sendBufferSize
+}
+
+// ShedCounters — сколько сообщений/клиентов отброшено каждой политикой.
+type ShedCounters struct {
+	DroppedNewest atomic.Uint64
+	DroppedOldest atomic.Uint64
+	Disconnected  atomic.Uint64
+}
+
+// ShedStats — снимок ShedCounters для Stats.
+type ShedStats struct {
+	DroppedNewest uint64 `json:"dropped_newest"`
+	DroppedOldest uint64 `json:"dropped_oldest"`
+	Disconnected  uint64 `json:"disconnected"`
+}
+
+func (s *ShedCounters) snapshot() ShedStats {
+	return ShedStats{
+		DroppedNewest: s.DroppedNewest.Load(),
+		DroppedOldest: s.DroppedOldest.Load(),
+		Disconnected:  s.Disconnected.Load(),
+	}
+}
+
+// enqueue кладёт кадр в очередь, применяя политику переполнения.
+func (c *Client) enqueue(msg outbound) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.done:
+		return false
+	default:
+	}
+
+	shed := &c.hub.shed
+	switch c.hub.cfg.Queue.Overflow {
+	case DropOldest:
+		// вытесняем под мьютексом, чтобы параллельные Send не выбросили
+		// больше сообщений, чем нужно.
+		c.sendMu.Lock()
+		defer c.sendMu.Unlock()
+		for {
+			select {
+			case c.send <- msg:
+				return true
+			case <-c.done:
+				return false
+			default:
+			}
+			select {
+			case <-c.send:
+				c.dropped.Add(1)
+				shed.DroppedOldest.Add(1)
+			default:
+			}
+		}
+
+	case Disconnect:
+		if c.shed.CompareAndSwap(false, true) {
+			log.Printf("client %s (%s): send queue overflow, disconnecting", c.id, senderName(c))
+			shed.Disconnected.Add(1)
+			go c.CloseWith(websocket.ClosePolicyViolation, "send queue overflow")
+		}
+		return false
+
+	default:
+		c.dropped.Add(1)
+		shed.DroppedNewest.Add(1)
+		if c.dropped.Load()%100 == 1 {
+			log.Printf("client %s (%s): send queue full, dropped %d messages", c.id, senderName(c), c.dropped.Load())
+		}
+		return false
+	}
+}
+
+// QueueLen возвращает текущую глубину исходящей очереди.
+func (c *Client) QueueLen() int { return len(c.send) }
+
+// Dropped возвращает, сколько сообщений этого клиента было отброшено.
+func (c *Client) Dropped() uint64 { return c.dropped.Load() }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestQueueOverflow(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   []string // что осталось в очереди
+		shed   ShedStats
+		closed bool
+	}{
+		{DropNewest, []string{"0", "1"}, ShedStats{DroppedNewest: 3}, false},
+		{DropOldest, []string{"3", "4"}, ShedStats{DroppedOldest: 3}, false},
+		{Disconnect, nil, ShedStats{Disconnected: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			h := NewHub(HubConfig{Queue: QueueConfig{Size: 2, Overflow: tt.policy}})
+			// клиент без writer‑горутины — медленный потребитель.
+			c := h.registerStream(nil, "test", "slow")
+			h.Join(c, "feed")
+
+			for i := range 5 {
+				h.Broadcast("feed", websocket.TextMessage, []byte(strconv.Itoa(i)))
+			}
+			if tt.closed {
+				// сокета нет: закрытие видно по done.
+				waitFor(t, "disconnect", func() bool {
+					select {
+					case <-c.done:
+						return true
+					default:
+						return false
+					}
+				})
+			}
+			if got := queued(c); !tt.closed && !slices.Equal(got, tt.want) {
+				t.Errorf("queue = %q, want %q", got, tt.want)
+			}
+			if got := h.Shed(); got != tt.shed {
+				t.Errorf("shed = %+v, want %+v", got, tt.shed)
+			}
+			if tt.policy != Disconnect && c.Dropped() != 3 {
+				t.Errorf("Dropped = %d, want 3", c.Dropped())
+			}
+		})
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, p := range []OverflowPolicy{DropNewest, DropOldest, Disconnect} {
+		if got, err := ParseOverflowPolicy(p.String()); err != nil || got != p {
+			t.Errorf("ParseOverflowPolicy(%q) = %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParseOverflowPolicy("drop_all"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func TestSlowConsumerDisconnected(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Hub:       HubConfig{Queue: QueueConfig{Size: 1, Overflow: Disconnect}},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	fast := dial(t, wsURL(ts, "/ws?room=feed"), nil)
+	received := make(chan struct{}, 1)
+	go func() {
+		for {
+			if _, _, err := fast.ReadMessage(); err != nil {
+				return
+			}
+			received <- struct{}{}
+		}
+	}()
+	// медленный клиент не читает: сначала заполняются буферы TCP, потом очередь.
+	dial(t, wsURL(ts, "/ws?room=feed"), nil)
+	waitFor(t, "two clients", func() bool { return srv.Hub().Len() == 2 })
+
+	// шлём в ногу с быстрым клиентом, чтобы переполнялась только очередь медленного.
+	big := make([]byte, 256<<10)
+	for i := 0; srv.Hub().Shed().Disconnected == 0; i++ {
+		if i == 1000 {
+			t.Fatal("slow client was never shed")
+		}
+		srv.Hub().Broadcast("feed", websocket.BinaryMessage, big)
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("fast client stalled")
+		}
+	}
+	waitFor(t, "slow client removed", func() bool { return srv.Hub().Len() == 1 })
+	if got := srv.Hub().Shed().Disconnected; got != 1 {
+		t.Errorf("disconnected = %d, want 1 (the fast client must stay)", got)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TypeRateLimited — предупреждение клиенту о превышении лимита.
+const TypeRateLimited = "rate_limited"
+
+// LimitAction — реакция на превышение лимита входящих сообщений.
+type LimitAction int
+
+const (
+	// LimitDrop молча отбрасывает сообщение.
+	LimitDrop LimitAction = iota
+	// LimitWarn отбрасывает сообщение и присылает TypeRateLimited
+	// (не чаще раза в секунду).
+	LimitWarn
+	// LimitClose закрывает соединение с кодом 1008.
+	LimitClose
+)
+
+func (a LimitAction) String() string {
+	switch a {
+	case LimitDrop:
+		return "drop"
+	case LimitWarn:
+		return "warn"
+	case LimitClose:
+		return "close"
+	default:
+		return fmt.Sprintf("LimitAction(%d)", int(a))
+	}
+}
+
+// ParseLimitAction разбирает значение флага/конфига.
+func ParseLimitAction(s string) (LimitAction, error) {
+	for _, a := range []LimitAction{LimitDrop, LimitWarn, LimitClose} {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown limit action %q", s)
+}
+
+// Rate — параметры token bucket: скорость пополнения и ёмкость.
+// Нулевой PerSecond отключает лимит.
+type Rate struct {
+	PerSecond float64
+	Burst     float64
+}
+
+// MessageLimits — лимиты на число сообщений и на байты.
+type MessageLimits struct {
+	Messages Rate
+	Bytes    Rate
+}
+
+// RateLimitConfig — лимиты входящего трафика.
+type RateLimitConfig struct {
+	PerConn MessageLimits
+	PerIP   MessageLimits
+	Action  LimitAction
+
+	// MaxConnsPerIP ограничивает одновременные соединения с одного IP,
+	// чтобы шторм переподключений не выел файловые дескрипторы. 0 — без лимита.
+	MaxConnsPerIP int
+	// ClientIP определяет адрес клиента; по умолчанию берётся RemoteAddr.
+	// За балансировщиком сюда подставляют разбор X-Forwarded-For.
+	ClientIP func(r *http.Request) string
+}
+
+// LimitWarning — payload TypeRateLimited.
+type LimitWarning struct {
+	Scope        string `json:"scope" proto:"1"` // conn или ip
+	RetryAfterMs int64  `json:"retry_after_ms" proto:"2"`
+}
+
+// tokenBucket — классический token bucket. Пустой (rate=0) всегда пропускает.
+type tokenBucket struct {
+	rate, burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(r Rate) *tokenBucket {
+	burst := r.Burst
+	if burst < r.PerSecond {
+		burst = r.PerSecond
+	}
+	return &tokenBucket{rate: r.PerSecond, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+func (b *tokenBucket) limited() bool { return b != nil && b.rate > 0 }
+
+// debit — сколько токенов списать из ведра.
+type debit struct {
+	b *tokenBucket
+	n float64
+}
+
+// takeAll списывает токены сразу из всех ведер или, если хоть в одном
+// не хватает, ни из одного. Возвращает индекс первого отказавшего ведра
+// (-1 — всё списано) и через сколько в нём станет достаточно токенов.
+// Ведра блокируются по порядку, поэтому порядок у всех вызовов один:
+// сначала ведра соединения, потом IP.
+func takeAll(ds ...debit) (int, time.Duration) {
+	now := time.Now()
+	for _, d := range ds {
+		if d.b.limited() {
+			d.b.mu.Lock()
+			defer d.b.mu.Unlock()
+			d.b.refill(now)
+		}
+	}
+
+	for i, d := range ds {
+		if d.b.limited() && d.b.tokens < d.n {
+			return i, time.Duration((d.n - d.b.tokens) / d.b.rate * float64(time.Second))
+		}
+	}
+	for _, d := range ds {
+		if d.b.limited() {
+			d.b.tokens -= d.n
+		}
+	}
+	return -1, 0
+}
+
+// full сообщает, что ведро полностью восстановилось.
+func (b *tokenBucket) full() bool {
+	if !b.limited() {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	return b.tokens >= b.burst
+}
+
+// limitPair — ведра на сообщения и байты одной области (conn или ip).
+type limitPair struct {
+	msgs, bytes *tokenBucket
+}
+
+func newLimitPair(l MessageLimits) limitPair {
+	return limitPair{msgs: newTokenBucket(l.Messages), bytes: newTokenBucket(l.Bytes)}
+}
+
+// debits — списание за одно сообщение размером size.
+func (p limitPair) debits(size int) []debit {
+	return []debit{{p.msgs, 1}, {p.bytes, float64(size)}}
+}
+
+// idle сообщает, что ведра полностью восстановились.
+func (p limitPair) idle() bool { return p.msgs.full() && p.bytes.full() }
+
+// ipState — общие для всех соединений одного IP ведра и счётчик соединений.
+type ipState struct {
+	limits limitPair
+	conns  int // защищено RateLimiter.mu
+}
+
+// ipSweepInterval — как часто Admit удаляет состояние IP без соединений.
+const ipSweepInterval = time.Minute
+
+// RateLimiter хранит состояние лимитов по IP.
+type RateLimiter struct {
+	cfg     RateLimitConfig
+	metrics *Metrics
+
+	mu        sync.Mutex
+	ips       map[string]*ipState
+	lastSweep time.Time
+}
+
+// NewRateLimiter создаёт лимитер. metrics может быть nil.
+func NewRateLimiter(cfg RateLimitConfig, metrics *Metrics) *RateLimiter {
+	return &RateLimiter{cfg: cfg, metrics: metrics, ips: make(map[string]*ipState)}
+}
+
+// connLimit — лимиты одного соединения.
+type connLimit struct {
+	rl     *RateLimiter
+	ip     string
+	state  *ipState
+	limits limitPair
+
+	lastWarn time.Time // трогает только читатель: readPump или /send под sendMu
+	released sync.Once
+}
+
+// Admit проверяет лимит соединений с IP до апгрейда. При отказе сам
+// отвечает 429. Полученный connLimit нужно освободить через release.
+func (rl *RateLimiter) Admit(w http.ResponseWriter, r *http.Request) (*connLimit, bool) {
+	ip := rl.clientIP(r)
+
+	rl.mu.Lock()
+	if now := time.Now(); now.Sub(rl.lastSweep) >= ipSweepInterval {
+		rl.lastSweep = now
+		rl.sweep()
+	}
+	st, ok := rl.ips[ip]
+	if !ok {
+		st = &ipState{limits: newLimitPair(rl.cfg.PerIP)}
+		rl.ips[ip] = st
+	}
+	if rl.cfg.MaxConnsPerIP > 0 && st.conns >= rl.cfg.MaxConnsPerIP {
+		rl.mu.Unlock()
+		log.Printf("rate limit: %s has %d connections, rejecting upgrade", ip, rl.cfg.MaxConnsPerIP)
+		rl.metrics.upgradeRejected("too_many_connections")
+		http.Error(w, "too many connections", http.StatusTooManyRequests)
+		return nil, false
+	}
+	st.conns++
+	rl.mu.Unlock()
+
+	return &connLimit{rl: rl, ip: ip, state: st, limits: newLimitPair(rl.cfg.PerConn)}, true
+}
+
+// release освобождает слот соединения. Состояние IP удаляется, когда
+// соединений не осталось и ведра восстановились: иначе переподключение
+// обнуляло бы штраф. Не восстановившиеся ведра позже убирает sweep.
+func (l *connLimit) release() {
+	l.released.Do(func() {
+		rl := l.rl
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+
+		l.state.conns--
+		if l.state.conns == 0 && l.state.limits.idle() {
+			delete(rl.ips, l.ip)
+		}
+	})
+}
+
+// sweep удаляет IP, которые release не смог удалить сразу: соединений
+// нет, а ведра с тех пор восстановились. Вызывается под rl.mu.
+func (rl *RateLimiter) sweep() {
+	for ip, st := range rl.ips {
+		if st.conns == 0 && st.limits.idle() {
+			delete(rl.ips, ip)
+		}
+	}
+}
+
+// allow проверяет входящее сообщение и применяет Action при превышении.
+func (l *connLimit) allow(c *Client, size int) bool {
+	// отказ IP не должен съедать токены соединения, и наоборот.
+	failed, wait := takeAll(append(l.limits.debits(size), l.state.limits.debits(size)...)...)
+	if failed < 0 {
+		return true
+	}
+	scope := "conn"
+	if failed >= 2 {
+		scope = "ip"
+	}
+
+	action := l.rl.cfg.Action
+	l.rl.metrics.rateLimited(scope, action)
+
+	switch action {
+	case LimitWarn:
+		if now := time.Now(); now.Sub(l.lastWarn) >= time.Second {
+			l.lastWarn = now
+			c.SendEnvelope(TypeRateLimited, "", LimitWarning{Scope: scope, RetryAfterMs: wait.Milliseconds()})
+		}
+	case LimitClose:
+		log.Printf("client %s (%s): %s rate limit exceeded, closing", c.id, l.ip, scope)
+		go c.CloseWith(websocket.ClosePolicyViolation, "rate limit exceeded")
+	}
+	return false
+}
+
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+	if rl.cfg.ClientIP != nil {
+		return rl.cfg.ClientIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limited оборачивает обработчик входящих сообщений проверкой лимитов:
+// отброшенные сообщения не доходят ни до логов, ни до роутера.
+func limited(c *Client, l *connLimit, onMessage func(mt int, msg []byte)) func(mt int, msg []byte) {
+	return func(mt int, msg []byte) {
+		if l.allow(c, len(msg)) {
+			onMessage(mt, msg)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// admit пропускает соединение с ip через лимитер.
+func admit(t *testing.T, rl *RateLimiter, ip string) *connLimit {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.RemoteAddr = ip + ":1234"
+	l, ok := rl.Admit(httptest.NewRecorder(), r)
+	if !ok {
+		t.Fatalf("Admit(%s) rejected", ip)
+	}
+	return l
+}
+
+func TestLimitPairAtomic(t *testing.T) {
+	p := newLimitPair(MessageLimits{
+		Messages: Rate{PerSecond: 0.001, Burst: 10},
+		Bytes:    Rate{PerSecond: 0.001, Burst: 100},
+	})
+
+	// отказ по байтам не списывает токен сообщения.
+	if failed, _ := takeAll(p.debits(200)...); failed != 1 {
+		t.Fatalf("oversized message: failed = %d, want 1 (bytes)", failed)
+	}
+	for range 2 {
+		if failed, _ := takeAll(p.debits(50)...); failed != -1 {
+			t.Fatalf("message within limits rejected by bucket %d", failed)
+		}
+	}
+	failed, wait := takeAll(p.debits(50)...)
+	if failed != 1 || wait <= 0 {
+		t.Errorf("bytes exhausted: failed = %d, wait = %v", failed, wait)
+	}
+	if got := p.msgs.tokens; got < 7.99 || got > 8.01 {
+		t.Errorf("message tokens = %v, want 8", got)
+	}
+}
+
+func TestAllowScopes(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{
+		PerConn: MessageLimits{Messages: Rate{PerSecond: 0.001, Burst: 5}},
+		PerIP:   MessageLimits{Messages: Rate{PerSecond: 0.001, Burst: 3}},
+		Action:  LimitDrop,
+	}, nil)
+	a, b := admit(t, rl, "10.0.0.1"), admit(t, rl, "10.0.0.1")
+
+	// два соединения делят три токена IP.
+	for i, l := range []*connLimit{a, a, b} {
+		if !l.allow(nil, 1) {
+			t.Fatalf("message %d rejected", i)
+		}
+	}
+	for range 3 {
+		if b.allow(nil, 1) {
+			t.Fatal("IP limit not applied")
+		}
+	}
+	// отказы по IP не тратят токены соединения.
+	if got := b.limits.msgs.tokens; got < 3.99 || got > 4.01 {
+		t.Errorf("conn tokens of b = %v, want 4", got)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{
+		PerIP:  MessageLimits{Messages: Rate{PerSecond: 50, Burst: 1}},
+		Action: LimitDrop,
+	}, nil)
+
+	l := admit(t, rl, "10.0.0.1")
+	l.allow(nil, 1)
+	// ведро IP пустое: при release состояние остаётся, чтобы штраф не обнулился.
+	l.release()
+	if _, ok := rl.ips["10.0.0.1"]; !ok {
+		t.Fatal("drained IP state removed on release")
+	}
+
+	busy := admit(t, rl, "10.0.0.2")
+	busy.allow(nil, 1)
+	time.Sleep(50 * time.Millisecond)
+	// ведра восстановились; следующий Admit после интервала чистит простаивающие IP.
+	rl.mu.Lock()
+	rl.lastSweep = time.Time{}
+	rl.mu.Unlock()
+	admit(t, rl, "10.0.0.3")
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if _, ok := rl.ips["10.0.0.1"]; ok {
+		t.Error("idle IP state not swept")
+	}
+	// с живым соединением состояние не трогается.
+	if _, ok := rl.ips["10.0.0.2"]; !ok || len(rl.ips) != 2 {
+		t.Errorf("ips = %v", rl.ips)
+	}
+}
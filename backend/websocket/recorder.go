@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
+)
+
+// Запись трафика для отладки и регрессионных прогонов через wsreplay.
+// Включается HubConfig.Recorder; пишутся все кадры всех транспортов,
+// открытие соединения (URI без токена и сессии) и close‑кадры с обеих
+// сторон. Соединения записываются как "<epoch>/<id>": ID хаба после
+// перезапуска повторяются, а файл дописывается.
+
+// record пишет кадр клиента в запись, если она включена.
+func (c *Client) record(dir wsrecord.Direction, mt int, data []byte) {
+	rec := c.hub.cfg.Recorder
+	if rec == nil {
+		return
+	}
+	err := rec.Write(wsrecord.Frame{
+		Dir:     dir,
+		Time:    time.Now(),
+		Opcode:  byte(mt),
+		Conn:    rec.ConnID(c.id),
+		Payload: data,
+	})
+	// при сбое диска не засыпаем лог ошибкой на каждый кадр.
+	if err != nil && c.hub.recordFailed.CompareAndSwap(false, true) {
+		log.Printf("recorder: %v (further errors suppressed)", err)
+	}
+}
+
+// recordOpen отмечает начало соединения; wsreplay берёт из URI комнаты.
+func (c *Client) recordOpen(r *http.Request) {
+	if c.hub.cfg.Recorder == nil {
+		return
+	}
+	c.record(wsrecord.In, int(wsrecord.OpOpen), []byte(redactedURI(r)))
+}
+
+// recordClose пишет close‑кадр в том же виде, что уходит по сети.
+func (c *Client) recordClose(dir wsrecord.Direction, code int, reason string) {
+	if c.hub.cfg.Recorder == nil {
+		return
+	}
+	c.record(dir, websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+}
+
+// redactedParams — параметры запроса, которые не попадают в запись:
+// токен и ID сессии дают доступ к чужому соединению.
+var redactedParams = []string{"token", "session"}
+
+// redactedURI убирает из URI секреты: запись уходит разработчикам и в тикеты.
+func redactedURI(r *http.Request) string {
+	u := *r.URL
+	q := u.Query()
+	redacted := false
+	for _, name := range redactedParams {
+		if q.Has(name) {
+			q.Del(name)
+			redacted = true
+		}
+	}
+	if redacted {
+		u.RawQuery = q.Encode()
+	}
+	return u.RequestURI()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
+)
+
+func TestRedactedURI(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/ws?room=a", "/ws?room=a"},
+		{"/ws?room=a&token=secret", "/ws?room=a"},
+		{"/ws?session=abc&last_seq=5&room=a", "/ws?last_seq=5&room=a"},
+		{"/ws?token=t&session=s", "/ws"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.in, nil)
+		if got := redactedURI(r); got != tt.want {
+			t.Errorf("redactedURI(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := wsrecord.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Hub:       HubConfig{Recorder: rec},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn := dial(t, wsURL(ts, "/ws?room=news&token=secret"), nil)
+	waitFor(t, "client", func() bool { return srv.Hub().Len() == 1 })
+	srv.Hub().Broadcast("news", websocket.TextMessage, []byte("hello"))
+	readText(t, conn)
+	conn.Close()
+	waitFor(t, "disconnect", func() bool { return srv.Hub().Len() == 0 })
+
+	r, err := wsrecord.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		f, err := r.Next()
+		if err != nil {
+			break
+		}
+		if !strings.HasPrefix(f.Conn, rec.Epoch()+"/") {
+			t.Errorf("conn %q has no epoch %q", f.Conn, rec.Epoch())
+		}
+		got = append(got, f.Dir.String()+" "+string(f.Payload))
+	}
+	if len(got) < 2 || got[0] != "in /ws?room=news" || got[1] != "out hello" {
+		t.Errorf("recorded %q", got)
+	}
+}
@@ -1,54 +1,525 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	"websocket/wsrecord"
 )
 
-// upgrader переводит HTTP в WebSocket.
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	// в проде здесь надо проверять Origin.
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// комната, в которую попадает клиент без параметра ?room=.
+const defaultRoom = "lobby"
+
+// Config — настройки WebSocket‑сервера.
+type Config struct {
+	Handshake HandshakePolicy
+	Hub       HubConfig
+
+	// Auth вызывается до апгрейда; nil — анонимные соединения.
+	Auth Authenticator
+	// AuthorizeJoin проверяет вход в комнату; nil — вход свободный.
+	AuthorizeJoin JoinAuthorizer
+
+	// DrainTimeout — сколько Shutdown ждёт закрытия клиентов;
+	// 0 означает DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	// Sessions включает resume по ?session=&last_seq= на /ws.
+	Sessions SessionConfig
+
+	// Metrics отдаётся на /metrics; nil — сервер создаст свой набор.
+	Metrics *Metrics
+
+	// RateLimit ограничивает входящие сообщения и соединения с одного IP.
+	RateLimit RateLimitConfig
+
+	// Presence включает события online/offline, who и typing.
+	Presence PresenceConfig
+	// Fallback включает /sse, /poll и /send для клиентов без WebSocket.
+	Fallback FallbackConfig
+
+	// Admin включает /admin/ для операторов.
+	Admin AdminConfig
+
+	// TLS — сертификаты для ListenAndServeTLS.
+	TLS TLSConfig
 }
 
-// echoHandler апгрейдит соединение и эхо‑ит сообщения.
-func echoHandler(w http.ResponseWriter, r *http.Request) {
-	// апгрейд HTTP -> WebSocket.
-	conn, err := upgrader.Upgrade(w, r, nil)
+// Server связывает хаб, политику рукопожатия, роутер сообщений
+// и HTTP‑обработчики.
+type Server struct {
+	cfg      Config
+	hub      *Hub
+	router   *Router
+	sessions *SessionStore
+	limiter  *RateLimiter
+	streams  *streamRegistry
+	upgrader *websocket.Upgrader
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	draining   atomic.Bool
+	// rejectUpgrades включается через admin API на время инцидента.
+	rejectUpgrades atomic.Bool
+}
+
+// NewServer создаёт сервер с пустым хабом и стандартными обработчиками.
+func NewServer(cfg Config) *Server {
+	if cfg.Metrics == nil {
+		cfg.Metrics = NewMetrics()
+	}
+	cfg.Hub.Metrics = cfg.Metrics
+
+	s := &Server{
+		cfg:      cfg,
+		hub:      NewHub(cfg.Hub),
+		router:   NewRouter(),
+		limiter:  NewRateLimiter(cfg.RateLimit, cfg.Metrics),
+		streams:  newStreamRegistry(),
+		upgrader: cfg.Handshake.Upgrader(),
+	}
+	s.upgrader.EnableCompression = cfg.Hub.Compression.Enabled
+	if cfg.Sessions.enabled() {
+		s.sessions = NewSessionStore(s.hub, cfg.Sessions)
+	}
+	s.registerBuiltins()
+	if cfg.Presence.Enabled {
+		s.hub.presence = newPresence(s.hub, cfg.Presence)
+		s.registerPresence()
+	}
+	s.registerMetrics()
+	return s
+}
+
+// Hub возвращает хаб сервера.
+func (s *Server) Hub() *Hub { return s.hub }
+
+// Handle регистрирует обработчик сообщений типа typ на /ws.
+func (s *Server) Handle(typ string, h Handler) {
+	s.router.Handle(typ, h)
+	s.cfg.Metrics.RegisterType(typ)
+}
+
+// Metrics возвращает метрики сервера.
+func (s *Server) Metrics() *Metrics { return s.cfg.Metrics }
+
+// Handler возвращает маршруты сервера.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.roomHandler)
+	mux.HandleFunc("/echo", s.echoHandler)
+	if s.cfg.Fallback.Enabled {
+		mux.HandleFunc("/sse", s.sseHandler)
+		mux.HandleFunc("/poll", s.pollHandler)
+		mux.HandleFunc("/send", s.sendHandler)
+	}
+	if s.cfg.Admin.Auth != nil {
+		s.adminHandler(mux)
+	}
+	mux.HandleFunc("/stats", s.statsHandler)
+	mux.Handle("/metrics", s.cfg.Metrics)
+	return mux
+}
+
+// Stats — снимок состояния сервера для отладки и алертов.
+type Stats struct {
+	Clients      int              `json:"clients"`
+	Reaped       uint64           `json:"reaped"`
+	Shed         ShedStats        `json:"shed"`
+	Compressed   CompressionStats `json:"compressed"`
+	Uncompressed CompressionStats `json:"uncompressed"`
+}
+
+// Stats возвращает текущий снимок состояния.
+func (s *Server) Stats() Stats {
+	compressed, uncompressed := s.hub.Compression()
+	return Stats{
+		Clients:      s.hub.Len(),
+		Reaped:       s.hub.Reaped(),
+		Shed:         s.hub.Shed(),
+		Compressed:   compressed,
+		Uncompressed: uncompressed,
+	}
+}
+
+// statsHandler отдаёт Stats в JSON.
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Stats()); err != nil {
+		log.Println("stats encode error:", err)
+	}
+}
+
+// upgrade проверяет политику рукопожатия, аутентифицирует запрос
+// и переводит HTTP в WebSocket. При отказе ответ клиенту уже записан.
+func (s *Server) upgrade(w http.ResponseWriter, r *http.Request, rooms ...string) (*websocket.Conn, *Principal, bool) {
+	principal, ok := s.admit(w, r, rooms...)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// countingWriter подсовывает gorilla сокет, считающий байты на проводе.
+	cw := &countingWriter{ResponseWriter: w, deflate: s.upgrader.EnableCompression && offersDeflate(r)}
+	conn, err := s.upgrader.Upgrade(cw, r, nil)
 	if err != nil {
+		// Upgrade уже ответил клиенту сам.
 		log.Println("upgrade error:", err)
-		return
+		s.cfg.Metrics.upgradeRejected("bad_handshake")
+		return nil, nil, false
 	}
-	defer conn.Close()
+	s.cfg.Metrics.upgradeAccepted()
 
-	for {
-		// читаем сообщение от клиента
-		mt, msg, err := conn.ReadMessage()
-		if err != nil {
-			log.Println("read error:", err)
-			break
+	if level := s.cfg.Hub.Compression.Level; level != 0 {
+		if err := conn.SetCompressionLevel(level); err != nil {
+			log.Println("compression level:", err)
+		}
+	}
+	return conn, principal, true
+}
+
+// admit — общие для всех транспортов проверки до подключения: остановка
+// сервера, политика рукопожатия, аутентификация и доступ к комнатам.
+func (s *Server) admit(w http.ResponseWriter, r *http.Request, rooms ...string) (*Principal, bool) {
+	reject := func(reason string, err error) (*Principal, bool) {
+		log.Println("handshake rejected:", err)
+		s.cfg.Metrics.upgradeRejected(reason)
+		writeHandshakeError(w, err)
+		return nil, false
+	}
+
+	if s.Draining() {
+		return reject("draining", Reject(http.StatusServiceUnavailable, "server shutting down"))
+	}
+	if s.rejectUpgrades.Load() {
+		return reject("paused", Reject(http.StatusServiceUnavailable, "new connections are paused"))
+	}
+
+	if err := s.cfg.Handshake.Check(r); err != nil {
+		return reject(policyRejectReason(err), err)
+	}
+
+	principal, err := authenticate(s.cfg.Auth, r)
+	if err != nil {
+		return reject("unauthorized", err)
+	}
+
+	for _, room := range rooms {
+		if err := s.authorizeJoin(principal, room); err != nil {
+			return reject("forbidden_room", err)
 		}
+	}
+	return principal, true
+}
+
+func (s *Server) authorizeJoin(p *Principal, room string) error {
+	if s.cfg.AuthorizeJoin == nil {
+		return nil
+	}
+	if err := s.cfg.AuthorizeJoin(p, room); err != nil {
+		var herr *HandshakeError
+		if errors.As(err, &herr) {
+			return herr
+		}
+		return &HandshakeError{Status: http.StatusForbidden, Reason: err.Error()}
+	}
+	return nil
+}
+
+// Join добавляет клиента в комнату после проверки AuthorizeJoin.
+func (s *Server) Join(c *Client, room string) error {
+	if err := s.authorizeJoin(c.Principal(), room); err != nil {
+		return err
+	}
+	s.hub.Join(c, room)
+	return nil
+}
+
+// echoHandler апгрейдит соединение и эхо‑ит сообщения.
+func (s *Server) echoHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := s.limiter.Admit(w, r)
+	if !ok {
+		return
+	}
+	defer limit.release()
+
+	// апгрейд HTTP -> WebSocket.
+	conn, principal, ok := s.upgrade(w, r)
+	if !ok {
+		return
+	}
+
+	client := s.hub.Register(conn, principal)
+	client.recordOpen(r)
+	client.readPump(limited(client, limit, func(mt int, msg []byte) {
 		log.Printf("recv: %s\n", msg)
 
-		// отправляем назад то же сообщение
-		if err := conn.WriteMessage(mt, msg); err != nil {
-			log.Println("write error:", err)
-			break
+		// отправляем назад то же сообщение через writer‑горутину
+		client.Send(mt, msg)
+	}))
+}
+
+// roomHandler подключает клиента к комнатам из ?room= и разбирает входящие
+// кадры как Envelope: join/leave/publish и зарегистрированные через Handle.
+func (s *Server) roomHandler(w http.ResponseWriter, r *http.Request) {
+	rooms := requestRooms(r)
+
+	limit, ok := s.limiter.Admit(w, r)
+	if !ok {
+		return
+	}
+	defer limit.release()
+
+	conn, principal, ok := s.upgrade(w, r, rooms...)
+	if !ok {
+		return
+	}
+
+	client := s.hub.Register(conn, principal)
+	client.recordOpen(r)
+	if s.sessions != nil {
+		// при удачном resume комнаты уже перенесены, повторный Join безвреден.
+		lastSeq, _ := strconv.ParseUint(r.URL.Query().Get("last_seq"), 10, 64)
+		s.sessions.Attach(client, r.URL.Query().Get("session"), lastSeq)
+	}
+	for _, room := range rooms {
+		s.hub.Join(client, room)
+	}
+
+	client.readPump(limited(client, limit, func(mt int, msg []byte) {
+		if err := client.checkFrame(mt); err != nil {
+			client.SendError("", err)
+			return
+		}
+		s.router.Dispatch(client, msg)
+	}))
+}
+
+// requestRooms возвращает комнаты из ?room=, по умолчанию defaultRoom.
+func requestRooms(r *http.Request) []string {
+	rooms := r.URL.Query()["room"]
+	if len(rooms) == 0 {
+		rooms = []string{defaultRoom}
+	}
+	return rooms
+}
+
+// splitList разбирает значение флага вида "a,b,c".
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
 		}
 	}
+	return out
 }
 
 func main() {
-	http.HandleFunc("/ws", echoHandler)
+	addr := flag.String("addr", ":8080", "listen address")
+	origins := flag.String("origins", "", "comma-separated Origin allowlist, e.g. https://app.example.com,https://*.example.com")
+	allowNoOrigin := flag.Bool("allow-no-origin", true, "accept upgrades without Origin header (non-browser clients)")
+	pingInterval := flag.Duration("ping-interval", DefaultHeartbeat.PingInterval, "server ping period, 0 disables heartbeat")
+	maxMissed := flag.Int("max-missed-pongs", DefaultHeartbeat.MaxMissedPongs, "unanswered pings before a connection is reaped")
+	queueSize := flag.Int("send-queue", sendBufferSize, "per-client outbound queue size")
+	overflow := flag.String("overflow", DropOldest.String(), "queue overflow policy: drop_oldest, drop_newest or disconnect")
+	drain := flag.Duration("drain", DefaultDrainTimeout, "how long shutdown waits for clients to close")
+	replay := flag.Int("replay", 256, "per-session replay buffer size, 0 disables resumable sessions")
+	sessionTTL := flag.Duration("session-ttl", 2*time.Minute, "how long a disconnected session can be resumed")
+	brokerAddr := flag.String("backplane-listen", "", "run a backplane broker on this address")
+	backplaneAddr := flag.String("backplane", "", "connect to a backplane broker to share rooms with other nodes")
+	msgRate := flag.Float64("msg-rate", 50, "per-connection inbound messages per second, 0 disables")
+	byteRate := flag.Float64("byte-rate", 256<<10, "per-connection inbound bytes per second, 0 disables")
+	ipMsgRate := flag.Float64("ip-msg-rate", 200, "per-IP inbound messages per second, 0 disables")
+	ipByteRate := flag.Float64("ip-byte-rate", 1<<20, "per-IP inbound bytes per second, 0 disables")
+	limitAction := flag.String("limit-action", LimitWarn.String(), "what to do with messages over the limit: drop, warn or close")
+	maxConnsPerIP := flag.Int("max-conns-per-ip", 64, "concurrent connections per IP, 0 disables")
+	maxMessage := flag.Int64("max-message", DefaultMaxMessageSize, "max inbound message size in bytes, -1 disables")
+	compress := flag.Bool("compress", true, "negotiate permessage-deflate")
+	compressMin := flag.Int("compress-min", 512, "don't compress outbound messages smaller than this")
+	noCompressRooms := flag.String("no-compress-rooms", "", "comma-separated rooms whose messages are never compressed")
+	presence := flag.Bool("presence", true, "broadcast online/offline events and answer who queries")
+	presenceGrace := flag.Duration("presence-grace", DefaultPresenceGrace, "how long a user may reconnect before going offline")
+	fallback := flag.Bool("fallback", true, "serve /sse, /poll and /send for clients that cannot upgrade")
+	recordPath := flag.String("record", "", "append every frame to this file for wsreplay")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate file; enables wss://")
+	tlsKey := flag.String("tls-key", "", "PEM private key file for -tls-cert")
+	clientCA := flag.String("tls-client-ca", "", "PEM CA bundle for client certificates; enables mTLS")
+	requireClientCert := flag.Bool("tls-require-client-cert", false, "reject TLS handshakes without a client certificate")
+	tlsReload := flag.Duration("tls-reload", DefaultTLSReload, "how often certificate files are checked for changes, negative disables")
+	flag.Parse()
 
-	log.Println("WebSocket server listening on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	overflowPolicy, err := ParseOverflowPolicy(*overflow)
+	if err != nil {
+		log.Fatal(err)
+	}
+	action, err := ParseLimitAction(*limitAction)
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	if *brokerAddr != "" {
+		ln, err := net.Listen("tcp", *brokerAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("backplane broker listening on", *brokerAddr)
+		go NewBackplaneBroker().Serve(ln)
+	}
+
+	compressRooms := make(map[string]CompressionMode)
+	for _, room := range splitList(*noCompressRooms) {
+		compressRooms[room] = CompressNever
+	}
+
+	if (*clientCA != "" || *tlsKey != "") && *tlsCert == "" {
+		log.Fatal("-tls-key and -tls-client-ca require -tls-cert")
+	}
+
+	var recorder *wsrecord.Writer
+	if *recordPath != "" {
+		recorder, err = wsrecord.Create(*recordPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer recorder.Close()
+		log.Println("recording traffic to", *recordPath)
+	}
+
+	var backplane Backplane
+	if *backplaneAddr != "" {
+		backplane = NewTCPBackplane(*backplaneAddr)
+	}
+
+	// при заданном секрете требуем HS256‑JWT в заголовке, ?token= или подпротоколе.
+	var auth Authenticator
+	if secret := os.Getenv("WS_JWT_SECRET"); secret != "" {
+		auth = &BearerAuth{
+			Sources:  DefaultTokenSources(),
+			Verifier: &JWTVerifier{Key: []byte(secret)},
+		}
+	}
+	// с mTLS субъект берётся из клиентского сертификата, а без него — из токена.
+	if *clientCA != "" {
+		if auth != nil {
+			auth = AnyOf(ClientCertAuth{}, auth)
+		} else {
+			auth = ClientCertAuth{}
+		}
+	}
+
+	// admin API только с токеном оператора.
+	var admin AdminConfig
+	if token := os.Getenv("WS_ADMIN_TOKEN"); token != "" {
+		admin.Auth = &BearerAuth{
+			Sources:  []TokenExtractor{FromHeader("Authorization")},
+			Verifier: StaticTokens{token: {Subject: "operator", Roles: []string{DefaultAdminRole}}},
+		}
+	}
+
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{
+			AllowedOrigins:     splitList(*origins),
+			AllowMissingOrigin: *allowNoOrigin,
+			Subprotocols:       Subprotocols,
+		},
+		Hub: HubConfig{
+			Heartbeat: HeartbeatConfig{
+				PingInterval:   *pingInterval,
+				WriteWait:      DefaultHeartbeat.WriteWait,
+				MaxMissedPongs: *maxMissed,
+			},
+			Queue: QueueConfig{
+				Size:     *queueSize,
+				Overflow: overflowPolicy,
+			},
+			Backplane:      backplane,
+			Recorder:       recorder,
+			MaxMessageSize: *maxMessage,
+			Compression: CompressionConfig{
+				Enabled: *compress,
+				MinSize: *compressMin,
+				Rooms:   compressRooms,
+			},
+		},
+		Auth:         auth,
+		DrainTimeout: *drain,
+		Sessions: SessionConfig{
+			ReplaySize: *replay,
+			TTL:        *sessionTTL,
+		},
+		Presence: PresenceConfig{
+			Enabled: *presence,
+			Grace:   *presenceGrace,
+		},
+		Fallback: FallbackConfig{Enabled: *fallback},
+		Admin:    admin,
+		TLS: TLSConfig{
+			CertFile:          *tlsCert,
+			KeyFile:           *tlsKey,
+			ClientCAFile:      *clientCA,
+			RequireClientCert: *requireClientCert,
+			ReloadInterval:    *tlsReload,
+		},
+		RateLimit: RateLimitConfig{
+			PerConn: MessageLimits{
+				Messages: Rate{PerSecond: *msgRate, Burst: 2 * *msgRate},
+				Bytes:    Rate{PerSecond: *byteRate, Burst: 2 * *byteRate},
+			},
+			PerIP: MessageLimits{
+				Messages: Rate{PerSecond: *ipMsgRate, Burst: 2 * *ipMsgRate},
+				Bytes:    Rate{PerSecond: *ipByteRate, Burst: 2 * *ipByteRate},
+			},
+			Action:        action,
+			MaxConnsPerIP: *maxConnsPerIP,
+		},
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		if *tlsCert != "" {
+			log.Println("WebSocket server listening with TLS on", *addr)
+			errc <- srv.ListenAndServeTLS(*addr)
+			return
+		}
+		log.Println("WebSocket server listening on", *addr)
+		errc <- srv.ListenAndServe(*addr)
+	}()
+
+	select {
+	case err := <-errc:
+		// не log.Fatal: запись нужно закрыть, а defer при os.Exit не сработает.
+		log.Print(err)
+		if recorder != nil {
+			recorder.Close()
+		}
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	log.Println("shutting down")
+	// запас сверху на закрытие листенеров и принудительный разрыв.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *drain+5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("shutdown error:", err)
+	}
+	log.Println("server stopped")
 }
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TypeSession — первое сообщение после подключения: ID сессии и итог resume.
+const TypeSession = "session"
+
+// CloseSessionTakenOver — код закрытия старого соединения, когда ту же
+// сессию продолжило новое (диапазон 4000–4999 отдан приложениям).
+const CloseSessionTakenOver = 4001
+
+// SessionConfig — настройки возобновляемых сессий.
+type SessionConfig struct {
+	// ReplaySize — сколько последних сообщений хранить для докачки;
+	// 0 отключает сессии.
+	ReplaySize int
+	// TTL — сколько сессия живёт без соединения.
+	TTL time.Duration
+}
+
+func (sc SessionConfig) enabled() bool { return sc.ReplaySize > 0 }
+
+// SessionInfo — payload сообщения TypeSession.
+type SessionInfo struct {
+	ID       string `json:"id" proto:"1"`
+	Resumed  bool   `json:"resumed" proto:"2"`
+	Replayed int    `json:"replayed" proto:"3"`
+	// Seq — последний выданный номер; клиент присылает его как last_seq.
+	Seq uint64 `json:"seq" proto:"4"`
+}
+
+// sessionFrame — сообщение в буфере докачки.
+type sessionFrame struct {
+	seq uint64
+	msg outbound
+}
+
+// Session переживает обрывы соединения: нумерует исходящие сообщения
+// и хранит последние ReplaySize из них.
+//
+// anchor — клиент, который сейчас числится в комнатах хаба. После обрыва
+// он остаётся в комнатах «якорем», и рассылки продолжают попадать в буфер.
+// При resume комнаты переносятся на новое соединение.
+type Session struct {
+	id      string
+	subject string
+	store   *SessionStore
+
+	mu       sync.Mutex
+	seq      uint64
+	buf      []sessionFrame // кольцевой буфер
+	head     int            // индекс самого старого кадра
+	anchor   *Client
+	attached bool
+	expiry   *time.Timer
+}
+
+// deliver нумерует кадр, кладёт его в буфер и, если соединение живо,
+// ставит в очередь клиента.
+func (s *Session) deliver(c *Client, msg outbound) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.anchor != c {
+		return false
+	}
+
+	s.seq++
+	if msg.mt == c.codec.frameType() {
+		msg.data = c.codec.stampSeq(msg.data, s.seq)
+	}
+	frame := sessionFrame{seq: s.seq, msg: msg}
+	if len(s.buf) < cap(s.buf) {
+		s.buf = append(s.buf, frame)
+	} else {
+		s.buf[s.head] = frame
+		s.head = (s.head + 1) % len(s.buf)
+	}
+
+	if !s.attached {
+		return true
+	}
+	return c.enqueue(msg)
+}
+
+// since возвращает кадры с seq > last. ok=false, если часть разрыва
+// уже вытеснена из буфера и докачать его целиком нельзя.
+func (s *Session) since(last uint64) (frames []sessionFrame, ok bool) {
+	if last > s.seq {
+		return nil, false
+	}
+	n := len(s.buf)
+	if n > 0 && s.buf[s.head].seq > last+1 {
+		return nil, false
+	}
+	if n == 0 && last < s.seq {
+		return nil, false
+	}
+
+	for i := 0; i < n; i++ {
+		f := s.buf[(s.head+i)%n]
+		if f.seq > last {
+			frames = append(frames, f)
+		}
+	}
+	return frames, true
+}
+
+// stampSeq вписывает "seq" в начало JSON‑объекта конверта. Бинарные
+// кадры и не‑объекты не трогаем.
+func stampSeq(mt int, data []byte, seq uint64) []byte {
+	if mt != websocket.TextMessage || len(data) < 2 || data[0] != '{' {
+		return data
+	}
+
+	out := make([]byte, 0, len(data)+24)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	if data[1] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...)
+}
+
+// SessionStore хранит сессии и отвечает за resume и истечение.
+type SessionStore struct {
+	cfg SessionConfig
+	hub *Hub
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessionStore создаёт хранилище сессий для хаба.
+func NewSessionStore(hub *Hub, cfg SessionConfig) *SessionStore {
+	return &SessionStore{
+		cfg:      cfg,
+		hub:      hub,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Attach привязывает только что зарегистрированного клиента к сессии.
+// Если id известен, субъект совпадает и разрыв после lastSeq целиком есть
+// в буфере, сессия продолжается и клиент получает пропущенное. Иначе
+// создаётся новая сессия. Первым кадром клиенту уходит TypeSession.
+func (st *SessionStore) Attach(c *Client, id string, lastSeq uint64) *Session {
+	if id != "" {
+		st.mu.Lock()
+		s := st.sessions[id]
+		st.mu.Unlock()
+
+		if s != nil && s.subject == subjectOf(c) {
+			if st.resume(s, c, lastSeq) {
+				return s
+			}
+		}
+	}
+
+	s := &Session{
+		id:       newSessionID(),
+		subject:  subjectOf(c),
+		store:    st,
+		buf:      make([]sessionFrame, 0, st.cfg.ReplaySize),
+		anchor:   c,
+		attached: true,
+	}
+	c.session = s
+
+	st.mu.Lock()
+	st.sessions[s.id] = s
+	st.mu.Unlock()
+
+	// приветствие идёт мимо сессии: его не нумеруем и не докачиваем.
+	info, _ := c.codec.encode(TypeSession, "", SessionInfo{ID: s.id})
+	c.enqueue(outbound{mt: c.codec.frameType(), data: info})
+	return s
+}
+
+func (st *SessionStore) resume(s *Session, c *Client, lastSeq uint64) bool {
+	s.mu.Lock()
+	if s.anchor == nil {
+		// сессия как раз истекла.
+		s.mu.Unlock()
+		return false
+	}
+	if s.anchor.codec != c.codec {
+		// буфер докачки закодирован для прежнего подпротокола.
+		s.mu.Unlock()
+		log.Printf("session %s: cannot resume over %s, started over %s", s.id, c.codec.name(), s.anchor.codec.name())
+		return false
+	}
+	frames, ok := s.since(lastSeq)
+	if !ok {
+		s.mu.Unlock()
+		log.Printf("session %s: cannot resume from seq %d, starting over", s.id, lastSeq)
+		return false
+	}
+
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	old, wasAttached := s.anchor, s.attached
+
+	c.session = s
+	rooms := st.hub.transfer(old, c)
+	s.anchor = c
+	s.attached = true
+
+	// под s.mu: ни одно новое сообщение не обгонит докачку.
+	info, _ := c.codec.encode(TypeSession, "", SessionInfo{
+		ID: s.id, Resumed: true, Replayed: len(frames), Seq: s.seq,
+	})
+	c.enqueue(outbound{mt: c.codec.frameType(), data: info})
+	for _, f := range frames {
+		c.enqueue(f.msg)
+	}
+	s.mu.Unlock()
+
+	// announce присутствия доходит до c через s.deliver, поэтому не под s.mu.
+	st.hub.movePresence(old, c, rooms)
+
+	if wasAttached {
+		// старое соединение ещё не заметило обрыв — закрываем его сами.
+		go old.CloseWith(CloseSessionTakenOver, "session resumed elsewhere")
+	}
+	log.Printf("session %s: resumed by client %s, replayed %d", s.id, c.id, len(frames))
+	return true
+}
+
+// detach вызывается при обрыве соединения. Возвращает false, если клиент
+// уже не владеет сессией и его надо снять с регистрации как обычно.
+func (s *Session) detach(c *Client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.anchor != c {
+		return false
+	}
+	s.attached = false
+	s.expiry = time.AfterFunc(s.store.cfg.TTL, func() { s.store.expire(s) })
+	return true
+}
+
+// ID возвращает идентификатор сессии.
+func (s *Session) ID() string { return s.id }
+
+func (st *SessionStore) expire(s *Session) {
+	s.mu.Lock()
+	if s.attached {
+		s.mu.Unlock()
+		return
+	}
+	anchor := s.anchor
+	s.anchor = nil
+	s.mu.Unlock()
+
+	st.mu.Lock()
+	delete(st.sessions, s.id)
+	st.mu.Unlock()
+
+	st.hub.Unregister(anchor)
+}
+
+// end завершает сессию без права на resume: выгнанный оператором клиент
+// не должен вернуться, просто переподключившись.
+func (st *SessionStore) end(s *Session) {
+	s.mu.Lock()
+	s.anchor = nil
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+	s.mu.Unlock()
+
+	st.mu.Lock()
+	delete(st.sessions, s.id)
+	st.mu.Unlock()
+}
+
+// Len возвращает количество живых (в том числе отключённых) сессий.
+func (st *SessionStore) Len() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return len(st.sessions)
+}
+
+func subjectOf(c *Client) string {
+	if p := c.Principal(); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
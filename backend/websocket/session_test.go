@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readEnvelope читает и разбирает следующий JSON‑конверт.
+func readEnvelope(t *testing.T, conn *websocket.Conn) Envelope {
+	t.Helper()
+
+	var env Envelope
+	if err := json.Unmarshal([]byte(readText(t, conn)), &env); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// readSession читает приветствие TypeSession.
+func readSession(t *testing.T, conn *websocket.Conn) SessionInfo {
+	t.Helper()
+
+	env := readEnvelope(t, conn)
+	var info SessionInfo
+	if env.Type != TypeSession || json.Unmarshal(env.Payload, &info) != nil {
+		t.Fatalf("first frame = %+v, want %s", env, TypeSession)
+	}
+	return info
+}
+
+func TestSessionResume(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Sessions:  SessionConfig{ReplaySize: 3, TTL: time.Minute},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	news := func(n int) {
+		srv.Hub().Broadcast("news", websocket.TextMessage, fmt.Appendf(nil, `{"type":"news","payload":%d}`, n))
+	}
+
+	conn := dial(t, wsURL(ts, "/ws?room=news"), nil)
+	first := readSession(t, conn)
+	if first.ID == "" || first.Resumed {
+		t.Fatalf("new session = %+v", first)
+	}
+	news(1)
+	if env := readEnvelope(t, conn); env.Seq != 1 || string(env.Payload) != "1" {
+		t.Fatalf("got %+v, want seq 1", env)
+	}
+
+	// пока клиента нет, рассылки копятся в буфере сессии.
+	conn.Close()
+	news(2)
+	news(3)
+
+	url := wsURL(ts, "/ws?room=news&session="+first.ID+"&last_seq=1")
+	resumed := dial(t, url, nil)
+	info := readSession(t, resumed)
+	if info != (SessionInfo{ID: first.ID, Resumed: true, Replayed: 2, Seq: 3}) {
+		t.Fatalf("resume = %+v", info)
+	}
+	for want := uint64(2); want <= 3; want++ {
+		if env := readEnvelope(t, resumed); env.Seq != want || string(env.Payload) != fmt.Sprint(want) {
+			t.Errorf("replayed %+v, want seq %d", env, want)
+		}
+	}
+	// после докачки нумерация продолжается.
+	news(4)
+	if env := readEnvelope(t, resumed); env.Seq != 4 {
+		t.Errorf("live seq = %d, want 4", env.Seq)
+	}
+	if n := srv.Hub().Len(); n != 1 {
+		t.Errorf("Len = %d, want 1 (the anchor is transferred)", n)
+	}
+
+	// разрыв длиннее буфера докачать нельзя: начинается новая сессия.
+	resumed.Close()
+	for n := 5; n <= 8; n++ {
+		news(n)
+	}
+	again := dial(t, wsURL(ts, "/ws?room=news&session="+first.ID+"&last_seq=4"), nil)
+	if info := readSession(t, again); info.Resumed || info.ID == first.ID {
+		t.Errorf("gap larger than the buffer resumed: %+v", info)
+	}
+}
+
+func TestSessionResumeAfterGrace(t *testing.T) {
+	const grace = 20 * time.Millisecond
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Auth: AuthenticatorFunc(func(*http.Request) (*Principal, error) {
+			return &Principal{Subject: "alice"}, nil
+		}),
+		Sessions: SessionConfig{ReplaySize: 3, TTL: time.Minute},
+		Presence: PresenceConfig{Enabled: true, Grace: grace},
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	conn := dial(t, wsURL(ts, "/ws?room=chat"), nil)
+	first := readSession(t, conn)
+	if env := readEnvelope(t, conn); env.Type != TypePresence {
+		t.Fatalf("got %+v, want own presence", env)
+	}
+	conn.Close()
+	// grace истёк: alice уже offline, и resume объявит её online заново —
+	// через Broadcast в ту же сессию, что сейчас возобновляется.
+	waitFor(t, "alice offline", func() bool { return len(srv.Hub().presence.Who("chat")) == 0 })
+
+	done := make(chan *websocket.Conn, 1)
+	go func() {
+		d := websocket.Dialer{HandshakeTimeout: time.Second}
+		c, _, err := d.Dial(wsURL(ts, "/ws?session="+first.ID+"&last_seq=1"), nil)
+		if err != nil {
+			t.Errorf("resume dial: %v", err)
+		}
+		done <- c
+	}()
+	var resumed *websocket.Conn
+	select {
+	case resumed = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("resume hangs")
+	}
+	if resumed == nil {
+		return
+	}
+	defer resumed.Close()
+
+	if info := readSession(t, resumed); !info.Resumed || info.Replayed != 1 {
+		t.Fatalf("resume = %+v", info)
+	}
+	// докачивается её же offline, затем приходит новый online.
+	for _, want := range []string{StatusOffline, StatusOnline} {
+		var ev PresenceEvent
+		env := readEnvelope(t, resumed)
+		if env.Type != TypePresence || json.Unmarshal(env.Payload, &ev) != nil || ev.User != "alice" || ev.Status != want {
+			t.Errorf("got %+v, want alice %s", env, want)
+		}
+	}
+}
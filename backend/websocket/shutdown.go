@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultDrainTimeout — сколько ждать ответных close‑кадров от клиентов.
+const DefaultDrainTimeout = 10 * time.Second
+
+// ErrServerClosed возвращается Serve после Shutdown.
+var ErrServerClosed = http.ErrServerClosed
+
+// ListenAndServe слушает addr и обслуживает запросы до Shutdown.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve обслуживает запросы на ln до Shutdown.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.httpServer == nil {
+		s.httpServer = &http.Server{Handler: s.Handler()}
+	}
+	srv := s.httpServer
+	s.mu.Unlock()
+
+	return srv.Serve(ln)
+}
+
+// Shutdown корректно останавливает сервер:
+//  1. перестаёт принимать новые апгрейды (503);
+//  2. отправляет всем клиентам close 1001 "going away", а клиентов
+//     SSE и long-poll отключает сразу;
+//  3. закрывает листенеры и ждёт завершения HTTP‑запросов;
+//  4. ждёт, пока WebSocket‑клиенты ответят, но не дольше DrainTimeout или ctx;
+//  5. принудительно закрывает оставшиеся соединения.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !s.draining.CompareAndSwap(false, true) {
+		return errors.New("shutdown already in progress")
+	}
+
+	s.mu.Lock()
+	srv := s.httpServer
+	s.mu.Unlock()
+
+	// http.Server не отслеживает захваченные (hijacked) соединения,
+	// поэтому WebSocket‑клиентов закрываем сами. Обработчики SSE и
+	// long-poll завершаются только с закрытием клиента, так что это
+	// нужно сделать до srv.Shutdown: иначе он ждал бы их весь ctx.
+	n := s.hub.Len()
+	log.Printf("shutdown: sending going away to %d clients", n)
+	s.hub.CloseAll(websocket.CloseGoingAway, "server shutting down")
+
+	var httpErr error
+	if srv != nil {
+		httpErr = srv.Shutdown(ctx)
+	}
+
+	drain := s.cfg.DrainTimeout
+	if drain <= 0 {
+		drain = DefaultDrainTimeout
+	}
+	drainCtx, cancel := context.WithTimeout(ctx, drain)
+	defer cancel()
+
+	if err := s.hub.Wait(drainCtx); err != nil {
+		left := s.hub.Len()
+		log.Printf("shutdown: drain timeout, force-closing %d clients", left)
+		s.hub.ForceCloseAll()
+	}
+
+	if bp := s.cfg.Hub.Backplane; bp != nil {
+		bp.Close()
+	}
+	return httpErr
+}
+
+// Draining сообщает, что сервер в процессе остановки.
+func (s *Server) Draining() bool { return s.draining.Load() }
+
+// CloseAll отправляет каждому клиенту close‑кадр, не разрывая TCP:
+// клиент ответит своим close, и readPump снимет его с регистрации.
+func (h *Hub) CloseAll(code int, reason string) {
+	for _, c := range h.snapshot() {
+		go func(c *Client) {
+			if err := c.sendCloseFrame(code, reason); err != nil {
+				c.Close()
+			}
+		}(c)
+	}
+}
+
+// ForceCloseAll рвёт все оставшиеся соединения без ожидания.
+func (h *Hub) ForceCloseAll() {
+	for _, c := range h.snapshot() {
+		h.Unregister(c)
+	}
+}
+
+// Wait ждёт, пока в хабе не останется клиентов, или отмены ctx.
+func (h *Hub) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for h.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (h *Hub) snapshot() []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serve запускает srv на случайном порту и возвращает адрес и результат Serve.
+func serve(t *testing.T, srv *Server) (string, <-chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	return ln.Addr().String(), served
+}
+
+// closeCode читает conn до ошибки и возвращает код close‑кадра.
+func closeCode(conn *websocket.Conn) int {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		var ce *websocket.CloseError
+		if errors.As(err, &ce) {
+			return ce.Code
+		}
+		if err != nil {
+			return -1
+		}
+	}
+}
+
+func TestShutdownGoingAway(t *testing.T) {
+	srv := NewServer(Config{Handshake: HandshakePolicy{AllowMissingOrigin: true}})
+	addr, served := serve(t, srv)
+
+	conns := []*websocket.Conn{
+		dial(t, "ws://"+addr+"/ws", nil),
+		dial(t, "ws://"+addr+"/ws?room=news", nil),
+	}
+	waitFor(t, "two clients", func() bool { return srv.Hub().Len() == 2 })
+
+	codes := make(chan int, len(conns))
+	for _, conn := range conns {
+		// ReadMessage сам отвечает на close‑кадр сервера.
+		go func() { codes <- closeCode(conn) }()
+	}
+
+	start := time.Now()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	// клиенты ответили, ждать DrainTimeout не пришлось.
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Shutdown took %v", d)
+	}
+	for range conns {
+		if code := <-codes; code != websocket.CloseGoingAway {
+			t.Errorf("close code = %d, want %d", code, websocket.CloseGoingAway)
+		}
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, want ErrServerClosed", err)
+	}
+	if srv.Hub().Len() != 0 {
+		t.Errorf("Len = %d after Shutdown", srv.Hub().Len())
+	}
+	if err := srv.Shutdown(context.Background()); err == nil {
+		t.Error("second Shutdown succeeded")
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake:    HandshakePolicy{AllowMissingOrigin: true},
+		DrainTimeout: 100 * time.Millisecond,
+	})
+	addr, _ := serve(t, srv)
+
+	// клиент не читает и потому не отвечает на close.
+	dial(t, "ws://"+addr+"/ws", nil)
+	waitFor(t, "client", func() bool { return srv.Hub().Len() == 1 })
+
+	start := time.Now()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > time.Second {
+		t.Errorf("Shutdown took %v, want about DrainTimeout", d)
+	}
+	if srv.Hub().Len() != 0 {
+		t.Errorf("client was not force-closed")
+	}
+}
+
+func TestDrainingRejectsUpgrades(t *testing.T) {
+	srv := NewServer(Config{Handshake: HandshakePolicy{AllowMissingOrigin: true}})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	srv.draining.Store(true)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(ts, "/ws"), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial while draining: %v, %v", resp, err)
+	}
+}
+
+func TestShutdownClosesStreams(t *testing.T) {
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Fallback:  FallbackConfig{Enabled: true},
+	})
+	addr, served := serve(t, srv)
+
+	sse, _ := openSSE(t, "http://"+addr+"/sse?room=news")
+	sse.open()
+	ws := dial(t, "ws://"+addr+"/ws?room=news", nil)
+	waitFor(t, "two clients", func() bool { return srv.Hub().Len() == 2 })
+	code := make(chan int, 1)
+	go func() { code <- closeCode(ws) }()
+
+	// SSE‑обработчик держал бы srv.Shutdown до истечения ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Shutdown took %v with an SSE client connected", d)
+	}
+	if c := <-code; c != websocket.CloseGoingAway {
+		t.Errorf("websocket close code = %d, want %d", c, websocket.CloseGoingAway)
+	}
+	// поток SSE закончился.
+	if _, err := sse.rd.ReadString('\n'); err == nil {
+		t.Error("SSE stream still open")
+	}
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v", err)
+	}
+}
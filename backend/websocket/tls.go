@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTLSReload — как часто проверять, не сменились ли файлы сертификатов.
+const DefaultTLSReload = 10 * time.Second
+
+// TLSConfig — сертификаты для wss://. Файлы перечитываются на лету:
+// новые рукопожатия получают новый сертификат, а уже открытые соединения
+// продолжают жить со старым — ротация никого не отключает.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile — PEM с CA клиентских сертификатов; пусто — mTLS выключен.
+	ClientCAFile string
+	// RequireClientCert отклоняет рукопожатие без клиентского сертификата.
+	// Иначе сертификат необязателен, но предъявленный проверяется.
+	RequireClientCert bool
+	// ReloadInterval — период проверки файлов. 0 — DefaultTLSReload,
+	// отрицательное значение отключает перезагрузку.
+	ReloadInterval time.Duration
+}
+
+func (cfg TLSConfig) reloadInterval() time.Duration {
+	if cfg.ReloadInterval == 0 {
+		return DefaultTLSReload
+	}
+	return cfg.ReloadInterval
+}
+
+func (cfg TLSConfig) files() []string {
+	files := []string{cfg.CertFile, cfg.KeyFile}
+	if cfg.ClientCAFile != "" {
+		files = append(files, cfg.ClientCAFile)
+	}
+	return files
+}
+
+// tlsState — загруженные сертификаты. Подменяется целиком, чтобы
+// рукопожатие не увидело сертификат от одной версии файлов, а CA от другой.
+type tlsState struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	// raw — содержимое файлов; по нему и замечаем изменения: mtime на
+	// некоторых ФС грубее, чем частота ротации в тестах и cert-manager.
+	raw [][]byte
+}
+
+// certReloader отдаёт рукопожатиям текущие сертификаты и следит за файлами.
+type certReloader struct {
+	cfg   TLSConfig
+	state atomic.Pointer[tlsState]
+}
+
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("tls: certificate and key files are required")
+	}
+	r := &certReloader{cfg: cfg}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload перечитывает файлы и, если они изменились, подменяет состояние.
+// При ошибке (например, новый сертификат уже записан, а ключ ещё нет)
+// остаётся прежнее состояние.
+func (r *certReloader) reload() (changed bool, err error) {
+	files := r.cfg.files()
+	raw := make([][]byte, len(files))
+	for i, name := range files {
+		if raw[i], err = os.ReadFile(name); err != nil {
+			return false, fmt.Errorf("tls: %w", err)
+		}
+	}
+	if old := r.state.Load(); old != nil && slices.EqualFunc(old.raw, raw, bytes.Equal) {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(raw[0], raw[1])
+	if err != nil {
+		return false, fmt.Errorf("tls: load %s: %w", r.cfg.CertFile, err)
+	}
+	st := &tlsState{cert: &cert, raw: raw}
+	if r.cfg.ClientCAFile != "" {
+		st.clientCAs = x509.NewCertPool()
+		if !st.clientCAs.AppendCertsFromPEM(raw[2]) {
+			return false, fmt.Errorf("tls: no certificates in %s", r.cfg.ClientCAFile)
+		}
+	}
+	r.state.Store(st)
+	return true, nil
+}
+
+// watch перечитывает файлы раз в ReloadInterval, пока не закрыт stop.
+func (r *certReloader) watch(stop <-chan struct{}) {
+	interval := r.cfg.reloadInterval()
+	if interval < 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// одну и ту же ошибку не повторяем в лог каждый тик.
+	var lastErr string
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := r.reload()
+			if err != nil {
+				if err.Error() != lastErr {
+					log.Printf("%v; keeping the previous certificate", err)
+					lastErr = err.Error()
+				}
+				continue
+			}
+			lastErr = ""
+			if changed {
+				log.Printf("tls: certificate reloaded, valid until %s", r.state.Load().cert.Leaf.NotAfter.Format(time.RFC3339))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tlsConfig собирает конфигурацию, которая на каждом рукопожатии берёт
+// актуальное состояние.
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			st := r.state.Load()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*st.cert},
+				// gorilla/websocket апгрейдит только HTTP/1.1: h2 не предлагаем.
+				NextProtos: []string{"http/1.1"},
+			}
+			if st.clientCAs != nil {
+				cfg.ClientCAs = st.clientCAs
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				if r.cfg.RequireClientCert {
+					cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ListenAndServeTLS слушает addr и обслуживает wss:// с сертификатами
+// из Config.TLS до Shutdown.
+func (s *Server) ListenAndServeTLS(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(ln)
+}
+
+// ServeTLS обслуживает TLS‑соединения на ln до Shutdown. Пока сервер
+// работает, файлы сертификатов перечитываются.
+func (s *Server) ServeTLS(ln net.Listener) error {
+	certs, err := newCertReloader(s.cfg.TLS)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go certs.watch(stop)
+
+	return s.Serve(tls.NewListener(ln, certs.tlsConfig()))
+}
+
+// ClientCertAuth берёт субъекта из проверенного клиентского сертификата
+// (mTLS). Без сертификата возвращает ErrNoCredentials, поэтому его удобно
+// ставить в AnyOf перед BearerAuth.
+type ClientCertAuth struct {
+	// Map переводит сертификат в Principal; nil — CertPrincipal.
+	Map func(cert *x509.Certificate) (*Principal, error)
+}
+
+func (a ClientCertAuth) Authenticate(r *http.Request) (*Principal, error) {
+	// только VerifiedChains: PeerCertificates при ClientAuth без проверки
+	// может прислать кто угодно.
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, ErrNoCredentials
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	if a.Map != nil {
+		return a.Map(leaf)
+	}
+	return CertPrincipal(leaf)
+}
+
+// CertPrincipal — отображение по умолчанию: субъект — CN, роли — OU,
+// в Claims — полный DN, издатель и серийный номер.
+func CertPrincipal(cert *x509.Certificate) (*Principal, error) {
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("client certificate has no common name")
+	}
+	return &Principal{
+		Subject: cert.Subject.CommonName,
+		Roles:   slices.Clone(cert.Subject.OrganizationalUnit),
+		Claims: map[string]any{
+			"dn":     cert.Subject.String(),
+			"issuer": cert.Issuer.String(),
+			"serial": cert.SerialNumber.String(),
+		},
+	}, nil
+}
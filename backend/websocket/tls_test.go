@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testCA — самоподписанный CA, выпускающий сертификаты для тестов.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// testSerial — серийные номера выпущенных в тестах сертификатов.
+var testSerial int64
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает серверный (client=false) или клиентский сертификат
+// и возвращает его и ключ в PEM.
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		tmpl.IPAddresses = nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveTLS запускает сервер на случайном порту и возвращает wss:// URL.
+func serveTLS(t *testing.T, srv *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(ln)
+	t.Cleanup(func() { srv.Shutdown(t.Context()) })
+	return "wss://" + ln.Addr().String()
+}
+
+func TestTLSClientCertPrincipal(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "server"}, false)
+	writeFile(t, filepath.Join(dir, "cert.pem"), certPEM)
+	writeFile(t, filepath.Join(dir, "key.pem"), keyPEM)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem)
+
+	joined := make(chan *Principal, 1)
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		Auth:      ClientCertAuth{},
+		AuthorizeJoin: func(p *Principal, room string) error {
+			joined <- p
+			return nil
+		},
+		TLS: TLSConfig{
+			CertFile:          filepath.Join(dir, "cert.pem"),
+			KeyFile:           filepath.Join(dir, "key.pem"),
+			ClientCAFile:      filepath.Join(dir, "ca.pem"),
+			RequireClientCert: true,
+		},
+	})
+	url := serveTLS(t, srv) + "/ws?room=r"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// без клиентского сертификата рукопожатие TLS не проходит.
+	d := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	if _, _, err := d.Dial(url, nil); err == nil {
+		t.Fatal("dial without client certificate succeeded")
+	}
+
+	// сертификат чужого CA тоже не принимается.
+	other := newTestCA(t)
+	badCert, badKey := other.issue(t, pkix.Name{CommonName: "mallory"}, true)
+	bad, err := tls.X509KeyPair(badCert, badKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.TLSClientConfig.Certificates = []tls.Certificate{bad}
+	if _, _, err := d.Dial(url, nil); err == nil {
+		t.Fatal("dial with a certificate from an unknown CA succeeded")
+	}
+
+	clientCert, clientKey := ca.issue(t, pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"ops"}}, true)
+	good, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.TLSClientConfig.Certificates = []tls.Certificate{good}
+	conn, _, err := d.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial with client certificate: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case p := <-joined:
+		if p == nil || p.Subject != "alice" || !slices.Equal(p.Roles, []string{"ops"}) {
+			t.Fatalf("principal = %+v, want alice with role ops", p)
+		}
+		if p.Claims["dn"] != "CN=alice,OU=ops" {
+			t.Errorf("dn claim = %v", p.Claims["dn"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not join")
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "server-1"}, false)
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	srv := NewServer(Config{
+		Handshake: HandshakePolicy{AllowMissingOrigin: true},
+		TLS:       TLSConfig{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 10 * time.Millisecond},
+	})
+	url := serveTLS(t, srv) + "/ws?room=r"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	d := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	served := func(conn *websocket.Conn) string {
+		tc := conn.NetConn().(*tls.Conn)
+		return tc.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	old, _, err := d.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	if cn := served(old); cn != "server-1" {
+		t.Fatalf("served %q, want server-1", cn)
+	}
+
+	// ключ без сертификата — полузаписанная ротация: остаётся старый сертификат.
+	certPEM, keyPEM = ca.issue(t, pkix.Name{CommonName: "server-2"}, false)
+	writeFile(t, keyFile, keyPEM)
+	time.Sleep(50 * time.Millisecond)
+	conn, _, err := d.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial during rotation: %v", err)
+	}
+	if cn := served(conn); cn != "server-1" {
+		t.Fatalf("served %q during rotation, want server-1", cn)
+	}
+	conn.Close()
+
+	writeFile(t, certFile, certPEM)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, _, err := d.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cn := served(conn)
+		conn.Close()
+		if cn == "server-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// соединение, открытое до ротации, продолжает работать.
+	old.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := old.WriteMessage(websocket.TextMessage, []byte(`{"type":"echo","id":"1","payload":"still here"}`)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, msg, err := old.ReadMessage()
+		if err != nil {
+			t.Fatalf("old connection: %v", err)
+		}
+		var env Envelope
+		if err := json.Unmarshal(msg, &env); err != nil {
+			t.Fatal(err)
+		}
+		if env.Type == TypeAck && env.ReplyTo == "1" {
+			break
+		}
+	}
+}
@@ -0,0 +1,519 @@
+// Package wsclient — Go‑клиент WebSocket‑сервера из backend/websocket.
+// Говорит тем же протоколом, что и client_example.ts: JSON‑конверты,
+// запросы с id и ответами по reply_to, resume сессии по ?session=&last_seq=
+// и переподключение с экспоненциальной задержкой и джиттером.
+//
+// Клиент же служит обвязкой для интеграционных тестов и нагрузочных
+// прогонов сервера.
+package wsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// значения по умолчанию совпадают с client_example.ts.
+const (
+	DefaultRequestTimeout = 5 * time.Second
+	DefaultBackoffBase    = 500 * time.Millisecond
+	DefaultBackoffMax     = 30 * time.Second
+	DefaultQueueSize      = 256
+)
+
+// служебные типы сообщений сервера.
+const (
+	TypeAck     = "ack"
+	TypeError   = "error"
+	TypeSession = "session"
+)
+
+// ErrClosed возвращается после Close.
+var ErrClosed = errors.New("wsclient: client closed")
+
+// Envelope — конверт сообщения, тот же, что Envelope в protocol.go.
+type Envelope struct {
+	Seq     uint64          `json:"seq,omitempty"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	ReplyTo string          `json:"reply_to,omitempty"`
+}
+
+// SessionInfo — первое сообщение сервера после подключения.
+type SessionInfo struct {
+	ID       string `json:"id"`
+	Resumed  bool   `json:"resumed"`
+	Replayed int    `json:"replayed"`
+	Seq      uint64 `json:"seq"`
+}
+
+// ProtocolError — ошибка, которую сервер вернул на запрос.
+type ProtocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Config — настройки клиента. Обязателен только URL.
+type Config struct {
+	// URL сервера, например ws://localhost:8080/ws?room=lobby.
+	URL    string
+	Header http.Header
+	// Dialer — nil означает websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+
+	// BackoffBase и BackoffMax — границы задержки между переподключениями.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// QueueSize — сколько исходящих сообщений копится, пока соединения нет.
+	QueueSize int
+	// RequestTimeout ограничивает Request, если у ctx нет своего дедлайна.
+	RequestTimeout time.Duration
+
+	// OnSession вызывается на каждое подключение, в том числе после resume.
+	OnSession func(SessionInfo)
+	// OnDisconnect вызывается при обрыве перед попыткой переподключения.
+	OnDisconnect func(err error)
+	// Logf — nil означает log.Printf.
+	Logf func(format string, args ...any)
+}
+
+func (cfg *Config) setDefaults() {
+	if cfg.Dialer == nil {
+		cfg.Dialer = websocket.DefaultDialer
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = DefaultBackoffBase
+	}
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = DefaultBackoffMax
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultRequestTimeout
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = log.Printf
+	}
+}
+
+// outbound — элемент исходящей очереди; closeFrame завершает работу.
+type outbound struct {
+	data       []byte
+	closeFrame bool
+}
+
+type reply struct {
+	env Envelope
+	err error
+}
+
+// Client — соединение с автоматическим переподключением. Методы
+// безопасны для вызова из нескольких горутин.
+type Client struct {
+	cfg Config
+
+	// out переживает переподключения: сообщения ждут в ней нового соединения.
+	out chan outbound
+	// retry — сообщение, запись которого оборвалась; трогает только writer.
+	retry *outbound
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	handlers  map[string]func(Envelope)
+	pending   map[string]chan reply
+	sessionID string
+	lastSeq   uint64
+
+	nextID    atomic.Uint64
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Dial подключается к серверу и запускает фоновый цикл чтения,
+// записи и переподключения. ctx ограничивает только первое подключение.
+func Dial(ctx context.Context, cfg Config) (*Client, error) {
+	cfg.setDefaults()
+	c := &Client{
+		cfg:      cfg,
+		out:      make(chan outbound, cfg.QueueSize),
+		handlers: make(map[string]func(Envelope)),
+		pending:  make(map[string]chan reply),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go c.run(conn)
+	return c, nil
+}
+
+// Handle подписывает обработчик на серверные сообщения типа typ.
+// Обработчики вызываются из горутины чтения: они не должны надолго
+// блокироваться и не могут синхронно ждать Request.
+func (c *Client) Handle(typ string, h func(Envelope)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers[typ] = h
+}
+
+// On подписывает типизированный обработчик: payload разбирается в T.
+// Сообщения, которые не удалось разобрать, логируются и пропускаются.
+func On[T any](c *Client, typ string, h func(T)) {
+	c.Handle(typ, func(env Envelope) {
+		var v T
+		if len(env.Payload) > 0 {
+			if err := json.Unmarshal(env.Payload, &v); err != nil {
+				c.cfg.Logf("wsclient: bad %s payload: %v", typ, err)
+				return
+			}
+		}
+		h(v)
+	})
+}
+
+// Send ставит сообщение в очередь без ожидания ответа (fire-and-forget).
+// Пока соединения нет, сообщение ждёт в очереди; если очередь полна,
+// Send блокируется до освобождения места или отмены ctx.
+func (c *Client) Send(ctx context.Context, typ string, payload any) error {
+	return c.send(ctx, Envelope{Type: typ}, payload)
+}
+
+// Request отправляет сообщение с id и ждёт ack или error с тем же
+// reply_to. Payload ответа разбирается в out, если он не nil. Ошибка
+// сервера возвращается как *ProtocolError.
+//
+// Ответ, пришедший во время обрыва, сервер докачает при resume; если
+// сессию восстановить не удалось, Request завершится по таймауту.
+func (c *Client) Request(ctx context.Context, typ string, payload, out any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.RequestTimeout)
+		defer cancel()
+	}
+
+	id := strconv.FormatUint(c.nextID.Add(1), 10)
+	ch := make(chan reply, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(ctx, Envelope{Type: typ, ID: id}, payload); err != nil {
+		return err
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return r.err
+		}
+		if r.env.Type == TypeError {
+			perr := &ProtocolError{}
+			if err := json.Unmarshal(r.env.Payload, perr); err != nil {
+				return err
+			}
+			return perr
+		}
+		if out != nil && len(r.env.Payload) > 0 {
+			return json.Unmarshal(r.env.Payload, out)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+func (c *Client) send(ctx context.Context, env Envelope, payload any) error {
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		env.Payload = raw
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(ctx, outbound{data: data})
+}
+
+func (c *Client) enqueue(ctx context.Context, msg outbound) error {
+	select {
+	case <-c.closing:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close отправляет накопленные сообщения, затем close‑кадр 1000 и ждёт,
+// пока сервер закроет соединение. Если ctx истекает раньше, соединение
+// рвётся без ожидания.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() { close(c.closing) })
+
+	// close‑кадр встаёт в очередь последним, после уже принятых сообщений.
+	var err error
+	select {
+	case c.out <- outbound{closeFrame: true}:
+		select {
+		case <-c.done:
+			return nil
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.mu.Unlock()
+	<-c.done
+	return err
+}
+
+// SessionID возвращает id текущей сессии; пусто, если сервер без сессий.
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.sessionID
+}
+
+// Done закрывается, когда клиент окончательно остановлен.
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// dial подключается, при наличии сессии — с просьбой докачать разрыв.
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	u, err := url.Parse(c.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.sessionID != "" {
+		q := u.Query()
+		q.Set("session", c.sessionID)
+		q.Set("last_seq", strconv.FormatUint(c.lastSeq, 10))
+		u.RawQuery = q.Encode()
+	}
+	c.mu.Unlock()
+
+	conn, _, err := c.cfg.Dialer.DialContext(ctx, u.String(), c.cfg.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	return conn, nil
+}
+
+// run обслуживает соединения до Close, переподключаясь после обрывов.
+func (c *Client) run(conn *websocket.Conn) {
+	defer close(c.done)
+	defer c.failPending(ErrClosed)
+
+	for {
+		err := c.serve(conn)
+		select {
+		case <-c.closing:
+			return
+		default:
+		}
+
+		c.cfg.Logf("wsclient: connection lost: %v", err)
+		if c.cfg.OnDisconnect != nil {
+			c.cfg.OnDisconnect(err)
+		}
+		if conn = c.reconnect(); conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect повторяет подключение с экспоненциальной задержкой и
+// джиттером, чтобы клиенты не ломились на сервер разом.
+// Возвращает nil, если клиент закрыли.
+func (c *Client) reconnect() *websocket.Conn {
+	for attempt := 0; ; attempt++ {
+		base := min(c.cfg.BackoffMax, c.cfg.BackoffBase<<min(attempt, 30))
+		delay := base/2 + rand.N(base/2+1)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-c.closing:
+			timer.Stop()
+			return nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-c.closing:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		conn, err := c.dial(ctx)
+		cancel()
+		if err == nil {
+			return conn
+		}
+		c.cfg.Logf("wsclient: reconnect attempt %d failed: %v", attempt+1, err)
+	}
+}
+
+// serve читает кадры, пока соединение живо; writer работает рядом.
+func (c *Client) serve(conn *websocket.Conn) error {
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop(conn, stop)
+	}()
+	defer func() {
+		close(stop)
+		conn.Close()
+		<-writerDone
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			c.cfg.Logf("wsclient: malformed message: %v", err)
+			continue
+		}
+		c.dispatch(env)
+	}
+}
+
+func (c *Client) writeLoop(conn *websocket.Conn, stop <-chan struct{}) {
+	for {
+		var msg outbound
+		if c.retry != nil {
+			msg, c.retry = *c.retry, nil
+		} else {
+			select {
+			case msg = <-c.out:
+			case <-stop:
+				return
+			}
+		}
+
+		if msg.closeFrame {
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "client disconnect")
+			if err := conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second)); err != nil {
+				conn.Close()
+			}
+			return
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, msg.data); err != nil {
+			// не потеряем сообщение: оно уйдёт первым после переподключения.
+			c.retry = &msg
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (c *Client) dispatch(env Envelope) {
+	c.mu.Lock()
+	if env.Seq > 0 {
+		c.lastSeq = env.Seq
+	}
+
+	if env.Type == TypeSession {
+		var info SessionInfo
+		if err := json.Unmarshal(env.Payload, &info); err != nil {
+			c.mu.Unlock()
+			c.cfg.Logf("wsclient: bad session payload: %v", err)
+			return
+		}
+		c.sessionID = info.ID
+		if !info.Resumed {
+			// новая сессия: старую докачать не удалось, начинаем с нуля.
+			c.lastSeq = info.Seq
+		}
+		c.mu.Unlock()
+
+		if c.cfg.OnSession != nil {
+			c.cfg.OnSession(info)
+		}
+		return
+	}
+
+	if env.ReplyTo != "" {
+		if ch, ok := c.pending[env.ReplyTo]; ok {
+			delete(c.pending, env.ReplyTo)
+			c.mu.Unlock()
+			ch <- reply{env: env}
+			return
+		}
+	}
+
+	h := c.handlers[env.Type]
+	c.mu.Unlock()
+	if h != nil {
+		h(env)
+	}
+}
+
+func (c *Client) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, ch := range c.pending {
+		ch <- reply{err: err}
+		delete(c.pending, id)
+	}
+}
@@ -0,0 +1,256 @@
+package wsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// message — payload рассылки "message", как RoomMessage сервера.
+type message struct {
+	Room string          `json:"room"`
+	From string          `json:"from,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+// fakeServer говорит протоколом сервера в объёме, нужном клиенту: одна
+// сессия "s1" с буфером докачки, publish только в комнату lobby.
+type fakeServer struct {
+	ts      *httptest.Server
+	queries chan url.Values
+
+	mu     sync.Mutex
+	conns  map[*websocket.Conn]bool
+	seq    uint64
+	missed [][]byte // рассылки, пока клиента нет
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	s := &fakeServer{queries: make(chan url.Values, 8), conns: make(map[*websocket.Conn]bool)}
+	s.ts = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.ts.Close)
+	return s
+}
+
+func (s *fakeServer) url() string {
+	return "ws" + strings.TrimPrefix(s.ts.URL, "http") + "/ws?room=lobby"
+}
+
+func (s *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	var upgrader websocket.Upgrader
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	q := r.URL.Query()
+	s.queries <- q
+
+	s.mu.Lock()
+	info := SessionInfo{ID: "s1", Seq: s.seq}
+	if q.Get("session") == "s1" {
+		info.Resumed, info.Replayed = true, len(s.missed)
+	}
+	s.write(conn, Envelope{Type: TypeSession}, info)
+	if info.Resumed {
+		for _, frame := range s.missed {
+			conn.WriteMessage(websocket.TextMessage, frame)
+		}
+	}
+	s.missed = nil
+	s.conns[conn] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		var env Envelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return
+		}
+		if env.Type != "publish" {
+			continue
+		}
+		var in message
+		json.Unmarshal(env.Payload, &in)
+
+		s.mu.Lock()
+		if in.Room != "lobby" {
+			s.write(conn, Envelope{Type: TypeError, ReplyTo: env.ID}, ProtocolError{Code: "forbidden", Message: "not a member"})
+		} else {
+			n := s.broadcast(message{Room: in.Room, From: "c", Data: in.Data})
+			s.write(conn, Envelope{Type: TypeAck, ReplyTo: env.ID}, map[string]int{"delivered": n})
+		}
+		s.mu.Unlock()
+	}
+}
+
+// write отправляет конверт с payload; вызывается под s.mu.
+func (s *fakeServer) write(conn *websocket.Conn, env Envelope, payload any) {
+	env.Payload, _ = json.Marshal(payload)
+	conn.WriteJSON(env)
+}
+
+// broadcast нумерует рассылку и отдаёт её всем соединениям, а без них —
+// в буфер докачки; вызывается под s.mu.
+func (s *fakeServer) broadcast(m message) int {
+	s.seq++
+	payload, _ := json.Marshal(m)
+	frame, _ := json.Marshal(Envelope{Seq: s.seq, Type: "message", Payload: payload})
+	if len(s.conns) == 0 {
+		s.missed = append(s.missed, frame)
+	}
+	for conn := range s.conns {
+		conn.WriteMessage(websocket.TextMessage, frame)
+	}
+	return len(s.conns)
+}
+
+// kill рвёт соединения без close‑кадра.
+func (s *fakeServer) kill() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+func dialClient(t *testing.T, url string, cfg Config) *Client {
+	t.Helper()
+
+	cfg.URL = url
+	if cfg.BackoffBase == 0 {
+		cfg.BackoffBase = 10 * time.Millisecond
+	}
+	cfg.Logf = t.Logf
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := Dial(ctx, cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		c.Close(ctx)
+	})
+	return c
+}
+
+func TestRequests(t *testing.T) {
+	s := newFakeServer(t)
+	c := dialClient(t, s.url(), Config{})
+	got := make(chan message, 1)
+	On(c, "message", func(m message) { got <- m })
+
+	ctx := context.Background()
+	var res struct {
+		Delivered int `json:"delivered"`
+	}
+	if err := c.Request(ctx, "publish", message{Room: "lobby", Data: json.RawMessage(`"hi"`)}, &res); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if res.Delivered != 1 {
+		t.Errorf("delivered = %d, want 1", res.Delivered)
+	}
+	select {
+	case m := <-got:
+		if m.Room != "lobby" || string(m.Data) != `"hi"` {
+			t.Errorf("message = %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("room message not received")
+	}
+
+	err := c.Request(ctx, "publish", message{Room: "other", Data: json.RawMessage(`1`)}, nil)
+	var perr *ProtocolError
+	if !errors.As(err, &perr) || perr.Code != "forbidden" {
+		t.Fatalf("publish to foreign room: err = %v, want forbidden", err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := newFakeServer(t)
+	sessions := make(chan SessionInfo, 2)
+	lost := make(chan error, 1)
+	c := dialClient(t, s.url(), Config{
+		OnSession:    func(info SessionInfo) { sessions <- info },
+		OnDisconnect: func(err error) { lost <- err },
+		// переподключение не раньше чем через 100ms: успеваем разослать
+		// сообщение, пока клиента нет.
+		BackoffBase: 200 * time.Millisecond,
+	})
+	got := make(chan message, 4)
+	On(c, "message", func(m message) { got <- m })
+
+	first := <-sessions
+	if first.Resumed {
+		t.Fatalf("first session resumed: %+v", first)
+	}
+	<-s.queries
+
+	s.mu.Lock()
+	s.broadcast(message{Room: "lobby", Data: json.RawMessage(`"seen"`)})
+	s.mu.Unlock()
+	if m := <-got; string(m.Data) != `"seen"` {
+		t.Fatalf("data = %s", m.Data)
+	}
+
+	// рвём соединение и рассылаем, пока клиента нет.
+	s.kill()
+	s.mu.Lock()
+	s.broadcast(message{Room: "lobby", Data: json.RawMessage(`"missed"`)})
+	s.mu.Unlock()
+
+	// отправленное во время обрыва уходит после переподключения.
+	// Ждём, пока клиент заметит обрыв: запись в полузакрытый сокет
+	// может «успеть» и потеряться.
+	<-lost
+	if err := c.Send(context.Background(), "publish", message{Room: "lobby", Data: json.RawMessage(`"queued"`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case q := <-s.queries:
+		// клиент просит докачку после последнего полученного seq.
+		if q.Get("session") != first.ID || q.Get("last_seq") != "1" || q.Get("room") != "lobby" {
+			t.Errorf("reconnect query = %v", q)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+	select {
+	case info := <-sessions:
+		if !info.Resumed || info.ID != first.ID || info.Replayed != 1 {
+			t.Fatalf("resume = %+v, want resumed %s with 1 replayed", info, first.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no session after reconnect")
+	}
+
+	for _, want := range []string{`"missed"`, `"queued"`} {
+		select {
+		case m := <-got:
+			if string(m.Data) != want {
+				t.Errorf("data = %s, want %s", m.Data, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %s not received", want)
+		}
+	}
+}
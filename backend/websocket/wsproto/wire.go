@@ -0,0 +1,413 @@
+// Package wsproto — минимальный protobuf без генерации кода: сообщения
+// описываются обычными Go‑структурами, а номера полей задаёт тег proto:
+//
+//	type RoomMessage struct {
+//		Room string `json:"room" proto:"1"`
+//		From string `json:"from" proto:"2"`
+//		Data []byte `json:"data" proto:"3"`
+//	}
+//
+// Кодирование совпадает с proto3: целые — varint (int64 без zigzag),
+// float32/float64 — fixed32/fixed64, string и []byte — length-delimited,
+// вложенные структуры — вложенные сообщения, срезы — repeated. Нулевые
+// значения не пишутся, неизвестные поля при разборе пропускаются, так что
+// со схемой на клиенте (.proto и protoc) формат совместим.
+//
+// Поля без тега proto в сообщение не попадают. Тип поля может сам задать
+// своё представление в виде bytes, реализовав Marshaler и Unmarshaler.
+package wsproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// типы кодирования protobuf (wire types).
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// ErrTruncated — сообщение оборвано посреди поля.
+var ErrTruncated = errors.New("wsproto: truncated message")
+
+// Marshaler — значение, которое кодируется как поле bytes с тем
+// содержимым, что вернёт MarshalProto. Пустой результат у одиночного поля
+// не пишется.
+type Marshaler interface {
+	MarshalProto() ([]byte, error)
+}
+
+// Unmarshaler получает содержимое поля bytes. Срез принадлежит входному
+// сообщению: чтобы сохранить данные, их нужно скопировать.
+type Unmarshaler interface {
+	UnmarshalProto([]byte) error
+}
+
+var (
+	marshalerType   = reflect.TypeFor[Marshaler]()
+	unmarshalerType = reflect.TypeFor[Unmarshaler]()
+)
+
+// custom — тип кодируется своими методами, а не по виду.
+func custom(t reflect.Type) bool {
+	return t.Implements(marshalerType) && reflect.PointerTo(t).Implements(unmarshalerType)
+}
+
+// field — поле структуры, участвующее в кодировании.
+type field struct {
+	num      uint64
+	index    int
+	repeated bool
+	// kind — вид значения (элемента для repeated).
+	kind reflect.Kind
+}
+
+// fieldsCache: разбор тегов — самая дорогая часть, делаем его один раз на тип.
+var fieldsCache sync.Map // reflect.Type -> []field
+
+func fieldsOf(t reflect.Type) ([]field, error) {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field), nil
+	}
+
+	var fields []field
+	seen := make(map[uint64]string)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("proto")
+		if !ok || tag == "-" {
+			continue
+		}
+		num, err := strconv.ParseUint(tag, 10, 32)
+		if err != nil || num == 0 || num >= 1<<29 {
+			return nil, fmt.Errorf("wsproto: %s.%s: bad field number %q", t, sf.Name, tag)
+		}
+		if prev, dup := seen[num]; dup {
+			return nil, fmt.Errorf("wsproto: %s: fields %s and %s share number %d", t, prev, sf.Name, num)
+		}
+		seen[num] = sf.Name
+
+		f := field{num: num, index: i, kind: sf.Type.Kind()}
+		elem := sf.Type
+		if elem.Kind() == reflect.Slice && elem.Elem().Kind() != reflect.Uint8 && !custom(elem) {
+			f.repeated = true
+			elem = elem.Elem()
+			f.kind = elem.Kind()
+		}
+		if !supported(elem) && !custom(elem) {
+			return nil, fmt.Errorf("wsproto: %s.%s: unsupported type %s", t, sf.Name, sf.Type)
+		}
+		fields = append(fields, f)
+	}
+
+	fieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func supported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Struct:
+		return true
+	case reflect.Pointer:
+		return t.Elem().Kind() == reflect.Struct
+	}
+	return false
+}
+
+// Marshal кодирует структуру (или указатель на неё) в protobuf.
+func Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("wsproto: cannot marshal %T, want struct", v)
+	}
+	return appendMessage(nil, rv)
+}
+
+func appendMessage(b []byte, rv reflect.Value) ([]byte, error) {
+	fields, err := fieldsOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		if !f.repeated {
+			if b, err = appendField(b, f.num, fv, true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// repeated пишем поэлементно, нулевые элементы тоже: важна позиция.
+		for i := 0; i < fv.Len(); i++ {
+			if b, err = appendField(b, f.num, fv.Index(i), false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// appendField пишет одно значение. omitZero — proto3: нулевое
+// одиночное поле не пишется.
+func appendField(b []byte, num uint64, v reflect.Value, omitZero bool) ([]byte, error) {
+	if omitZero && v.IsZero() {
+		return b, nil
+	}
+	if custom(v.Type()) {
+		raw, err := v.Interface().(Marshaler).MarshalProto()
+		if err != nil || omitZero && len(raw) == 0 {
+			return b, err
+		}
+		b = appendTag(b, num, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(raw)))
+		return append(b, raw...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b = appendTag(b, num, wireVarint)
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b = appendTag(b, num, wireVarint)
+		return binary.AppendUvarint(b, uint64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b = appendTag(b, num, wireVarint)
+		return binary.AppendUvarint(b, v.Uint()), nil
+	case reflect.Float32:
+		b = appendTag(b, num, wireFixed32)
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		b = appendTag(b, num, wireFixed64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		b = appendTag(b, num, wireBytes)
+		b = binary.AppendUvarint(b, uint64(v.Len()))
+		return append(b, v.String()...), nil
+	case reflect.Slice:
+		b = appendTag(b, num, wireBytes)
+		b = binary.AppendUvarint(b, uint64(v.Len()))
+		return append(b, v.Bytes()...), nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return b, nil
+		}
+		return appendField(b, num, v.Elem(), false)
+	case reflect.Struct:
+		msg, err := appendMessage(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		b = appendTag(b, num, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(msg)))
+		return append(b, msg...), nil
+	}
+	return nil, fmt.Errorf("wsproto: unsupported kind %s", v.Kind())
+}
+
+func appendTag(b []byte, num uint64, wire int) []byte {
+	return binary.AppendUvarint(b, num<<3|uint64(wire))
+}
+
+// Unmarshal разбирает protobuf в структуру по указателю v. Поля, которых
+// нет в структуре, пропускаются; повторное одиночное поле перезаписывает
+// предыдущее, как в protobuf.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("wsproto: cannot unmarshal into %T, want pointer to struct", v)
+	}
+	return unmarshalMessage(data, rv.Elem())
+}
+
+func unmarshalMessage(data []byte, rv reflect.Value) error {
+	fields, err := fieldsOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrTruncated
+		}
+		data = data[n:]
+		num, wire := key>>3, int(key&7)
+
+		raw, rest, err := next(data, wire)
+		if err != nil {
+			return err
+		}
+		data = rest
+
+		f, ok := lookup(fields, num)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(f.index)
+		if f.repeated {
+			err = decodeRepeated(fv, wire, raw)
+		} else {
+			err = decodeValue(fv, wire, raw)
+		}
+		if err != nil {
+			return fmt.Errorf("wsproto: %s field %d: %w", rv.Type(), num, err)
+		}
+	}
+	return nil
+}
+
+func lookup(fields []field, num uint64) (field, bool) {
+	for _, f := range fields {
+		if f.num == num {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// next отрезает значение поля с данным wire type. Для varint и fixed
+// возвращаются сами байты значения, для length-delimited — содержимое.
+func next(data []byte, wire int) (raw, rest []byte, err error) {
+	switch wire {
+	case wireVarint:
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, ErrTruncated
+		}
+		return data[:n], data[n:], nil
+	case wireFixed64:
+		if len(data) < 8 {
+			return nil, nil, ErrTruncated
+		}
+		return data[:8], data[8:], nil
+	case wireFixed32:
+		if len(data) < 4 {
+			return nil, nil, ErrTruncated
+		}
+		return data[:4], data[4:], nil
+	case wireBytes:
+		l, n := binary.Uvarint(data)
+		if n <= 0 || l > uint64(len(data)-n) {
+			return nil, nil, ErrTruncated
+		}
+		end := n + int(l)
+		return data[n:end], data[end:], nil
+	}
+	// группы (3, 4) устарели ещё в proto2.
+	return nil, nil, fmt.Errorf("wsproto: unsupported wire type %d", wire)
+}
+
+func decodeRepeated(fv reflect.Value, wire int, raw []byte) error {
+	elem := fv.Type().Elem()
+	// упакованные числа (proto3 по умолчанию пишет repeated‑скаляры так).
+	if wire == wireBytes && packable(elem.Kind()) && !custom(elem) {
+		elemWire := wireVarint
+		switch elem.Kind() {
+		case reflect.Float32:
+			elemWire = wireFixed32
+		case reflect.Float64:
+			elemWire = wireFixed64
+		}
+		for len(raw) > 0 {
+			item, rest, err := next(raw, elemWire)
+			if err != nil {
+				return err
+			}
+			raw = rest
+			v := reflect.New(elem).Elem()
+			if err := decodeValue(v, elemWire, item); err != nil {
+				return err
+			}
+			fv.Set(reflect.Append(fv, v))
+		}
+		return nil
+	}
+
+	v := reflect.New(elem).Elem()
+	if err := decodeValue(v, wire, raw); err != nil {
+		return err
+	}
+	fv.Set(reflect.Append(fv, v))
+	return nil
+}
+
+func packable(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Struct, reflect.Pointer:
+		return false
+	}
+	return true
+}
+
+func decodeValue(v reflect.Value, wire int, raw []byte) error {
+	if custom(v.Type()) {
+		if wire != wireBytes {
+			return fmt.Errorf("wire type %d, want %d", wire, wireBytes)
+		}
+		return v.Addr().Interface().(Unmarshaler).UnmarshalProto(raw)
+	}
+
+	want := wireVarint
+	switch v.Kind() {
+	case reflect.Float32:
+		want = wireFixed32
+	case reflect.Float64:
+		want = wireFixed64
+	case reflect.String, reflect.Slice, reflect.Struct, reflect.Pointer:
+		want = wireBytes
+	}
+	if wire != want {
+		return fmt.Errorf("wire type %d, want %d", wire, want)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		x, _ := binary.Uvarint(raw)
+		v.SetBool(x != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, _ := binary.Uvarint(raw)
+		v.SetInt(int64(x))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, _ := binary.Uvarint(raw)
+		v.SetUint(x)
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(raw))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(raw)))
+	case reflect.String:
+		v.SetString(string(raw))
+	case reflect.Slice:
+		v.SetBytes(append([]byte(nil), raw...))
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalMessage(raw, v.Elem())
+	case reflect.Struct:
+		return unmarshalMessage(raw, v)
+	}
+	return nil
+}
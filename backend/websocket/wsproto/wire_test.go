@@ -0,0 +1,202 @@
+package wsproto
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type inner struct {
+	Name string `proto:"1"`
+	N    int32  `proto:"2"`
+}
+
+type everything struct {
+	B     bool     `proto:"1"`
+	I     int      `proto:"2"`
+	I64   int64    `proto:"3"`
+	U8    uint8    `proto:"4"`
+	U64   uint64   `proto:"5"`
+	F32   float32  `proto:"6"`
+	F64   float64  `proto:"7"`
+	S     string   `proto:"8"`
+	Raw   []byte   `proto:"9"`
+	In    inner    `proto:"10"`
+	Ptr   *inner   `proto:"11"`
+	Strs  []string `proto:"12"`
+	Ints  []int64  `proto:"13"`
+	Items []inner  `proto:"14"`
+	Skip  string   // без тега не кодируется
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   everything
+	}{
+		{"zero", everything{}},
+		{"scalars", everything{B: true, I: -1, I64: 1 << 40, U8: 255, U64: 1<<64 - 1, F32: 1.5, F64: -2.25, S: "привет"}},
+		{"bytes", everything{Raw: []byte{0, 0x96, 0xff}}},
+		{"nested", everything{In: inner{Name: "a", N: 7}, Ptr: &inner{Name: "b"}}},
+		{"repeated", everything{Strs: []string{"x", "", "z"}, Ints: []int64{1, 0, 300}, Items: []inner{{Name: "i"}, {}}}},
+	}
+	for _, tt := range tests {
+		data, err := Marshal(&tt.in)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", tt.name, err)
+		}
+		var out everything
+		if err := Unmarshal(data, &out); err != nil {
+			t.Fatalf("%s: unmarshal: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(out, tt.in) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, out, tt.in)
+		}
+	}
+
+	// поле без тега в сообщение не попадает.
+	data, _ := Marshal(everything{Skip: "x"})
+	if len(data) != 0 {
+		t.Errorf("untagged field encoded: % x", data)
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	// примеры из документации protobuf.
+	tests := []struct {
+		in   any
+		want []byte
+	}{
+		{struct {
+			A int `proto:"1"`
+		}{150}, []byte{0x08, 0x96, 0x01}},
+		{struct {
+			B string `proto:"2"`
+		}{"testing"}, append([]byte{0x12, 0x07}, "testing"...)},
+		{struct {
+			C inner `proto:"3"`
+		}{inner{N: 150}}, []byte{0x1a, 0x03, 0x10, 0x96, 0x01}},
+		{struct {
+			F float32 `proto:"1"`
+		}{1}, []byte{0x0d, 0, 0, 0x80, 0x3f}},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.in)
+		if err != nil {
+			t.Fatalf("%T: %v", tt.in, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%+v: % x, want % x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalCompat(t *testing.T) {
+	var out struct {
+		N    int64   `proto:"1"`
+		Ints []int64 `proto:"4"`
+	}
+	data := []byte{
+		0x08, 0x01, // N = 1
+		0x10, 0x05, // неизвестное varint‑поле 2
+		0x1a, 0x02, 'h', 'i', // неизвестное bytes‑поле 3
+		0x2d, 1, 2, 3, 4, // неизвестное fixed32‑поле 5
+		0x31, 1, 2, 3, 4, 5, 6, 7, 8, // неизвестное fixed64‑поле 6
+		0x08, 0x02, // повторное одиночное поле перезаписывает
+		0x22, 0x03, 0x01, 0x96, 0x01, // упакованные Ints
+		0x20, 0x07, // и неупакованный элемент
+	}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.N != 2 || !reflect.DeepEqual(out.Ints, []int64{1, 150, 7}) {
+		t.Errorf("got %+v", out)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var out inner
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"key", []byte{0x80}},
+		{"varint", []byte{0x10, 0x96}},
+		{"length", []byte{0x0a, 0x05, 'a'}},
+		{"fixed32", []byte{0x15, 1, 2}},
+	}
+	for _, tt := range tests {
+		if err := Unmarshal(tt.data, &out); !errors.Is(err, ErrTruncated) {
+			t.Errorf("%s: err = %v, want ErrTruncated", tt.name, err)
+		}
+	}
+
+	// строковое поле с wire type varint.
+	if err := Unmarshal([]byte{0x08, 0x01}, &out); err == nil || !strings.Contains(err.Error(), "wire type") {
+		t.Errorf("wire type mismatch: err = %v", err)
+	}
+	if err := Unmarshal(nil, out); err == nil {
+		t.Error("unmarshal into a value accepted")
+	}
+	if _, err := Marshal(42); err == nil {
+		t.Error("marshal of a non-struct accepted")
+	}
+}
+
+func TestBadSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"number", struct {
+			A int `proto:"0"`
+		}{}},
+		{"duplicate", struct {
+			A int `proto:"1"`
+			B int `proto:"1"`
+		}{}},
+		{"type", struct {
+			M map[string]int `proto:"1"`
+		}{}},
+	}
+	for _, tt := range tests {
+		if _, err := Marshal(tt.v); err == nil {
+			t.Errorf("%s: marshal succeeded", tt.name)
+		}
+	}
+}
+
+// upper хранит строку в верхнем регистре, а на проводе — в нижнем.
+type upper string
+
+func (u upper) MarshalProto() ([]byte, error) { return []byte(strings.ToLower(string(u))), nil }
+
+func (u *upper) UnmarshalProto(b []byte) error {
+	*u = upper(strings.ToUpper(string(b)))
+	return nil
+}
+
+func TestCustom(t *testing.T) {
+	type msg struct {
+		U    upper   `proto:"1"`
+		List []upper `proto:"2"`
+	}
+	data, err := Marshal(msg{U: "HI", List: []upper{"A", "B"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x0a, 0x02, 'h', 'i', 0x12, 0x01, 'a', 0x12, 0x01, 'b'}
+	if !bytes.Equal(data, want) {
+		t.Errorf("% x, want % x", data, want)
+	}
+
+	var out msg
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.U != "HI" || !reflect.DeepEqual(out.List, []upper{"A", "B"}) {
+		t.Errorf("got %+v", out)
+	}
+}
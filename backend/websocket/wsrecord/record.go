@@ -0,0 +1,234 @@
+// Package wsrecord — формат записи WebSocket‑трафика: компактный
+// append-only файл, по одной записи на кадр. Его пишет сервер
+// (флаг -record), а читает wsreplay.
+//
+// Файл начинается с Magic, дальше идут записи:
+//
+//	direction  1 байт   (In или Out)
+//	opcode     1 байт   (OpText, OpBinary, OpClose или OpOpen)
+//	timestamp  uvarint  (unix‑наносекунды)
+//	conn       uvarint‑длина + байты
+//	payload    uvarint‑длина + байты
+//
+// Оборванная последняя запись (процесс упал посреди записи) читается
+// как io.ErrUnexpectedEOF: всё до неё остаётся пригодным.
+//
+// Create дописывает в существующий файл, а сервер после перезапуска
+// снова нумерует соединения с единицы. Поэтому у каждого Writer есть
+// Epoch — случайная метка запуска, которую пишущий добавляет к conn,
+// чтобы соединения разных запусков не склеивались при чтении.
+package wsrecord
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Magic — сигнатура и версия формата.
+const Magic = "WSREC1\n"
+
+// Direction — направление кадра относительно сервера.
+type Direction byte
+
+const (
+	In  Direction = 1 // от клиента к серверу
+	Out Direction = 2 // от сервера к клиенту
+)
+
+func (d Direction) String() string {
+	switch d {
+	case In:
+		return "in"
+	case Out:
+		return "out"
+	default:
+		return fmt.Sprintf("Direction(%d)", byte(d))
+	}
+}
+
+// Коды операций совпадают с RFC 6455; OpOpen — псевдокадр открытия
+// соединения, его payload — URI запроса на апгрейд.
+const (
+	OpText   byte = 1
+	OpBinary byte = 2
+	OpClose  byte = 8
+	OpOpen   byte = 0x10
+)
+
+// максимальные длины полей: защищают читателя от мусорного файла.
+const (
+	maxConnLen    = 1 << 10
+	maxPayloadLen = 1 << 30
+)
+
+// ErrBadMagic — файл не является записью wsrecord.
+var ErrBadMagic = errors.New("wsrecord: not a recording")
+
+// Frame — одна запись.
+type Frame struct {
+	Dir     Direction
+	Time    time.Time
+	Opcode  byte
+	Conn    string
+	Payload []byte
+}
+
+// Writer дописывает кадры; безопасен для конкурентного использования.
+type Writer struct {
+	epoch string
+
+	mu  sync.Mutex
+	w   io.Writer
+	c   io.Closer
+	buf []byte
+}
+
+// NewWriter пишет записи в w. Magic записывается сразу: w должен быть
+// пустым (для дописывания в существующий файл есть Create).
+func NewWriter(w io.Writer) (*Writer, error) {
+	if _, err := io.WriteString(w, Magic); err != nil {
+		return nil, err
+	}
+	return &Writer{epoch: newEpoch(), w: w}, nil
+}
+
+// Epoch возвращает метку этого запуска записи.
+func (w *Writer) Epoch() string { return w.epoch }
+
+// ConnID составляет conn записи из метки запуска и ID соединения.
+func (w *Writer) ConnID(id string) string { return w.epoch + "/" + id }
+
+func newEpoch() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Create открывает файл на дописывание, создавая его при необходимости.
+func Create(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if st.Size() == 0 {
+		if _, err := io.WriteString(f, Magic); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &Writer{epoch: newEpoch(), w: f, c: f}, nil
+}
+
+// Write дописывает кадр одной операцией записи, чтобы при падении
+// процесса в файле оставались только целые записи (кроме последней).
+func (w *Writer) Write(f Frame) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := w.buf[:0]
+	b = append(b, byte(f.Dir), f.Opcode)
+	b = binary.AppendUvarint(b, uint64(f.Time.UnixNano()))
+	b = binary.AppendUvarint(b, uint64(len(f.Conn)))
+	b = append(b, f.Conn...)
+	b = binary.AppendUvarint(b, uint64(len(f.Payload)))
+	b = append(b, f.Payload...)
+	w.buf = b
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Close закрывает файл, если Writer создан через Create.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.c == nil {
+		return nil
+	}
+	return w.c.Close()
+}
+
+// Reader читает записи по порядку.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader проверяет Magic и возвращает читателя.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, head); err != nil || !bytes.Equal(head, []byte(Magic)) {
+		return nil, ErrBadMagic
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next возвращает следующую запись или io.EOF в конце файла.
+func (r *Reader) Next() (Frame, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		// io.EOF — ровно на границе записи, то есть конец файла.
+		return Frame{}, err
+	}
+
+	ts, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return Frame{}, unexpected(err)
+	}
+	conn, err := r.bytes(maxConnLen)
+	if err != nil {
+		return Frame{}, err
+	}
+	payload, err := r.bytes(maxPayloadLen)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	return Frame{
+		Dir:     Direction(hdr[0]),
+		Opcode:  hdr[1],
+		Time:    time.Unix(0, int64(ts)),
+		Conn:    string(conn),
+		Payload: payload,
+	}, nil
+}
+
+func (r *Reader) bytes(limit uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if n > limit {
+		return nil, fmt.Errorf("wsrecord: field of %d bytes exceeds limit %d", n, limit)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+// unexpected превращает EOF внутри записи в io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
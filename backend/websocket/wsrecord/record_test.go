@@ -0,0 +1,121 @@
+package wsrecord
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	at := time.Unix(1_700_000_000, 123456789)
+	frames := []Frame{
+		{Dir: In, Time: at, Opcode: OpOpen, Conn: "e/1", Payload: []byte("/ws?room=a")},
+		{Dir: In, Time: at.Add(time.Millisecond), Opcode: OpText, Conn: "e/1", Payload: []byte(`{"type":"hi"}`)},
+		{Dir: Out, Time: at.Add(2 * time.Millisecond), Opcode: OpBinary, Conn: "e/1", Payload: []byte{0, 0xff, 0x80}},
+		{Dir: Out, Time: at.Add(3 * time.Millisecond), Opcode: OpText, Conn: "e/2", Payload: []byte{}},
+		{Dir: In, Time: at.Add(4 * time.Millisecond), Opcode: OpText, Conn: "e/2", Payload: bytes.Repeat([]byte("x"), 1<<16)},
+		{Dir: In, Time: at.Add(5 * time.Millisecond), Opcode: OpClose, Conn: "e/1", Payload: []byte{0x03, 0xe8}},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		if err := w.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !got.Time.Equal(want.Time) {
+			t.Errorf("frame %d: time %v, want %v", i, got.Time, want.Time)
+		}
+		got.Time = want.Time
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("frame %d:\n got %+v\nwant %+v", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("after the last frame: %v, want io.EOF", err)
+	}
+
+	// оборванная последняя запись не портит предыдущие.
+	r, _ = NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	for i := range len(frames) - 1 {
+		if _, err := r.Next(); err != nil {
+			t.Fatalf("truncated file, frame %d: %v", i, err)
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated frame: %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestNewReaderBadMagic(t *testing.T) {
+	for _, in := range []string{"", "WSREC", "WSREC2\n", "{}"} {
+		if _, err := NewReader(strings.NewReader(in)); !errors.Is(err, ErrBadMagic) {
+			t.Errorf("NewReader(%q) = %v, want ErrBadMagic", in, err)
+		}
+	}
+}
+
+func TestCreateAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.wsrec")
+
+	// два запуска сервера пишут в один файл и нумеруют соединения заново.
+	var epochs []string
+	for _, payload := range []string{"first run", "second run"} {
+		w, err := Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		epochs = append(epochs, w.Epoch())
+		if err := w.Write(Frame{Dir: In, Opcode: OpText, Conn: w.ConnID("1"), Payload: []byte(payload)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if epochs[0] == epochs[1] || epochs[0] == "" {
+		t.Fatalf("epochs %q must differ", epochs)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, payload := range []string{"first run", "second run"} {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Conn != epochs[i]+"/1" || string(got.Payload) != payload {
+			t.Errorf("frame %d = %s %q", i, got.Conn, got.Payload)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("magic written twice or extra data: %v", err)
+	}
+}
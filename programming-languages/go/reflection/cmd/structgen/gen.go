@@ -0,0 +1,655 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"reflection/validate"
+)
+
+// generate разбирает пакет в dir и возвращает исходник с методами для
+// types.
+func generate(dir string, types []string, output string) ([]byte, error) {
+	p, err := loadPackage(dir, output)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &gen{
+		pkg:     p,
+		imports: map[string]bool{"fmt": true, "reflection/validate": true},
+		regexps: make(map[string]string),
+		queued:  make(map[*structDecl]bool),
+	}
+	var roots []*structDecl
+	for _, name := range types {
+		d, err := p.structOf(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, d)
+		g.need(d)
+	}
+
+	var body bytes.Buffer
+	for _, d := range roots {
+		g.buf = &body
+		g.root(d)
+	}
+	// очередь растёт по ходу: вложенные структуры тоже нужно обойти.
+	for i := 0; i < len(g.queue); i++ {
+		g.buf = &body
+		g.structValidate(g.queue[i])
+	}
+	if len(g.errs) > 0 {
+		return nil, errors.Join(g.errs...)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s structgen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", generatedHeader, p.name)
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "%q\n", imp)
+	}
+	out.WriteString(")\n\n")
+	for _, pattern := range g.regexpOrder {
+		fmt.Fprintf(&out, "var %s = regexp.MustCompile(%q)\n", g.regexps[pattern], pattern)
+	}
+	out.Write(body.Bytes())
+	out.WriteString(runtimeHelpers)
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+// runtimeHelpers — общая часть сгенерированного кода: то же, что walker
+// и location в пакете validate.
+const runtimeHelpers = `
+// structgenWalker копит нарушения одной проверки.
+type structgenWalker struct {
+	errs validate.ValidationErrors
+	// seen — указатели на структуры на текущем пути: защита от циклов.
+	seen map[any]bool
+}
+
+func (w *structgenWalker) fail(path, json, rule, param string, value any) {
+	w.errs = append(w.errs, &validate.FieldError{Path: path, JSONPath: json, Rule: rule, Param: param, Value: value})
+}
+
+func (w *structgenWalker) enter(p any) bool {
+	if w.seen[p] {
+		return false
+	}
+	if w.seen == nil {
+		w.seen = make(map[any]bool)
+	}
+	w.seen[p] = true
+	return true
+}
+
+func (w *structgenWalker) leave(p any) { delete(w.seen, p) }
+
+func (w *structgenWalker) check(s any, path, json string) {
+	w.errs = append(w.errs, validate.CheckStruct(s, path, json)...)
+}
+
+func (w *structgenWalker) result() error {
+	if len(w.errs) == 0 {
+		return nil
+	}
+	return w.errs
+}
+
+func structgenJoin(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func structgenIndex(path string, key any) string {
+	return path + fmt.Sprintf("[%v]", key)
+}
+`
+
+type gen struct {
+	pkg *pkg
+	buf *bytes.Buffer
+
+	imports map[string]bool
+	// regexps — имя переменной для каждого выражения правила regexp.
+	regexps     map[string]string
+	regexpOrder []string
+
+	queue  []*structDecl
+	queued map[*structDecl]bool
+
+	vars int
+	errs []error
+}
+
+func (g *gen) printf(format string, args ...any) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+// need ставит структуру в очередь на structgenValidate.
+func (g *gen) need(d *structDecl) {
+	if !g.queued[d] {
+		g.queued[d] = true
+		g.queue = append(g.queue, d)
+	}
+}
+
+func (g *gen) newVar(prefix string) string {
+	g.vars++
+	return fmt.Sprintf("%s%d", prefix, g.vars)
+}
+
+// capture пишет то, что печатает fn, в отдельный буфер и возвращает его.
+func (g *gen) capture(fn func()) string {
+	saved := g.buf
+	var b bytes.Buffer
+	g.buf = &b
+	fn()
+	g.buf = saved
+	return b.String()
+}
+
+// root — открытые методы типа из -type.
+func (g *gen) root(d *structDecl) {
+	g.printf(`
+// Validate проверяет %[1]s по тегам validate — так же, как validate.Struct.
+func (s *%[1]s) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%%w: nil %%T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, %[1]q, "")
+	return w.result()
+}
+`, d.name)
+
+	// одинаковые ключи: как и при заполнении map в цикле, побеждает последнее поле.
+	var keys []string
+	values := make(map[string]string)
+	for _, f := range d.fields {
+		if !f.Exported {
+			continue
+		}
+		if _, dup := values[f.MapKey]; !dup {
+			keys = append(keys, f.MapKey)
+		}
+		values[f.MapKey] = "s." + f.Name
+	}
+	g.printf("\n// ToMap возвращает поля %s по ключам structinfo.ToMap.\n", d.name)
+	g.printf("func (s *%s) ToMap() map[string]any {\nreturn map[string]any{\n", d.name)
+	for _, k := range keys {
+		g.printf("%q: %s,\n", k, values[k])
+	}
+	g.printf("}\n}\n")
+}
+
+// scope — структура, чьи поля сейчас проверяются: к её полям обращаются
+// правила вроде eqfield.
+type scope struct {
+	decl  *structDecl
+	field *field
+}
+
+func (g *gen) errorf(sc scope, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	g.errs = append(g.errs, fmt.Errorf("%s: %s.%s: %s", g.pkg.fset.Position(sc.field.pos), sc.decl.name, sc.field.Name, msg))
+}
+
+// location — выражения Go, которые во время работы дают путь значения.
+type location struct {
+	path string
+	json string
+}
+
+func (l location) index(key string) location {
+	return location{
+		path: "structgenIndex(" + l.path + ", " + key + ")",
+		json: "structgenIndex(" + l.json + ", " + key + ")",
+	}
+}
+
+// structValidate — обход полей структуры, как walker.structFields.
+func (g *gen) structValidate(d *structDecl) {
+	if _, err := g.pkg.structOf(d.name); err != nil {
+		g.errs = append(g.errs, err)
+		return
+	}
+	g.vars = 0
+	g.printf("\nfunc (s *%s) structgenValidate(w *structgenWalker, path, json string) {\n", d.name)
+	for _, f := range d.fields {
+		// как rulesOf: поля неэкспортированной встроенной структуры подняты
+		// наверх, и её тоже обходим.
+		if !f.Exported && !(f.Anonymous && embedsStruct(f.typ)) {
+			continue
+		}
+		sc := scope{decl: d, field: f}
+		rules, err := validate.ParseTag(f.ValidateTag)
+		if err != nil {
+			g.errorf(sc, "%v", err)
+			continue
+		}
+		loc := location{
+			path: fmt.Sprintf("structgenJoin(path, %q)", f.Name),
+			json: fmt.Sprintf("structgenJoin(json, %q)", f.JSON),
+		}
+		if f.Anonymous && !f.JSONNamed {
+			// encoding/json поднимает поля встроенной структуры наверх.
+			loc.json = "json"
+		}
+		g.value(sc, "s."+f.Name, f.typ, loc, rules)
+	}
+	g.printf("w.check(s, path, json)\n}\n")
+}
+
+// embedsStruct — структура пакета или указатель на неё.
+func embedsStruct(t *goType) bool {
+	for t.kind == kindPointer {
+		t = t.elem
+	}
+	return t.kind == kindStruct
+}
+
+// value — как walker.value: разыменовать указатель и применить правила.
+func (g *gen) value(sc scope, x string, t *goType, loc location, rules []validate.Rule) {
+	switch t.kind {
+	case kindPointer:
+		p := g.newVar("p")
+		body := g.capture(func() { g.value(sc, "(*"+p+")", t.elem, loc, rules) })
+		onNil := g.capture(func() { g.nilRules(sc, p, loc, rules) })
+		switch {
+		case body == "" && onNil == "":
+		case body == "":
+			g.printf("if %s := %s; %s == nil {\n%s}\n", p, x, p, onNil)
+		default:
+			g.printf("if %s := %s; %s != nil {\n", p, x, p)
+			if t.elem.kind == kindStruct {
+				g.printf("if w.enter(%s) {\n%sw.leave(%s)\n}\n", p, body, p)
+			} else {
+				g.printf("%s", body)
+			}
+			if onNil != "" {
+				g.printf("} else {\n%s", onNil)
+			}
+			g.printf("}\n")
+		}
+	case kindInterface:
+		g.errorf(sc, "interface value %s: dynamic types are checked only by validate.Struct", t.expr)
+	case kindUnknown:
+		g.errorf(sc, "unsupported type %s: only types of this package and time.Time can be generated", t.expr)
+	default:
+		g.rules(sc, x, t, loc, rules)
+	}
+}
+
+// nilRules — правила для nil‑указателя p: проверяются только required*.
+func (g *gen) nilRules(sc scope, p string, loc location, rules []validate.Rule) {
+	for _, r := range rules {
+		switch {
+		case r.Name == "omitempty" || r.Name == "dive":
+			return
+		case strings.HasPrefix(r.Name, "required"):
+			g.rule(sc, p, nil, loc, r)
+		}
+	}
+}
+
+func (g *gen) rules(sc scope, x string, t *goType, loc location, rules []validate.Rule) {
+	for i, r := range rules {
+		switch r.Name {
+		case "omitempty":
+			g.printf("if !(%s) {\n", g.empty(sc, x, t))
+			g.rules(sc, x, t, loc, rules[i+1:])
+			g.printf("}\n")
+			return
+		case "dive":
+			g.dive(sc, x, t, loc, rules[i+1:])
+			return
+		}
+		g.rule(sc, x, t, loc, r)
+	}
+	if t.kind == kindStruct {
+		g.need(t.decl)
+		g.printf("%s.structgenValidate(w, %s, %s)\n", x, loc.path, loc.json)
+	}
+}
+
+// dive — как walker.dive.
+func (g *gen) dive(sc scope, x string, t *goType, loc location, rules []validate.Rule) {
+	switch t.kind {
+	case kindSlice, kindArray:
+		i := g.newVar("i")
+		body := g.capture(func() { g.value(sc, x+"["+i+"]", t.elem, loc.index(i), rules) })
+		if body != "" {
+			g.printf("for %s := range %s {\n%s}\n", i, x, body)
+		}
+	case kindMap:
+		k, v := g.newVar("k"), g.newVar("v")
+		body := g.capture(func() { g.value(sc, v, t.elem, loc.index(k), rules) })
+		if body != "" {
+			g.printf("for %s, %s := range %s {\n%s}\n", k, v, x, body)
+		}
+	default:
+		g.errorf(sc, "dive on %s", t.expr)
+	}
+}
+
+// rule печатает одно правило для значения x типа t. t == nil — x это
+// nil‑указатель: пустое значение, для которого проверяются только required*.
+func (g *gen) rule(sc scope, x string, t *goType, loc location, r validate.Rule) {
+	fail := fmt.Sprintf("w.fail(%s, %s, %q, %q, %s)\n", loc.path, loc.json, r.Name, r.Param, x)
+	empty := "true"
+	if t != nil {
+		empty = g.empty(sc, x, t)
+	}
+
+	switch r.Name {
+	case "required":
+		g.failIf(empty, fail)
+	case "required_if", "required_unless":
+		pairs := strings.Fields(r.Param)
+		if len(pairs) == 0 || len(pairs)%2 != 0 {
+			g.errorf(sc, "%s: want field value pairs, got %q", r.Name, r.Param)
+			return
+		}
+		var conds []string
+		for i := 0; i < len(pairs); i += 2 {
+			conds = append(conds, g.fieldEquals(sc, pairs[i], pairs[i+1]))
+		}
+		all := strings.Join(conds, " && ")
+		if r.Name == "required_unless" {
+			all = "!(" + all + ")"
+		}
+		g.failIf(and(all, empty), fail)
+	case "required_with", "required_without":
+		names := strings.Fields(r.Param)
+		if len(names) == 0 {
+			g.errorf(sc, "%s: no fields", r.Name)
+			return
+		}
+		var conds []string
+		for _, name := range names {
+			other, ok := g.sibling(sc, name)
+			if !ok {
+				return
+			}
+			cond := g.empty(sc, "s."+other.Name, other.typ)
+			if r.Name == "required_with" {
+				cond = "!(" + cond + ")"
+			}
+			conds = append(conds, cond)
+		}
+		g.failIf(and("("+strings.Join(conds, " || ")+")", empty), fail)
+	default:
+		if t == nil {
+			return
+		}
+		g.valueRule(sc, x, t, r, fail)
+	}
+}
+
+// valueRule — правила, которые смотрят на само значение.
+func (g *gen) valueRule(sc scope, x string, t *goType, r validate.Rule, fail string) {
+	switch r.Name {
+	case "min", "max", "len":
+		want, err := strconv.ParseFloat(r.Param, 64)
+		if err != nil || math.IsInf(want, 0) || math.IsNaN(want) {
+			g.errorf(sc, "%s: parameter %q is not a number", r.Name, r.Param)
+			return
+		}
+		op := map[string]string{"min": ">=", "max": "<=", "len": "=="}[r.Name]
+		size, ok := g.size(x, t)
+		if !ok {
+			g.errorf(sc, "%s on %s", r.Name, t.expr)
+			return
+		}
+		g.failIf(fmt.Sprintf("!(%s %s %s)", size, op, strconv.FormatFloat(want, 'g', -1, 64)), fail)
+	case "oneof":
+		options := strings.Fields(r.Param)
+		if len(options) == 0 {
+			g.errorf(sc, "oneof without options")
+			return
+		}
+		s, ok := g.scalar(x, t)
+		if !ok {
+			g.errorf(sc, "oneof on %s", t.expr)
+			return
+		}
+		var cases []string
+		seen := make(map[string]bool)
+		for _, opt := range options {
+			if !seen[opt] {
+				seen[opt] = true
+				cases = append(cases, strconv.Quote(opt))
+			}
+		}
+		g.printf("switch %s {\ncase %s:\ndefault:\n%s}\n", s, strings.Join(cases, ", "), fail)
+	case "email", "url", "uuid":
+		if t.kind != kindString {
+			g.errorf(sc, "%s on %s", r.Name, t.expr)
+			return
+		}
+		fn := map[string]string{"email": "IsEmail", "url": "IsURL", "uuid": "IsUUID"}[r.Name]
+		g.failIf(fmt.Sprintf("!validate.%s(string(%s))", fn, x), fail)
+	case "regexp":
+		if t.kind != kindString {
+			g.errorf(sc, "regexp on %s", t.expr)
+			return
+		}
+		// якоря как в validate: выражение должно совпасть целиком.
+		pattern := `^(?:` + r.Param + `)$`
+		if _, err := regexp.Compile(pattern); err != nil {
+			g.errorf(sc, "regexp: %v", err)
+			return
+		}
+		name, ok := g.regexps[pattern]
+		if !ok {
+			name = fmt.Sprintf("structgenRE%d", len(g.regexpOrder))
+			g.regexps[pattern] = name
+			g.regexpOrder = append(g.regexpOrder, pattern)
+			g.imports["regexp"] = true
+		}
+		g.failIf(fmt.Sprintf("!%s.MatchString(string(%s))", name, x), fail)
+	case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield":
+		op := map[string]string{
+			"eqfield": "==", "nefield": "!=",
+			"gtfield": ">", "gtefield": ">=",
+			"ltfield": "<", "ltefield": "<=",
+		}[r.Name]
+		other, ok := g.sibling(sc, r.Param)
+		if !ok {
+			return
+		}
+		o, ot := "s."+other.Name, other.typ
+		guard := ""
+		if ot.kind == kindPointer {
+			// сравнивать не с чем: правило выполнено.
+			guard, o, ot = o+" != nil && ", "*"+o, ot.elem
+		}
+		c, ok := g.compare(x, t, o, ot)
+		if !ok {
+			g.errorf(sc, "%s: cannot compare %s with %s", r.Name, t.expr, ot.expr)
+			return
+		}
+		g.failIf(guard+"!("+c+" "+op+" 0)", fail)
+	default:
+		g.errorf(sc, "rule %q is not supported by structgen: only the validate built-ins are", r.Name)
+	}
+}
+
+func (g *gen) failIf(cond, fail string) {
+	if cond == "true" {
+		g.printf("%s", fail)
+		return
+	}
+	g.printf("if %s {\n%s}\n", cond, fail)
+}
+
+func and(a, b string) string {
+	if b == "true" {
+		return a
+	}
+	return a + " && " + b
+}
+
+// sibling — поле, на которое ссылается правило. Генератор понимает
+// только поля той же структуры: поиск во внешних структурах зависит от
+// того, где структура лежит, а это известно лишь во время работы.
+func (g *gen) sibling(sc scope, name string) (*field, bool) {
+	if strings.Contains(name, ".") {
+		g.errorf(sc, "field path %q: only fields of %s are supported", name, sc.decl.name)
+		return nil, false
+	}
+	for _, f := range sc.decl.fields {
+		if f.Name == name && f.Exported {
+			if f.typ.kind == kindPointer && f.typ.elem.kind == kindPointer {
+				break
+			}
+			return f, true
+		}
+	}
+	g.errorf(sc, "no field %q in %s", name, sc.decl.name)
+	return nil, false
+}
+
+// fieldEquals — условие required_if: поле name в текстовом виде равно value.
+func (g *gen) fieldEquals(sc scope, name, value string) string {
+	other, ok := g.sibling(sc, name)
+	if !ok {
+		return "false"
+	}
+	o, ot := "s."+other.Name, other.typ
+	guard := ""
+	if ot.kind == kindPointer {
+		guard, o, ot = o+" != nil && ", "*"+o, ot.elem
+	}
+	s, ok := g.scalar(o, ot)
+	if !ok {
+		g.errorf(sc, "field %s of type %s cannot be compared with %q", name, other.typ.expr, value)
+		return "false"
+	}
+	return "(" + guard + s + " == " + strconv.Quote(value) + ")"
+}
+
+// empty — условие «значение пустое», как isEmpty в validate: у срезов и
+// map — без элементов, у остального — нулевое.
+func (g *gen) empty(sc scope, x string, t *goType) string {
+	switch t.kind {
+	case kindSlice, kindMap:
+		return "len(" + x + ") == 0"
+	}
+	return g.zero(sc, x, t)
+}
+
+// zero — условие «значение нулевое», как reflect.Value.IsZero.
+func (g *gen) zero(sc scope, x string, t *goType) string {
+	switch t.kind {
+	case kindString:
+		return x + ` == ""`
+	case kindBool:
+		return "!" + x
+	case kindInt, kindUint, kindFloat:
+		return x + " == 0"
+	case kindPointer, kindInterface, kindSlice, kindMap:
+		return x + " == nil"
+	case kindOpaque:
+		return x + " == " + t.zeroValue
+	case kindTime, kindStruct, kindArray:
+		if t.kind == kindTime {
+			g.imports["time"] = true
+		}
+		if g.pkg.comparable(t) {
+			return x + " == (" + t.expr + "{})"
+		}
+		if d, err := g.pkg.structOf(t.expr); err == nil && t.kind == kindStruct {
+			t.decl = d
+			// без == IsZero проверяет поля по одному, включая
+			// неэкспортированные: сгенерированный код в том же пакете.
+			var conds []string
+			for _, f := range t.decl.fields {
+				if f.Name == "_" {
+					break
+				}
+				conds = append(conds, g.zero(sc, x+"."+f.Name, f.typ))
+			}
+			if len(conds) == len(t.decl.fields) {
+				if len(conds) == 0 {
+					return "true"
+				}
+				return "(" + strings.Join(conds, " && ") + ")"
+			}
+		}
+	}
+	g.errorf(sc, "cannot check whether %s is empty", t.expr)
+	return "false"
+}
+
+// size — то, с чем сравнивают min, max и len, как size в validate.
+func (g *gen) size(x string, t *goType) (string, bool) {
+	switch t.kind {
+	case kindInt, kindUint, kindFloat:
+		return "float64(" + x + ")", true
+	case kindString:
+		g.imports["unicode/utf8"] = true
+		return "float64(utf8.RuneCountInString(string(" + x + ")))", true
+	case kindSlice, kindArray, kindMap:
+		return "float64(len(" + x + "))", true
+	}
+	return "", false
+}
+
+// scalar — значение в текстовом виде, как scalarString в validate.
+func (g *gen) scalar(x string, t *goType) (string, bool) {
+	switch t.kind {
+	case kindString:
+		return "string(" + x + ")", true
+	case kindInt:
+		g.imports["strconv"] = true
+		return "strconv.FormatInt(int64(" + x + "), 10)", true
+	case kindUint:
+		g.imports["strconv"] = true
+		return "strconv.FormatUint(uint64(" + x + "), 10)", true
+	case kindBool:
+		g.imports["strconv"] = true
+		return "strconv.FormatBool(bool(" + x + "))", true
+	}
+	return "", false
+}
+
+// compare — выражение со знаком сравнения a и b, как compareValues.
+func (g *gen) compare(a string, at *goType, b string, bt *goType) (string, bool) {
+	switch {
+	case at.kind == kindTime && bt.kind == kindTime:
+		return a + ".Compare(" + b + ")", true
+	case isNumber(at) && isNumber(bt):
+		g.imports["cmp"] = true
+		return "cmp.Compare(float64(" + a + "), float64(" + b + "))", true
+	case at.kind == kindString && bt.kind == kindString:
+		g.imports["cmp"] = true
+		return "cmp.Compare(string(" + a + "), string(" + b + "))", true
+	}
+	return "", false
+}
+
+func isNumber(t *goType) bool {
+	return t.kind == kindInt || t.kind == kindUint || t.kind == kindFloat
+}
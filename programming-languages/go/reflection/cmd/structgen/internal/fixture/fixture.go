@@ -0,0 +1,78 @@
+// Package fixture — типы для общего набора тестов: одни и те же значения
+// проверяются validate.Struct и сгенерированным Validate, и результаты
+// должны совпасть до последнего поля FieldError.
+package fixture
+
+import "time"
+
+//go:generate go run reflection/cmd/structgen -type Order,Customer,Node
+
+type Status string
+
+type Address struct {
+	City string `json:"city" validate:"required,max=32"`
+	Zip  string `json:"zip,omitempty" validate:"omitempty,regexp=[0-9]{6}"`
+}
+
+type Contact struct {
+	Phone string `json:"phone"`
+	Email string `json:"email" validate:"required_without=Phone,omitempty,email"`
+	Fax   string `json:"fax" validate:"required_with=Phone Email"`
+}
+
+type Customer struct {
+	Contact
+	ID       string   `json:"id" db:"customer_id" validate:"required,uuid"`
+	Name     string   `json:"name" validate:"required,min=2,max=16"`
+	Site     *string  `json:"site" validate:"omitempty,url"`
+	Nick     *string  `json:"nick" validate:"required"`
+	Age      int      `json:"age" validate:"min=18,max=130"`
+	Score    float64  `json:"score" validate:"max=1.5"`
+	Tier     uint8    `json:"tier" validate:"oneof=1 2 3"`
+	VIP      bool     `json:"vip"`
+	Password string   `json:"-" validate:"min=8"`
+	Confirm  string   `json:"-" validate:"eqfield=Password"`
+	Home     *Address `json:"home" validate:"required"`
+	Work     Address  `json:"work"`
+	internal string
+}
+
+type Line struct {
+	SKU   string `json:"sku" validate:"required,len=8"`
+	Qty   int    `json:"qty" validate:"gtefield=Min,ltefield=Max"`
+	Min   int    `json:"min"`
+	Max   *int   `json:"max"`
+	Notes []string
+}
+
+// stamp — неэкспортированная встроенная структура: её поля видны как
+// поля Order и проверяются.
+type stamp struct {
+	Author string `json:"author" validate:"max=8"`
+}
+
+type Order struct {
+	stamp
+	Status    Status            `json:"status" validate:"oneof=new paid shipped"`
+	Customer  *Customer         `json:"customer" validate:"required"`
+	Lines     []Line            `json:"lines" validate:"min=1,max=3,dive"`
+	Tags      []string          `json:"tags" validate:"max=3,dive,required,max=8"`
+	Matrix    [][]int           `json:"matrix" validate:"dive,min=1,dive,min=0,max=9"`
+	Labels    map[string]string `json:"labels" validate:"dive,max=4"`
+	Extras    map[string]*Line  `json:"extras" validate:"dive,required"`
+	Codes     [2]string         `json:"codes" validate:"dive,omitempty,len=3"`
+	CreatedAt time.Time         `json:"created_at" validate:"required"`
+	ShippedAt *time.Time        `json:"shipped_at" validate:"required_if=Status shipped,omitempty,gtfield=CreatedAt"`
+	Tracking  string            `json:"tracking" validate:"required_if=Status shipped,required_unless=Paid true"`
+	Paid      bool              `json:"paid"`
+	Discount  uint              `json:"discount" validate:"ltfield=Total"`
+	Total     uint              `json:"total" validate:"nefield=Discount"`
+	Meta      map[string]any    `json:"meta"`
+}
+
+// Node — рекурсивный тип: обход защищён от циклов.
+type Node struct {
+	Name     string  `json:"name" validate:"required"`
+	Next     *Node   `json:"next"`
+	Children []*Node `json:"children" validate:"dive"`
+}
@@ -0,0 +1,253 @@
+package fixture
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"reflection/structinfo"
+	"reflection/validate"
+)
+
+func init() {
+	// проверка типа из реестра должна сработать в обеих реализациях.
+	validate.RegisterStruct(Address{}, func(sl validate.StructLevel) {
+		a := sl.Value.Interface().(Address)
+		if a.City == "Moscow" && a.Zip == "" {
+			sl.Report("Zip", "required_in", "Moscow")
+		}
+	})
+}
+
+// impls — две реализации, которые должны вести себя одинаково.
+var impls = []struct {
+	name     string
+	validate func(v interface{ Validate() error }) error
+}{
+	{"reflection", func(v interface{ Validate() error }) error { return validate.Struct(v) }},
+	{"generated", func(v interface{ Validate() error }) error { return v.Validate() }},
+}
+
+var created = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func ptr[T any](v T) *T { return &v }
+
+func validOrder() *Order {
+	return &Order{
+		Status: "new",
+		Customer: &Customer{
+			Contact:  Contact{Email: "ann@example.com", Fax: "+7 495 000-00-00"},
+			ID:       "0f8fad5b-d9cb-469f-a165-70867728950e",
+			Name:     "Ann",
+			Nick:     ptr("ann"),
+			Age:      30,
+			Tier:     1,
+			Password: "12345678",
+			Confirm:  "12345678",
+			Home:     &Address{City: "Kazan"},
+			Work:     Address{City: "Kazan", Zip: "420111"},
+		},
+		Lines:     []Line{{SKU: "ABCDEFGH", Qty: 1}},
+		CreatedAt: created,
+		Paid:      true,
+		Discount:  1,
+		Total:     10,
+	}
+}
+
+// failures превращает ошибку в строки "Path|JSONPath|rule=param"
+// ("=param" — только если параметр есть).
+func failures(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs validate.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out []string
+	for _, fe := range errs {
+		s := fe.Path + "|" + fe.JSONPath + "|" + fe.Rule
+		if fe.Param != "" {
+			s += "=" + fe.Param
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestOrderValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(o *Order)
+		want   []string
+	}{
+		{"valid", func(o *Order) {}, nil},
+		{"oneof", func(o *Order) { o.Status = "lost" }, []string{
+			"Order.Status|status|oneof=new paid shipped",
+		}},
+		{"required nil pointer", func(o *Order) { o.Customer = nil }, []string{
+			"Order.Customer|customer|required",
+		}},
+		{"embedded struct keeps json path", func(o *Order) { o.Customer.Email = "ann" }, []string{
+			"Order.Customer.Contact.Email|customer.email|email",
+		}},
+		{"required_without", func(o *Order) { o.Customer.Email, o.Customer.Fax = "", "" }, []string{
+			"Order.Customer.Contact.Email|customer.email|required_without=Phone",
+		}},
+		{"required_with", func(o *Order) { o.Customer.Phone, o.Customer.Fax = "1", "" }, []string{
+			"Order.Customer.Contact.Fax|customer.fax|required_with=Phone Email",
+		}},
+		{"uuid", func(o *Order) { o.Customer.ID = "42" }, []string{
+			"Order.Customer.ID|customer.id|uuid",
+		}},
+		{"min and max on strings count runes", func(o *Order) { o.Customer.Name = "Я" }, []string{
+			"Order.Customer.Name|customer.name|min=2",
+		}},
+		{"omitempty pointer", func(o *Order) { o.Customer.Site = ptr("") }, nil},
+		{"url through pointer", func(o *Order) { o.Customer.Site = ptr("example.com") }, []string{
+			"Order.Customer.Site|customer.site|url",
+		}},
+		{"required nil string pointer", func(o *Order) { o.Customer.Nick = nil }, []string{
+			"Order.Customer.Nick|customer.nick|required",
+		}},
+		{"required empty string pointer", func(o *Order) { o.Customer.Nick = ptr("") }, []string{
+			"Order.Customer.Nick|customer.nick|required",
+		}},
+		{"numbers", func(o *Order) { o.Customer.Age, o.Customer.Score, o.Customer.Tier = 17, 1.51, 4 }, []string{
+			"Order.Customer.Age|customer.age|min=18",
+			"Order.Customer.Score|customer.score|max=1.5",
+			"Order.Customer.Tier|customer.tier|oneof=1 2 3",
+		}},
+		{"json skip keeps go name", func(o *Order) { o.Customer.Confirm = "87654321" }, []string{
+			"Order.Customer.Confirm|customer.Confirm|eqfield=Password",
+		}},
+		{"required struct pointer", func(o *Order) { o.Customer.Home = &Address{} }, []string{
+			"Order.Customer.Home|customer.home|required",
+			"Order.Customer.Home.City|customer.home.city|required",
+		}},
+		{"regexp", func(o *Order) { o.Customer.Work.Zip = "12" }, []string{
+			"Order.Customer.Work.Zip|customer.work.zip|regexp=[0-9]{6}",
+		}},
+		{"struct level", func(o *Order) { o.Customer.Home.City = "Moscow" }, []string{
+			"Order.Customer.Home.Zip|customer.home.zip|required_in=Moscow",
+		}},
+		{"slice size", func(o *Order) { o.Lines = nil }, []string{
+			"Order.Lines|lines|min=1",
+		}},
+		{"dive into structs", func(o *Order) {
+			o.Lines = append(o.Lines, Line{SKU: "SHORT", Qty: 1, Min: 2, Max: ptr(0)})
+		}, []string{
+			"Order.Lines[1].SKU|lines[1].sku|len=8",
+			"Order.Lines[1].Qty|lines[1].qty|gtefield=Min",
+			"Order.Lines[1].Qty|lines[1].qty|ltefield=Max",
+		}},
+		{"dive into strings", func(o *Order) { o.Tags = []string{"", "ok", "very long tag", "x"} }, []string{
+			"Order.Tags|tags|max=3",
+			"Order.Tags[0]|tags[0]|required",
+			"Order.Tags[2]|tags[2]|max=8",
+		}},
+		{"nested dive", func(o *Order) { o.Matrix = [][]int{{}, {1, 10, -1}} }, []string{
+			"Order.Matrix[0]|matrix[0]|min=1",
+			"Order.Matrix[1][1]|matrix[1][1]|max=9",
+			"Order.Matrix[1][2]|matrix[1][2]|min=0",
+		}},
+		{"dive into map", func(o *Order) { o.Labels = map[string]string{"env": "production"} }, []string{
+			"Order.Labels[env]|labels[env]|max=4",
+		}},
+		{"map of nil pointers", func(o *Order) { o.Extras = map[string]*Line{"gift": nil} }, []string{
+			"Order.Extras[gift]|extras[gift]|required",
+		}},
+		{"zero struct without ==", func(o *Order) { o.Extras = map[string]*Line{"gift": {}} }, []string{
+			"Order.Extras[gift]|extras[gift]|required",
+			"Order.Extras[gift].SKU|extras[gift].sku|required",
+			"Order.Extras[gift].SKU|extras[gift].sku|len=8",
+		}},
+		{"empty slice is not zero", func(o *Order) {
+			o.Extras = map[string]*Line{"gift": {Notes: []string{}}}
+		}, []string{
+			"Order.Extras[gift].SKU|extras[gift].sku|required",
+			"Order.Extras[gift].SKU|extras[gift].sku|len=8",
+		}},
+		{"dive into array", func(o *Order) { o.Codes = [2]string{"", "ab"} }, []string{
+			"Order.Codes[1]|codes[1]|len=3",
+		}},
+		{"required time", func(o *Order) { o.CreatedAt = time.Time{} }, []string{
+			"Order.CreatedAt|created_at|required",
+		}},
+		{"required_if", func(o *Order) { o.Status = "shipped" }, []string{
+			"Order.ShippedAt|shipped_at|required_if=Status shipped",
+			"Order.Tracking|tracking|required_if=Status shipped",
+		}},
+		{"gtfield on times", func(o *Order) { o.ShippedAt = ptr(created.Add(-time.Hour)) }, []string{
+			"Order.ShippedAt|shipped_at|gtfield=CreatedAt",
+		}},
+		{"required_unless", func(o *Order) { o.Paid = false }, []string{
+			"Order.Tracking|tracking|required_unless=Paid true",
+		}},
+		{"unexported embedded struct", func(o *Order) { o.Author = "Anonymous" }, []string{
+			"Order.stamp.Author|author|max=8",
+		}},
+		{"ltfield and nefield", func(o *Order) { o.Discount = 10 }, []string{
+			"Order.Discount|discount|ltfield=Total",
+			"Order.Total|total|nefield=Discount",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]error, len(impls))
+			for i, impl := range impls {
+				o := validOrder()
+				tt.mutate(o)
+				results[i] = impl.validate(o)
+				if got := failures(t, results[i]); !slices.Equal(got, tt.want) {
+					t.Errorf("%s:\n got %q\nwant %q", impl.name, got, tt.want)
+				}
+			}
+			// и до последнего поля FieldError, включая Value.
+			if !reflect.DeepEqual(results[0], results[1]) {
+				t.Errorf("results differ:\nreflection %#v\ngenerated  %#v", results[0], results[1])
+			}
+		})
+	}
+}
+
+func TestNodeCycles(t *testing.T) {
+	root := &Node{Name: "root"}
+	root.Next = root
+	root.Children = []*Node{root, {}, nil}
+
+	want := []string{
+		"Node.Next.Children[1].Name|next.children[1].name|required",
+		"Node.Children[0].Children[1].Name|children[0].children[1].name|required",
+		"Node.Children[1].Name|children[1].name|required",
+	}
+	for _, impl := range impls {
+		if got := failures(t, impl.validate(root)); !slices.Equal(got, want) {
+			t.Errorf("%s:\n got %q\nwant %q", impl.name, got, want)
+		}
+	}
+}
+
+func TestNilReceiver(t *testing.T) {
+	for _, impl := range impls {
+		err := impl.validate((*Order)(nil))
+		if !errors.Is(err, validate.ErrBadRule) || err.Error() != "validate: bad rule: nil *fixture.Order" {
+			t.Errorf("%s: got %v", impl.name, err)
+		}
+	}
+}
+
+func TestToMap(t *testing.T) {
+	o := validOrder()
+	o.Meta = map[string]any{"source": "web"}
+	for _, v := range []interface{ ToMap() map[string]any }{o, o.Customer, &Node{Name: "leaf"}} {
+		if got, want := v.ToMap(), structinfo.ToMap(v); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T:\n got %v\nwant %v", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Code generated by structgen; DO NOT EDIT.
+
+package fixture
+
+import (
+	"cmp"
+	"fmt"
+	"reflection/validate"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+var structgenRE0 = regexp.MustCompile("^(?:[0-9]{6})$")
+
+// Validate проверяет Order по тегам validate — так же, как validate.Struct.
+func (s *Order) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: nil %T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, "Order", "")
+	return w.result()
+}
+
+// ToMap возвращает поля Order по ключам structinfo.ToMap.
+func (s *Order) ToMap() map[string]any {
+	return map[string]any{
+		"status":     s.Status,
+		"customer":   s.Customer,
+		"lines":      s.Lines,
+		"tags":       s.Tags,
+		"matrix":     s.Matrix,
+		"labels":     s.Labels,
+		"extras":     s.Extras,
+		"codes":      s.Codes,
+		"created_at": s.CreatedAt,
+		"shipped_at": s.ShippedAt,
+		"tracking":   s.Tracking,
+		"paid":       s.Paid,
+		"discount":   s.Discount,
+		"total":      s.Total,
+		"meta":       s.Meta,
+	}
+}
+
+// Validate проверяет Customer по тегам validate — так же, как validate.Struct.
+func (s *Customer) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: nil %T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, "Customer", "")
+	return w.result()
+}
+
+// ToMap возвращает поля Customer по ключам structinfo.ToMap.
+func (s *Customer) ToMap() map[string]any {
+	return map[string]any{
+		"Contact": s.Contact,
+		"id":      s.ID,
+		"name":    s.Name,
+		"site":    s.Site,
+		"nick":    s.Nick,
+		"age":     s.Age,
+		"score":   s.Score,
+		"tier":    s.Tier,
+		"vip":     s.VIP,
+		"-":       s.Confirm,
+		"home":    s.Home,
+		"work":    s.Work,
+	}
+}
+
+// Validate проверяет Node по тегам validate — так же, как validate.Struct.
+func (s *Node) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: nil %T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, "Node", "")
+	return w.result()
+}
+
+// ToMap возвращает поля Node по ключам structinfo.ToMap.
+func (s *Node) ToMap() map[string]any {
+	return map[string]any{
+		"name":     s.Name,
+		"next":     s.Next,
+		"children": s.Children,
+	}
+}
+
+func (s *Order) structgenValidate(w *structgenWalker, path, json string) {
+	s.stamp.structgenValidate(w, structgenJoin(path, "stamp"), json)
+	switch string(s.Status) {
+	case "new", "paid", "shipped":
+	default:
+		w.fail(structgenJoin(path, "Status"), structgenJoin(json, "status"), "oneof", "new paid shipped", s.Status)
+	}
+	if p1 := s.Customer; p1 != nil {
+		if w.enter(p1) {
+			if (*p1) == (Customer{}) {
+				w.fail(structgenJoin(path, "Customer"), structgenJoin(json, "customer"), "required", "", (*p1))
+			}
+			(*p1).structgenValidate(w, structgenJoin(path, "Customer"), structgenJoin(json, "customer"))
+			w.leave(p1)
+		}
+	} else {
+		w.fail(structgenJoin(path, "Customer"), structgenJoin(json, "customer"), "required", "", p1)
+	}
+	if !(float64(len(s.Lines)) >= 1) {
+		w.fail(structgenJoin(path, "Lines"), structgenJoin(json, "lines"), "min", "1", s.Lines)
+	}
+	if !(float64(len(s.Lines)) <= 3) {
+		w.fail(structgenJoin(path, "Lines"), structgenJoin(json, "lines"), "max", "3", s.Lines)
+	}
+	for i2 := range s.Lines {
+		s.Lines[i2].structgenValidate(w, structgenIndex(structgenJoin(path, "Lines"), i2), structgenIndex(structgenJoin(json, "lines"), i2))
+	}
+	if !(float64(len(s.Tags)) <= 3) {
+		w.fail(structgenJoin(path, "Tags"), structgenJoin(json, "tags"), "max", "3", s.Tags)
+	}
+	for i3 := range s.Tags {
+		if s.Tags[i3] == "" {
+			w.fail(structgenIndex(structgenJoin(path, "Tags"), i3), structgenIndex(structgenJoin(json, "tags"), i3), "required", "", s.Tags[i3])
+		}
+		if !(float64(utf8.RuneCountInString(string(s.Tags[i3]))) <= 8) {
+			w.fail(structgenIndex(structgenJoin(path, "Tags"), i3), structgenIndex(structgenJoin(json, "tags"), i3), "max", "8", s.Tags[i3])
+		}
+	}
+	for i4 := range s.Matrix {
+		if !(float64(len(s.Matrix[i4])) >= 1) {
+			w.fail(structgenIndex(structgenJoin(path, "Matrix"), i4), structgenIndex(structgenJoin(json, "matrix"), i4), "min", "1", s.Matrix[i4])
+		}
+		for i5 := range s.Matrix[i4] {
+			if !(float64(s.Matrix[i4][i5]) >= 0) {
+				w.fail(structgenIndex(structgenIndex(structgenJoin(path, "Matrix"), i4), i5), structgenIndex(structgenIndex(structgenJoin(json, "matrix"), i4), i5), "min", "0", s.Matrix[i4][i5])
+			}
+			if !(float64(s.Matrix[i4][i5]) <= 9) {
+				w.fail(structgenIndex(structgenIndex(structgenJoin(path, "Matrix"), i4), i5), structgenIndex(structgenIndex(structgenJoin(json, "matrix"), i4), i5), "max", "9", s.Matrix[i4][i5])
+			}
+		}
+	}
+	for k6, v7 := range s.Labels {
+		if !(float64(utf8.RuneCountInString(string(v7))) <= 4) {
+			w.fail(structgenIndex(structgenJoin(path, "Labels"), k6), structgenIndex(structgenJoin(json, "labels"), k6), "max", "4", v7)
+		}
+	}
+	for k8, v9 := range s.Extras {
+		if p10 := v9; p10 != nil {
+			if w.enter(p10) {
+				if (*p10).SKU == "" && (*p10).Qty == 0 && (*p10).Min == 0 && (*p10).Max == nil && (*p10).Notes == nil {
+					w.fail(structgenIndex(structgenJoin(path, "Extras"), k8), structgenIndex(structgenJoin(json, "extras"), k8), "required", "", (*p10))
+				}
+				(*p10).structgenValidate(w, structgenIndex(structgenJoin(path, "Extras"), k8), structgenIndex(structgenJoin(json, "extras"), k8))
+				w.leave(p10)
+			}
+		} else {
+			w.fail(structgenIndex(structgenJoin(path, "Extras"), k8), structgenIndex(structgenJoin(json, "extras"), k8), "required", "", p10)
+		}
+	}
+	for i11 := range s.Codes {
+		if !(s.Codes[i11] == "") {
+			if !(float64(utf8.RuneCountInString(string(s.Codes[i11]))) == 3) {
+				w.fail(structgenIndex(structgenJoin(path, "Codes"), i11), structgenIndex(structgenJoin(json, "codes"), i11), "len", "3", s.Codes[i11])
+			}
+		}
+	}
+	if s.CreatedAt == (time.Time{}) {
+		w.fail(structgenJoin(path, "CreatedAt"), structgenJoin(json, "created_at"), "required", "", s.CreatedAt)
+	}
+	if p12 := s.ShippedAt; p12 != nil {
+		if (string(s.Status) == "shipped") && (*p12) == (time.Time{}) {
+			w.fail(structgenJoin(path, "ShippedAt"), structgenJoin(json, "shipped_at"), "required_if", "Status shipped", (*p12))
+		}
+		if !((*p12) == (time.Time{})) {
+			if !((*p12).Compare(s.CreatedAt) > 0) {
+				w.fail(structgenJoin(path, "ShippedAt"), structgenJoin(json, "shipped_at"), "gtfield", "CreatedAt", (*p12))
+			}
+		}
+	} else {
+		if string(s.Status) == "shipped" {
+			w.fail(structgenJoin(path, "ShippedAt"), structgenJoin(json, "shipped_at"), "required_if", "Status shipped", p12)
+		}
+	}
+	if (string(s.Status) == "shipped") && s.Tracking == "" {
+		w.fail(structgenJoin(path, "Tracking"), structgenJoin(json, "tracking"), "required_if", "Status shipped", s.Tracking)
+	}
+	if !(strconv.FormatBool(bool(s.Paid)) == "true") && s.Tracking == "" {
+		w.fail(structgenJoin(path, "Tracking"), structgenJoin(json, "tracking"), "required_unless", "Paid true", s.Tracking)
+	}
+	if !(cmp.Compare(float64(s.Discount), float64(s.Total)) < 0) {
+		w.fail(structgenJoin(path, "Discount"), structgenJoin(json, "discount"), "ltfield", "Total", s.Discount)
+	}
+	if !(cmp.Compare(float64(s.Total), float64(s.Discount)) != 0) {
+		w.fail(structgenJoin(path, "Total"), structgenJoin(json, "total"), "nefield", "Discount", s.Total)
+	}
+	w.check(s, path, json)
+}
+
+func (s *Customer) structgenValidate(w *structgenWalker, path, json string) {
+	s.Contact.structgenValidate(w, structgenJoin(path, "Contact"), json)
+	if s.ID == "" {
+		w.fail(structgenJoin(path, "ID"), structgenJoin(json, "id"), "required", "", s.ID)
+	}
+	if !validate.IsUUID(string(s.ID)) {
+		w.fail(structgenJoin(path, "ID"), structgenJoin(json, "id"), "uuid", "", s.ID)
+	}
+	if s.Name == "" {
+		w.fail(structgenJoin(path, "Name"), structgenJoin(json, "name"), "required", "", s.Name)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.Name))) >= 2) {
+		w.fail(structgenJoin(path, "Name"), structgenJoin(json, "name"), "min", "2", s.Name)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.Name))) <= 16) {
+		w.fail(structgenJoin(path, "Name"), structgenJoin(json, "name"), "max", "16", s.Name)
+	}
+	if p1 := s.Site; p1 != nil {
+		if !((*p1) == "") {
+			if !validate.IsURL(string((*p1))) {
+				w.fail(structgenJoin(path, "Site"), structgenJoin(json, "site"), "url", "", (*p1))
+			}
+		}
+	}
+	if p2 := s.Nick; p2 != nil {
+		if (*p2) == "" {
+			w.fail(structgenJoin(path, "Nick"), structgenJoin(json, "nick"), "required", "", (*p2))
+		}
+	} else {
+		w.fail(structgenJoin(path, "Nick"), structgenJoin(json, "nick"), "required", "", p2)
+	}
+	if !(float64(s.Age) >= 18) {
+		w.fail(structgenJoin(path, "Age"), structgenJoin(json, "age"), "min", "18", s.Age)
+	}
+	if !(float64(s.Age) <= 130) {
+		w.fail(structgenJoin(path, "Age"), structgenJoin(json, "age"), "max", "130", s.Age)
+	}
+	if !(float64(s.Score) <= 1.5) {
+		w.fail(structgenJoin(path, "Score"), structgenJoin(json, "score"), "max", "1.5", s.Score)
+	}
+	switch strconv.FormatUint(uint64(s.Tier), 10) {
+	case "1", "2", "3":
+	default:
+		w.fail(structgenJoin(path, "Tier"), structgenJoin(json, "tier"), "oneof", "1 2 3", s.Tier)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.Password))) >= 8) {
+		w.fail(structgenJoin(path, "Password"), structgenJoin(json, "Password"), "min", "8", s.Password)
+	}
+	if !(cmp.Compare(string(s.Confirm), string(s.Password)) == 0) {
+		w.fail(structgenJoin(path, "Confirm"), structgenJoin(json, "Confirm"), "eqfield", "Password", s.Confirm)
+	}
+	if p3 := s.Home; p3 != nil {
+		if w.enter(p3) {
+			if (*p3) == (Address{}) {
+				w.fail(structgenJoin(path, "Home"), structgenJoin(json, "home"), "required", "", (*p3))
+			}
+			(*p3).structgenValidate(w, structgenJoin(path, "Home"), structgenJoin(json, "home"))
+			w.leave(p3)
+		}
+	} else {
+		w.fail(structgenJoin(path, "Home"), structgenJoin(json, "home"), "required", "", p3)
+	}
+	s.Work.structgenValidate(w, structgenJoin(path, "Work"), structgenJoin(json, "work"))
+	w.check(s, path, json)
+}
+
+func (s *Node) structgenValidate(w *structgenWalker, path, json string) {
+	if s.Name == "" {
+		w.fail(structgenJoin(path, "Name"), structgenJoin(json, "name"), "required", "", s.Name)
+	}
+	if p1 := s.Next; p1 != nil {
+		if w.enter(p1) {
+			(*p1).structgenValidate(w, structgenJoin(path, "Next"), structgenJoin(json, "next"))
+			w.leave(p1)
+		}
+	}
+	for i2 := range s.Children {
+		if p3 := s.Children[i2]; p3 != nil {
+			if w.enter(p3) {
+				(*p3).structgenValidate(w, structgenIndex(structgenJoin(path, "Children"), i2), structgenIndex(structgenJoin(json, "children"), i2))
+				w.leave(p3)
+			}
+		}
+	}
+	w.check(s, path, json)
+}
+
+func (s *stamp) structgenValidate(w *structgenWalker, path, json string) {
+	if !(float64(utf8.RuneCountInString(string(s.Author))) <= 8) {
+		w.fail(structgenJoin(path, "Author"), structgenJoin(json, "author"), "max", "8", s.Author)
+	}
+	w.check(s, path, json)
+}
+
+func (s *Line) structgenValidate(w *structgenWalker, path, json string) {
+	if s.SKU == "" {
+		w.fail(structgenJoin(path, "SKU"), structgenJoin(json, "sku"), "required", "", s.SKU)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.SKU))) == 8) {
+		w.fail(structgenJoin(path, "SKU"), structgenJoin(json, "sku"), "len", "8", s.SKU)
+	}
+	if !(cmp.Compare(float64(s.Qty), float64(s.Min)) >= 0) {
+		w.fail(structgenJoin(path, "Qty"), structgenJoin(json, "qty"), "gtefield", "Min", s.Qty)
+	}
+	if s.Max != nil && !(cmp.Compare(float64(s.Qty), float64(*s.Max)) <= 0) {
+		w.fail(structgenJoin(path, "Qty"), structgenJoin(json, "qty"), "ltefield", "Max", s.Qty)
+	}
+	w.check(s, path, json)
+}
+
+func (s *Contact) structgenValidate(w *structgenWalker, path, json string) {
+	if (s.Phone == "") && s.Email == "" {
+		w.fail(structgenJoin(path, "Email"), structgenJoin(json, "email"), "required_without", "Phone", s.Email)
+	}
+	if !(s.Email == "") {
+		if !validate.IsEmail(string(s.Email)) {
+			w.fail(structgenJoin(path, "Email"), structgenJoin(json, "email"), "email", "", s.Email)
+		}
+	}
+	if (!(s.Phone == "") || !(s.Email == "")) && s.Fax == "" {
+		w.fail(structgenJoin(path, "Fax"), structgenJoin(json, "fax"), "required_with", "Phone Email", s.Fax)
+	}
+	w.check(s, path, json)
+}
+
+func (s *Address) structgenValidate(w *structgenWalker, path, json string) {
+	if s.City == "" {
+		w.fail(structgenJoin(path, "City"), structgenJoin(json, "city"), "required", "", s.City)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.City))) <= 32) {
+		w.fail(structgenJoin(path, "City"), structgenJoin(json, "city"), "max", "32", s.City)
+	}
+	if !(s.Zip == "") {
+		if !structgenRE0.MatchString(string(s.Zip)) {
+			w.fail(structgenJoin(path, "Zip"), structgenJoin(json, "zip"), "regexp", "[0-9]{6}", s.Zip)
+		}
+	}
+	w.check(s, path, json)
+}
+
+// structgenWalker копит нарушения одной проверки.
+type structgenWalker struct {
+	errs validate.ValidationErrors
+	// seen — указатели на структуры на текущем пути: защита от циклов.
+	seen map[any]bool
+}
+
+func (w *structgenWalker) fail(path, json, rule, param string, value any) {
+	w.errs = append(w.errs, &validate.FieldError{Path: path, JSONPath: json, Rule: rule, Param: param, Value: value})
+}
+
+func (w *structgenWalker) enter(p any) bool {
+	if w.seen[p] {
+		return false
+	}
+	if w.seen == nil {
+		w.seen = make(map[any]bool)
+	}
+	w.seen[p] = true
+	return true
+}
+
+func (w *structgenWalker) leave(p any) { delete(w.seen, p) }
+
+func (w *structgenWalker) check(s any, path, json string) {
+	w.errs = append(w.errs, validate.CheckStruct(s, path, json)...)
+}
+
+func (w *structgenWalker) result() error {
+	if len(w.errs) == 0 {
+		return nil
+	}
+	return w.errs
+}
+
+func structgenJoin(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func structgenIndex(path string, key any) string {
+	return path + fmt.Sprintf("[%v]", key)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"reflection/structinfo"
+)
+
+// generatedHeader — по нему узнаём свои и чужие сгенерированные файлы.
+const generatedHeader = "// Code generated by"
+
+// kind — то, что генератору важно знать о типе поля.
+type kind int
+
+const (
+	kindString kind = iota + 1
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindStruct // структура этого пакета
+	kindTime   // time.Time
+	kindPointer
+	kindSlice
+	kindArray
+	kindMap
+	kindInterface
+	// kindOpaque — chan, func, complex, uintptr: правил к ним нет, и
+	// validate.Struct внутрь не заходит.
+	kindOpaque
+	// kindUnknown — тип другого пакета, анонимная структура, generic:
+	// что у них внутри, генератор не видит.
+	kindUnknown
+)
+
+// goType — тип поля.
+type goType struct {
+	kind kind
+	// expr — тип в записи исходников пакета: "Role", "[]string", "*Address".
+	expr      string
+	elem, key *goType
+	decl      *structDecl
+	// zeroValue — нулевое значение kindOpaque: 0 или nil.
+	zeroValue string
+}
+
+// structDecl — структура пакета.
+type structDecl struct {
+	name   string
+	pos    token.Pos
+	spec   *ast.TypeSpec
+	file   *ast.File
+	fields []*field
+	// loaded — поля уже разобраны (структуры ссылаются друг на друга).
+	loaded bool
+}
+
+// field — поле структуры; имена и теги разобраны structinfo.ParseTags,
+// как у рефлексивных версий.
+type field struct {
+	structinfo.Field
+	typ *goType
+	pos token.Pos
+}
+
+// pkg — разобранный пакет.
+type pkg struct {
+	fset    *token.FileSet
+	name    string
+	specs   map[string]*ast.TypeSpec
+	files   map[*ast.TypeSpec]*ast.File
+	structs map[string]*structDecl
+	// resolving — именованные типы, которые typeOf разбирает сейчас.
+	resolving map[string]bool
+}
+
+// loadPackage читает .go файлы dir, кроме тестов, сгенерированных
+// файлов и output.
+func loadPackage(dir, output string) (*pkg, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	p := &pkg{
+		fset:      token.NewFileSet(),
+		specs:     make(map[string]*ast.TypeSpec),
+		files:     make(map[*ast.TypeSpec]*ast.File),
+		structs:   make(map[string]*structDecl),
+		resolving: make(map[string]bool),
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") || filepath.Base(name) == output {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(src, []byte(generatedHeader)) {
+			continue
+		}
+		f, err := parser.ParseFile(p.fset, name, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if p.name == "" {
+			p.name = f.Name.Name
+		} else if p.name != f.Name.Name {
+			return nil, fmt.Errorf("%s: package %s, want %s", name, f.Name.Name, p.name)
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				p.specs[ts.Name.Name] = ts
+				p.files[ts] = f
+			}
+		}
+	}
+	if p.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return p, nil
+}
+
+// structOf возвращает структуру пакета с разобранными полями.
+func (p *pkg) structOf(name string) (*structDecl, error) {
+	ts, ok := p.specs[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s not found", name)
+	}
+	if _, ok := ts.Type.(*ast.StructType); !ok || ts.TypeParams != nil || ts.Assign != 0 {
+		return nil, fmt.Errorf("%s: %s is not a plain struct type", p.fset.Position(ts.Pos()), name)
+	}
+	d := p.decl(ts)
+	if d.loaded {
+		return d, nil
+	}
+	d.loaded = true
+
+	st := ts.Type.(*ast.StructType)
+	for _, af := range st.Fields.List {
+		tag := reflect.StructTag("")
+		if af.Tag != nil {
+			raw, err := strconv.Unquote(af.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad tag: %v", p.fset.Position(af.Tag.Pos()), err)
+			}
+			tag = reflect.StructTag(raw)
+		}
+		typ := p.typeOf(af.Type, d.file)
+
+		names := af.Names
+		anonymous := len(names) == 0
+		if anonymous {
+			names = []*ast.Ident{ast.NewIdent(embeddedName(af.Type))}
+		}
+		for _, n := range names {
+			f := &field{Field: structinfo.ParseTags(n.Name, tag), typ: typ, pos: af.Pos()}
+			f.Index = len(d.fields)
+			f.Exported = ast.IsExported(n.Name)
+			f.Anonymous = anonymous
+			d.fields = append(d.fields, f)
+		}
+	}
+	return d, nil
+}
+
+func (p *pkg) decl(ts *ast.TypeSpec) *structDecl {
+	d, ok := p.structs[ts.Name.Name]
+	if !ok {
+		d = &structDecl{name: ts.Name.Name, pos: ts.Pos(), spec: ts, file: p.files[ts]}
+		p.structs[ts.Name.Name] = d
+	}
+	return d
+}
+
+// embeddedName — имя поля встроенного типа: T, *T, pkg.T.
+func embeddedName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return types.ExprString(e)
+}
+
+var basicKinds = map[string]kind{
+	"string": kindString,
+	"bool":   kindBool,
+	"int":    kindInt, "int8": kindInt, "int16": kindInt, "int32": kindInt, "int64": kindInt, "rune": kindInt,
+	"uint": kindUint, "uint8": kindUint, "uint16": kindUint, "uint32": kindUint, "uint64": kindUint, "byte": kindUint,
+	"float32": kindFloat, "float64": kindFloat,
+	"any": kindInterface, "error": kindInterface,
+	"complex64": kindOpaque, "complex128": kindOpaque, "uintptr": kindOpaque,
+}
+
+// typeOf разбирает тип поля. Ошибок здесь нет: неподдержанный тип
+// становится kindUnknown, и жалоба будет, только если его придётся
+// проверять.
+func (p *pkg) typeOf(e ast.Expr, file *ast.File) *goType {
+	switch t := e.(type) {
+	case *ast.ParenExpr:
+		return p.typeOf(t.X, file)
+	case *ast.Ident:
+		if k, ok := basicKinds[t.Name]; ok {
+			return &goType{kind: k, expr: t.Name, zeroValue: "0"}
+		}
+		if ts, ok := p.specs[t.Name]; ok {
+			return p.named(ts)
+		}
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && t.Sel.Name == "Time" && importPath(file, x.Name) == "time" {
+			return &goType{kind: kindTime, expr: "time.Time"}
+		}
+	case *ast.StarExpr:
+		elem := p.typeOf(t.X, file)
+		return &goType{kind: kindPointer, expr: "*" + elem.expr, elem: elem}
+	case *ast.ArrayType:
+		elem := p.typeOf(t.Elt, file)
+		if t.Len == nil {
+			return &goType{kind: kindSlice, expr: "[]" + elem.expr, elem: elem}
+		}
+		return &goType{kind: kindArray, expr: "[" + types.ExprString(t.Len) + "]" + elem.expr, elem: elem}
+	case *ast.MapType:
+		key, elem := p.typeOf(t.Key, file), p.typeOf(t.Value, file)
+		return &goType{kind: kindMap, expr: "map[" + key.expr + "]" + elem.expr, key: key, elem: elem}
+	case *ast.InterfaceType:
+		return &goType{kind: kindInterface, expr: types.ExprString(t)}
+	case *ast.ChanType, *ast.FuncType:
+		return &goType{kind: kindOpaque, expr: types.ExprString(t), zeroValue: "nil"}
+	}
+	return &goType{kind: kindUnknown, expr: types.ExprString(e)}
+}
+
+// named — тип, объявленный в пакете.
+func (p *pkg) named(ts *ast.TypeSpec) *goType {
+	name := ts.Name.Name
+	if ts.TypeParams != nil {
+		return &goType{kind: kindUnknown, expr: name}
+	}
+	if _, ok := ts.Type.(*ast.StructType); ok {
+		if ts.Assign != 0 {
+			// псевдоним анонимной структуры.
+			return &goType{kind: kindUnknown, expr: name}
+		}
+		return &goType{kind: kindStruct, expr: name, decl: p.decl(ts)}
+	}
+
+	// type List []List: на повторном входе тип уже не разобрать.
+	if p.resolving[name] {
+		return &goType{kind: kindUnknown, expr: name}
+	}
+	p.resolving[name] = true
+	defer delete(p.resolving, name)
+
+	under := *p.typeOf(ts.Type, p.files[ts])
+	if ts.Assign == 0 {
+		switch under.kind {
+		case kindStruct, kindTime, kindUnknown:
+			// type B A — новый тип со своими (пустыми) методами, а
+			// для reflect это уже не time.Time.
+			return &goType{kind: kindUnknown, expr: name}
+		}
+	}
+	under.expr = name
+	return &under
+}
+
+// importPath — путь импорта, который в file виден под именем name.
+func importPath(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		local := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		if local == name {
+			return path
+		}
+	}
+	return ""
+}
+
+// comparable — можно ли сравнить значение с нулевым через ==. Нужно для
+// required и omitempty на структурах и массивах: reflect.Value.IsZero у
+// них — это сравнение с нулевым значением.
+func (p *pkg) comparable(t *goType) bool {
+	switch t.kind {
+	case kindString, kindBool, kindInt, kindUint, kindFloat, kindTime, kindPointer, kindInterface:
+		return true
+	case kindArray:
+		return p.comparable(t.elem)
+	case kindStruct:
+		d, err := p.structOf(t.decl.name)
+		if err != nil {
+			return false
+		}
+		for _, f := range d.fields {
+			if !p.comparable(f.typ) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+// Command structgen генерирует для структур пакета методы
+//
+//	func (s *T) Validate() error           // как validate.Struct(s)
+//	func (s *T) ToMap() map[string]any     // как structinfo.ToMap(s) и structToMap
+//
+// Теги json, db и validate читаются из исходников (go/ast) тем же
+// разбором, что у рефлексивных версий, а проверки превращаются в обычный
+// код без reflect и без разбора тегов во время работы.
+//
+//	//go:generate go run reflection/cmd/structgen -type User,Admin
+//
+// Поведение сгенерированного кода совпадает с рефлексивным: те же
+// ValidationErrors с теми же путями, правилами и значениями, в том же
+// порядке (кроме порядка элементов map, который случаен в обоих). Проверки
+// типов из validate.RegisterStruct тоже запускаются.
+//
+// Всё, что так не повторить, генератор отклоняет с ошибкой, а не
+// пропускает молча: правила из validate.Register, ссылки на поля других
+// структур (eqfield=Other.Field), поля-интерфейсы, типы других пакетов,
+// кроме time.Time. Для таких типов остаётся validate.Struct.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultOutput — имя файла с результатом в каталоге пакета.
+const defaultOutput = "structgen_gen.go"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("structgen: ")
+
+	typeList := flag.String("type", "", "comma-separated struct types to generate methods for")
+	dir := flag.String("dir", ".", "package directory")
+	output := flag.String("output", defaultOutput, "output file name, relative to -dir")
+	flag.Parse()
+
+	if *typeList == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src, err := generate(*dir, strings.Split(*typeList, ","), *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,3 @@
+module reflection
+
+go 1.23
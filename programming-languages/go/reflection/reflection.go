@@ -1,17 +1,24 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+
+	"reflection/structinfo"
+	"reflection/validate"
 )
 
+// Validate и ToMap без рефлексии для тех же типов — в structgen_gen.go.
+//go:generate go run reflection/cmd/structgen -type User,Admin,Signup
+
 // ===== БАЗОВЫЕ СТРУКТУРЫ ДЛЯ ПРИМЕРОВ =====
 type User struct {
 	ID       int                    `json:"id" db:"user_id" validate:"required,min=1"`
-	Name     string                 `json:"name" db:"user_name" validate:"required"`
+	Name     string                 `json:"name" db:"user_name" validate:"required,max=64"`
 	Email    string                 `json:"email" db:"email" validate:"email"`
-	Tags     []string               `json:"tags"`
+	Tags     []string               `json:"tags" validate:"max=10,dive,required,max=32"`
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
@@ -21,6 +28,27 @@ type Admin struct {
 	secret string // приватное поле
 }
 
+// Signup — правила, которые смотрят на соседние поля.
+type Signup struct {
+	Login      string `json:"login" validate:"required"`
+	Password   string `json:"password" validate:"required,min=8"`
+	Confirm    string `json:"confirm" validate:"eqfield=Password"`
+	Type       string `json:"type" validate:"oneof=user admin"`
+	InviteCode string `json:"invite_code" validate:"required_if=Type admin"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email" validate:"required_without=Phone,omitempty,email"`
+}
+
+// проверка Signup целиком: логин не должен быть частью пароля.
+func init() {
+	validate.RegisterStruct(Signup{}, func(sl validate.StructLevel) {
+		s := sl.Value.Interface().(Signup)
+		if s.Login != "" && strings.Contains(strings.ToLower(s.Password), strings.ToLower(s.Login)) {
+			sl.Report("Password", "not_login", "")
+		}
+	})
+}
+
 // ===== ОСНОВЫ REFLECT =====
 func reflectBasics() {
 	fmt.Println("\n=== ОСНОВЫ REFLECT ===")
@@ -55,22 +83,23 @@ func inspectStruct(obj interface{}) {
 		return
 	}
 
+	// поля и теги разобраны один раз на тип (см. пакет structinfo)
+	info := structinfo.Of(t)
 	fmt.Printf("Struct: %s\n", t.Name())
-	fmt.Printf("Fields count: %d\n", t.NumField())
+	fmt.Printf("Fields count: %d\n", len(info.Fields))
 
 	// итерация по полям
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		fieldValue := v.Field(i)
+	for _, field := range info.Fields {
+		fieldValue := v.Field(field.Index)
 
-		fmt.Printf("\nField %d: %s\n", i, field.Name)
+		fmt.Printf("\nField %d: %s\n", field.Index, field.Name)
 		fmt.Printf("  Type: %v\n", field.Type)
 		fmt.Printf("  Kind: %v\n", field.Type.Kind())
 		fmt.Printf("  CanSet: %v\n", fieldValue.CanSet())
-		fmt.Printf("  IsExported: %v\n", field.IsExported())
+		fmt.Printf("  IsExported: %v\n", field.Exported)
 
 		// БЕЗОПАСНОЕ получение значения
-		if field.IsExported() {
+		if field.Exported {
 			// для экспортированных полей можно использовать Interface()
 			fmt.Printf("  Value: %v\n", fieldValue.Interface())
 		} else {
@@ -79,16 +108,16 @@ func inspectStruct(obj interface{}) {
 		}
 
 		// чтение тегов
-		if tag := field.Tag; tag != "" {
+		if field.JSONTag != "" || field.DBTag != "" || field.ValidateTag != "" {
 			fmt.Printf("  Tags:\n")
-			if jsonTag := tag.Get("json"); jsonTag != "" {
-				fmt.Printf("    json: %s\n", jsonTag)
+			if field.JSONTag != "" {
+				fmt.Printf("    json: %s\n", field.JSONTag)
 			}
-			if dbTag := tag.Get("db"); dbTag != "" {
-				fmt.Printf("    db: %s\n", dbTag)
+			if field.DBTag != "" {
+				fmt.Printf("    db: %s\n", field.DBTag)
 			}
-			if validateTag := tag.Get("validate"); validateTag != "" {
-				fmt.Printf("    validate: %s\n", validateTag)
+			if field.ValidateTag != "" {
+				fmt.Printf("    validate: %s\n", field.ValidateTag)
 			}
 		}
 	}
@@ -254,84 +283,55 @@ func callMethods() {
 }
 
 // ===== РАБОТА С ТЭГАМИ - ВАЛИДАЦИЯ =====
-func validateStruct(obj interface{}) []string {
+// Разбор тегов живёт в пакете validate: реестр правил, вложенные и
+// встроенные структуры, dive для срезов и map. Здесь только вывод.
+func validateStruct(obj interface{}) validate.ValidationErrors {
 	fmt.Println("\n=== ВАЛИДАЦИЯ ПО ТЭГАМ ===")
 
-	var errors []string
-	v := reflect.ValueOf(obj).Elem()
-	t := v.Type()
-
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		// пропускаем приватные поля
-		if !fieldType.IsExported() {
-			continue
-		}
-
-		validateTag := fieldType.Tag.Get("validate")
-		if validateTag == "" {
-			continue
-		}
+	err := validate.Struct(obj)
+	if err == nil {
+		fmt.Println("Validation passed!")
+		return nil
+	}
 
-		rules := strings.Split(validateTag, ",")
-		for _, rule := range rules {
-			switch {
-			case rule == "required":
-				if isZero(field) {
-					errors = append(errors,
-						fmt.Sprintf("%s is required", fieldType.Name))
-				}
-			case rule == "email":
-				if field.Kind() == reflect.String {
-					email := field.String()
-					if !strings.Contains(email, "@") {
-						errors = append(errors,
-							fmt.Sprintf("%s must be a valid email", fieldType.Name))
-					}
-				}
-			case strings.HasPrefix(rule, "min="):
-				var min int
-				fmt.Sscanf(rule, "min=%d", &min)
-				if field.Kind() == reflect.Int && field.Int() < int64(min) {
-					errors = append(errors,
-						fmt.Sprintf("%s must be at least %d", fieldType.Name, min))
-				}
-			case strings.HasPrefix(rule, "max="):
-				var max int
-				fmt.Sscanf(rule, "max=%d", &max)
-				if field.Kind() == reflect.Int && field.Int() > int64(max) {
-					errors = append(errors,
-						fmt.Sprintf("%s must be at most %d", fieldType.Name, max))
-				}
-			}
-		}
+	var errs validate.ValidationErrors
+	if !errors.As(err, &errs) {
+		// ошибка в самих тегах, а не в данных
+		fmt.Println("Invalid validate tag:", err)
+		return nil
 	}
 
-	if len(errors) > 0 {
-		fmt.Println("Validation errors:", errors)
-	} else {
-		fmt.Println("Validation passed!")
+	// каждое нарушение — отдельное поле ответа API
+	for _, fe := range errs {
+		fmt.Printf("  %-16s json=%-8s rule=%-8s param=%-4q value=%#v\n",
+			fe.Path, fe.JSONPath, fe.Rule, fe.Param, fe.Value)
 	}
+	fmt.Println("Validation errors (en):", strings.Join(errs.Format(validate.English), "; "))
+	fmt.Println("Validation errors (ru):", strings.Join(errs.Format(validate.Russian), "; "))
 
-	return errors
+	return errs
 }
 
-// проверка на нулевое значение
-func isZero(v reflect.Value) bool {
-	switch v.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return v.Int() == 0
-	case reflect.String:
-		return v.String() == ""
-	case reflect.Bool:
-		return !v.Bool()
-	case reflect.Slice, reflect.Map:
-		return v.Len() == 0
-	default:
-		return false
+// ===== СГЕНЕРИРОВАННЫЙ КОД ВМЕСТО РЕФЛЕКСИИ =====
+// structgen превращает те же теги в обычные методы: ни reflect, ни
+// разбора тегов во время работы. Результат должен совпадать с
+// validate.Struct и structinfo.ToMap полностью.
+func compareGenerated(user *User, admin *Admin, signup *Signup) {
+	fmt.Println("\n=== СГЕНЕРИРОВАННЫЙ КОД ===")
+
+	for _, obj := range []interface{ Validate() error }{user, admin, signup} {
+		generated, reflected := obj.Validate(), validate.Struct(obj)
+		fmt.Printf("%-13T same as validate.Struct: %v (%d errors)\n",
+			obj, reflect.DeepEqual(generated, reflected), len(failures(generated)))
 	}
+	fmt.Printf("ToMap same as structinfo.ToMap: %v\n",
+		reflect.DeepEqual(user.ToMap(), structinfo.ToMap(user)))
+}
+
+func failures(err error) validate.ValidationErrors {
+	var errs validate.ValidationErrors
+	errors.As(err, &errs)
+	return errs
 }
 
 // ===== ДИНАМИЧЕСКОЕ СОЗДАНИЕ СТРУКТУР =====
@@ -373,29 +373,12 @@ func createDynamic() {
 }
 
 // ===== ПРАКТИЧЕСКИЙ ПРИМЕР: СЕРИАЛИЗАЦИЯ В MAP =====
+// Поля и теги разобраны один раз на тип (structinfo). Ключ — часть тега
+// json до запятой, даже если она "-" или пустая, без тега — имя поля;
+// встроенные структуры не разворачиваются. Сгенерированный structgen
+// метод ToMap строит те же ключи.
 func structToMap(obj interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	v := reflect.ValueOf(obj).Elem()
-	t := v.Type()
-
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		if !fieldType.IsExported() {
-			continue // Пропускаем приватные поля
-		}
-
-		// используем json тег как ключ, или имя поля
-		key := fieldType.Name
-		if jsonTag := fieldType.Tag.Get("json"); jsonTag != "" {
-			key = strings.Split(jsonTag, ",")[0]
-		}
-
-		result[key] = field.Interface()
-	}
-
-	return result
+	return structinfo.ToMap(obj)
 }
 
 func main() {
@@ -442,6 +425,22 @@ func main() {
 	}
 	validateStruct(&invalidUser)
 
+	// правила встроенного User проверяются и у Admin
+	invalidAdmin := Admin{User: invalidUser, Level: 11}
+	validateStruct(&invalidAdmin)
+
+	// правила между полями и проверка всей структуры
+	signup := Signup{
+		Login:    "alice",
+		Password: "alice2024",
+		Confirm:  "alice2025",
+		Type:     "admin",
+	}
+	validateStruct(&signup)
+
+	// те же проверки сгенерированным кодом
+	compareGenerated(&invalidUser, &invalidAdmin, &signup)
+
 	// динамическое создание объектов
 	createDynamic()
 
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// structToMapUncached — structToMap до кэша structinfo: теги разбираются
+// на каждом вызове. Оставлена для сравнения в бенчмарке и как образец
+// ключей, которые structToMap должна сохранить.
+func structToMapUncached(obj interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		fieldType := t.Field(i)
+
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		key := fieldType.Name
+		if jsonTag := fieldType.Tag.Get("json"); jsonTag != "" {
+			key = strings.Split(jsonTag, ",")[0]
+		}
+
+		result[key] = field.Interface()
+	}
+
+	return result
+}
+
+// ===== БЕНЧМАРКИ =====
+func BenchmarkStructToMap(b *testing.B) {
+	user := &User{ID: 1, Name: "Alice", Email: "alice@example.com", Tags: []string{"go"}}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			structToMapUncached(user)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			structToMap(user)
+		}
+	})
+}
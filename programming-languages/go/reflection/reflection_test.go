@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// кэш structinfo не должен менять результат structToMap.
+func TestStructToMapKeys(t *testing.T) {
+	type tagged struct {
+		Name    string `json:"name"`
+		Skipped string `json:"-"`
+		Empty   string `json:",omitempty"`
+		Plain   string
+		private string
+	}
+	v := &tagged{Name: "n", Skipped: "s", Empty: "e", Plain: "p", private: "x"}
+
+	want := map[string]interface{}{"name": "n", "-": "s", "": "e", "Plain": "p"}
+	if got := structToMap(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("structToMap = %v, want %v", got, want)
+	}
+	if got := structToMapUncached(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("structToMapUncached = %v, want %v", got, want)
+	}
+}
+
+// сгенерированный ToMap строит те же ключи, что и structToMap: встроенный
+// User остаётся одним ключом, а не разворачивается.
+func TestGeneratedToMap(t *testing.T) {
+	user := User{ID: 1, Name: "Alice", Tags: []string{"go"}}
+	admin := &Admin{User: user, Level: 3, secret: "x"}
+	for _, v := range []interface{ ToMap() map[string]any }{&user, admin, &Signup{Login: "a"}} {
+		if got, want := v.ToMap(), structToMap(v); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T:\n got %v\nwant %v", v, got, want)
+		}
+	}
+	if _, ok := admin.ToMap()["User"]; !ok {
+		t.Errorf("Admin.ToMap has no User key: %v", admin.ToMap())
+	}
+}
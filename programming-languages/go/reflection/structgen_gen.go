@@ -0,0 +1,191 @@
+// Code generated by structgen; DO NOT EDIT.
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"reflection/validate"
+	"unicode/utf8"
+)
+
+// Validate проверяет User по тегам validate — так же, как validate.Struct.
+func (s *User) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: nil %T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, "User", "")
+	return w.result()
+}
+
+// ToMap возвращает поля User по ключам structinfo.ToMap.
+func (s *User) ToMap() map[string]any {
+	return map[string]any{
+		"id":       s.ID,
+		"name":     s.Name,
+		"email":    s.Email,
+		"tags":     s.Tags,
+		"metadata": s.Metadata,
+	}
+}
+
+// Validate проверяет Admin по тегам validate — так же, как validate.Struct.
+func (s *Admin) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: nil %T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, "Admin", "")
+	return w.result()
+}
+
+// ToMap возвращает поля Admin по ключам structinfo.ToMap.
+func (s *Admin) ToMap() map[string]any {
+	return map[string]any{
+		"User":  s.User,
+		"level": s.Level,
+	}
+}
+
+// Validate проверяет Signup по тегам validate — так же, как validate.Struct.
+func (s *Signup) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: nil %T", validate.ErrBadRule, s)
+	}
+	var w structgenWalker
+	s.structgenValidate(&w, "Signup", "")
+	return w.result()
+}
+
+// ToMap возвращает поля Signup по ключам structinfo.ToMap.
+func (s *Signup) ToMap() map[string]any {
+	return map[string]any{
+		"login":       s.Login,
+		"password":    s.Password,
+		"confirm":     s.Confirm,
+		"type":        s.Type,
+		"invite_code": s.InviteCode,
+		"phone":       s.Phone,
+		"email":       s.Email,
+	}
+}
+
+func (s *User) structgenValidate(w *structgenWalker, path, json string) {
+	if s.ID == 0 {
+		w.fail(structgenJoin(path, "ID"), structgenJoin(json, "id"), "required", "", s.ID)
+	}
+	if !(float64(s.ID) >= 1) {
+		w.fail(structgenJoin(path, "ID"), structgenJoin(json, "id"), "min", "1", s.ID)
+	}
+	if s.Name == "" {
+		w.fail(structgenJoin(path, "Name"), structgenJoin(json, "name"), "required", "", s.Name)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.Name))) <= 64) {
+		w.fail(structgenJoin(path, "Name"), structgenJoin(json, "name"), "max", "64", s.Name)
+	}
+	if !validate.IsEmail(string(s.Email)) {
+		w.fail(structgenJoin(path, "Email"), structgenJoin(json, "email"), "email", "", s.Email)
+	}
+	if !(float64(len(s.Tags)) <= 10) {
+		w.fail(structgenJoin(path, "Tags"), structgenJoin(json, "tags"), "max", "10", s.Tags)
+	}
+	for i1 := range s.Tags {
+		if s.Tags[i1] == "" {
+			w.fail(structgenIndex(structgenJoin(path, "Tags"), i1), structgenIndex(structgenJoin(json, "tags"), i1), "required", "", s.Tags[i1])
+		}
+		if !(float64(utf8.RuneCountInString(string(s.Tags[i1]))) <= 32) {
+			w.fail(structgenIndex(structgenJoin(path, "Tags"), i1), structgenIndex(structgenJoin(json, "tags"), i1), "max", "32", s.Tags[i1])
+		}
+	}
+	w.check(s, path, json)
+}
+
+func (s *Admin) structgenValidate(w *structgenWalker, path, json string) {
+	s.User.structgenValidate(w, structgenJoin(path, "User"), json)
+	if !(float64(s.Level) >= 1) {
+		w.fail(structgenJoin(path, "Level"), structgenJoin(json, "level"), "min", "1", s.Level)
+	}
+	if !(float64(s.Level) <= 10) {
+		w.fail(structgenJoin(path, "Level"), structgenJoin(json, "level"), "max", "10", s.Level)
+	}
+	w.check(s, path, json)
+}
+
+func (s *Signup) structgenValidate(w *structgenWalker, path, json string) {
+	if s.Login == "" {
+		w.fail(structgenJoin(path, "Login"), structgenJoin(json, "login"), "required", "", s.Login)
+	}
+	if s.Password == "" {
+		w.fail(structgenJoin(path, "Password"), structgenJoin(json, "password"), "required", "", s.Password)
+	}
+	if !(float64(utf8.RuneCountInString(string(s.Password))) >= 8) {
+		w.fail(structgenJoin(path, "Password"), structgenJoin(json, "password"), "min", "8", s.Password)
+	}
+	if !(cmp.Compare(string(s.Confirm), string(s.Password)) == 0) {
+		w.fail(structgenJoin(path, "Confirm"), structgenJoin(json, "confirm"), "eqfield", "Password", s.Confirm)
+	}
+	switch string(s.Type) {
+	case "user", "admin":
+	default:
+		w.fail(structgenJoin(path, "Type"), structgenJoin(json, "type"), "oneof", "user admin", s.Type)
+	}
+	if (string(s.Type) == "admin") && s.InviteCode == "" {
+		w.fail(structgenJoin(path, "InviteCode"), structgenJoin(json, "invite_code"), "required_if", "Type admin", s.InviteCode)
+	}
+	if (s.Phone == "") && s.Email == "" {
+		w.fail(structgenJoin(path, "Email"), structgenJoin(json, "email"), "required_without", "Phone", s.Email)
+	}
+	if !(s.Email == "") {
+		if !validate.IsEmail(string(s.Email)) {
+			w.fail(structgenJoin(path, "Email"), structgenJoin(json, "email"), "email", "", s.Email)
+		}
+	}
+	w.check(s, path, json)
+}
+
+// structgenWalker копит нарушения одной проверки.
+type structgenWalker struct {
+	errs validate.ValidationErrors
+	// seen — указатели на структуры на текущем пути: защита от циклов.
+	seen map[any]bool
+}
+
+func (w *structgenWalker) fail(path, json, rule, param string, value any) {
+	w.errs = append(w.errs, &validate.FieldError{Path: path, JSONPath: json, Rule: rule, Param: param, Value: value})
+}
+
+func (w *structgenWalker) enter(p any) bool {
+	if w.seen[p] {
+		return false
+	}
+	if w.seen == nil {
+		w.seen = make(map[any]bool)
+	}
+	w.seen[p] = true
+	return true
+}
+
+func (w *structgenWalker) leave(p any) { delete(w.seen, p) }
+
+func (w *structgenWalker) check(s any, path, json string) {
+	w.errs = append(w.errs, validate.CheckStruct(s, path, json)...)
+}
+
+func (w *structgenWalker) result() error {
+	if len(w.errs) == 0 {
+		return nil
+	}
+	return w.errs
+}
+
+func structgenJoin(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func structgenIndex(path string, key any) string {
+	return path + fmt.Sprintf("[%v]", key)
+}
@@ -0,0 +1,135 @@
+// Package structinfo — сведения о полях структуры, разобранные один раз
+// на тип: индексы, теги json, db и validate.
+//
+// reflect.Type.Field и StructTag.Get на каждом вызове разбирают одно и
+// то же; на горячем пути (обработка каждого запроса) это заметно. Of
+// делает эту работу при первой встрече с типом и дальше отдаёт готовое.
+package structinfo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Field — поле структуры.
+type Field struct {
+	// Index — индекс для reflect.Value.Field.
+	Index     int
+	Name      string
+	Type      reflect.Type
+	Exported  bool
+	Anonymous bool
+
+	// теги как есть.
+	JSONTag     string
+	DBTag       string
+	ValidateTag string
+
+	// JSON — имя в JSON: из тега, а без тега — имя Go. JSONNamed — имя
+	// задано тегом; JSONSkip — json:"-", поля в JSON нет.
+	JSON      string
+	JSONNamed bool
+	JSONSkip  bool
+	OmitEmpty bool
+
+	// DB — колонка из тега db; пусто, если тега нет.
+	DB string
+
+	// MapKey — ключ поля в ToMap: часть тега json до запятой как есть
+	// (даже "-" или пустая), без тега — имя Go. Это не правила
+	// encoding/json: так ключи исторически строит structToMap.
+	MapKey string
+}
+
+// Struct — поля типа в порядке объявления, включая неэкспортированные.
+type Struct struct {
+	Type   reflect.Type
+	Fields []Field
+}
+
+var cache sync.Map // reflect.Type -> *Struct
+
+// Of возвращает сведения о структуре t (указатели разыменовываются).
+// Результат общий для всех вызовов: менять его нельзя.
+func Of(t reflect.Type) *Struct {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if cached, ok := cache.Load(t); ok {
+		return cached.(*Struct)
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("structinfo: %s is not a struct", t))
+	}
+
+	s := &Struct{Type: t, Fields: make([]Field, t.NumField())}
+	for i := range s.Fields {
+		sf := t.Field(i)
+		f := ParseTags(sf.Name, sf.Tag)
+		f.Index = i
+		f.Type = sf.Type
+		f.Exported = sf.IsExported()
+		f.Anonymous = sf.Anonymous
+		s.Fields[i] = f
+	}
+
+	// при гонке двух первых вызовов побеждает один результат.
+	actual, _ := cache.LoadOrStore(t, s)
+	return actual.(*Struct)
+}
+
+// ParseTags заполняет поля Field, которые зависят только от имени и
+// тегов. Открыта для генераторов кода, читающих теги из исходников:
+// разбор должен совпадать с Of.
+func ParseTags(name string, tag reflect.StructTag) Field {
+	f := Field{
+		Name:        name,
+		JSONTag:     tag.Get("json"),
+		DBTag:       tag.Get("db"),
+		ValidateTag: tag.Get("validate"),
+		JSON:        name,
+		MapKey:      name,
+	}
+
+	jsonName, opts, _ := strings.Cut(f.JSONTag, ",")
+	switch {
+	case f.JSONTag == "-":
+		f.JSONSkip = true
+	case jsonName != "":
+		f.JSON, f.JSONNamed = jsonName, true
+	}
+	if f.JSONTag != "" {
+		f.MapKey = jsonName
+	}
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		f.OmitEmpty = f.OmitEmpty || opt == "omitempty"
+	}
+
+	f.DB, _, _ = strings.Cut(f.DBTag, ",")
+	return f
+}
+
+// ToMap собирает экспортированные поля структуры (или указателя на неё)
+// в map по Field.MapKey; при совпадении ключей побеждает последнее поле.
+// Значения не разворачиваются: вложенная и встроенная структуры попадают
+// в map целиком.
+func ToMap(v any) map[string]any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	info := Of(rv.Type())
+	result := make(map[string]any, len(info.Fields))
+
+	for _, f := range info.Fields {
+		if !f.Exported {
+			continue
+		}
+		result[f.MapKey] = rv.Field(f.Index).Interface()
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+
+	"reflection/structinfo"
+)
+
+// typeRules — всё, что проверке нужно знать о типе: какие поля обходить,
+// их имена и правила с уже найденными функциями. Собирается один раз на
+// тип, дальше обход не трогает ни теги, ни реестр.
+type typeRules struct {
+	fields []fieldRules
+	// check — проверка из RegisterStruct, если есть.
+	check StructFunc
+}
+
+type fieldRules struct {
+	index int
+	name  string
+	json  string
+	// flatten — встроенная структура без имени в JSON: её поля видны как
+	// поля внешней.
+	flatten bool
+	rules   []rule
+}
+
+// rulesOf берёт правила типа из кэша или собирает их. Сборка идёт под
+// RLock, а Register сбрасывает кэш под Lock: устаревшие правила в кэш
+// не попадут. Ошибки в тегах не кэшируются — они и так прерывают проверку.
+func (v *Validator) rulesOf(t reflect.Type) (*typeRules, error) {
+	if cached, ok := v.types.Load(t); ok {
+		return cached.(*typeRules), nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	info := structinfo.Of(t)
+	tr := &typeRules{check: v.structs[t]}
+	for _, f := range info.Fields {
+		// неэкспортированную встроенную структуру пропускать нельзя:
+		// её экспортированные поля подняты наверх и видны в JSON.
+		if !f.Exported && !(f.Anonymous && isStruct(f.Type)) {
+			continue
+		}
+		rules, err := parseTag(f.ValidateTag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrBadRule, t, f.Name, err)
+		}
+		for i := range rules {
+			r := &rules[i]
+			if r.name == tagDive || r.name == tagOmitEmpty {
+				continue
+			}
+			fn, ok := v.rules[r.name]
+			if !ok {
+				return nil, fmt.Errorf("%w %q on %s.%s", ErrUnknownRule, r.name, t, f.Name)
+			}
+			r.fn = fn
+		}
+		tr.fields = append(tr.fields, fieldRules{
+			index:   f.Index,
+			name:    f.Name,
+			json:    f.JSON,
+			flatten: f.Anonymous && !f.JSONNamed,
+			rules:   rules,
+		})
+	}
+
+	v.types.Store(t, tr)
+	return tr, nil
+}
+
+// isStruct — структура или указатель на неё.
+func isStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
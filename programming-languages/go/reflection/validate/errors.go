@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError — одно нарушение: какое поле, какое правило, какое значение.
+type FieldError struct {
+	// Path — путь из имён Go от корневого типа: Admin.User.Email, User.Tags[2].
+	Path string
+	// JSONPath — тот же путь в именах тега json, как поле видит клиент API:
+	// email, tags[2]. Встроенные структуры без тега json в нём не видны.
+	JSONPath string
+	// Rule и Param — нарушенное правило: "max" и "64" для max=64.
+	Rule  string
+	Param string
+	// Value — проверенное значение. Для nil‑указателя это nil своего
+	// типа, например (*int)(nil): fe.Value == nil ложно, проверять нужно
+	// reflect.ValueOf(fe.Value).IsNil().
+	Value any
+}
+
+// Error — краткая запись для логов; текст для людей даёт Messages.Format.
+func (fe *FieldError) Error() string {
+	if fe.Param != "" {
+		return fmt.Sprintf("%s: failed %s=%s", fe.Path, fe.Rule, fe.Param)
+	}
+	return fmt.Sprintf("%s: failed %s", fe.Path, fe.Rule)
+}
+
+// ValidationErrors — все нарушения одной проверки. Struct возвращает его
+// как error; достать обратно — errors.As:
+//
+//	var verrs validate.ValidationErrors
+//	if errors.As(err, &verrs) {
+//		for _, fe := range verrs { ... }
+//	}
+type ValidationErrors []*FieldError
+
+func (ve ValidationErrors) Error() string {
+	lines := make([]string, len(ve))
+	for i, fe := range ve {
+		lines[i] = fe.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap открывает отдельные нарушения для errors.As(err, &fieldErr).
+func (ve ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(ve))
+	for i, fe := range ve {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Format переводит все нарушения в сообщения по шаблонам m.
+func (ve ValidationErrors) Format(m Messages) []string {
+	out := make([]string, len(ve))
+	for i, fe := range ve {
+		out[i] = m.Format(fe)
+	}
+	return out
+}
+
+// Messages — шаблоны сообщений по имени правила. Подстановки:
+//
+//	{field}  JSON‑путь поля
+//	{path}   путь из имён Go
+//	{rule}   имя правила
+//	{param}  параметр правила
+//	{value}  проверенное значение
+//
+// Шаблон с ключом "" используется для правил без своего шаблона, например
+// для зарегистрированных через Register. Свой язык или свои тексты — это
+// своя Messages, например maps.Clone(English) с заменёнными ключами.
+type Messages map[string]string
+
+// Format подставляет данные нарушения в шаблон его правила.
+func (m Messages) Format(fe *FieldError) string {
+	tmpl, ok := m[fe.Rule]
+	if !ok {
+		tmpl, ok = m[""]
+	}
+	if !ok {
+		return fe.Error()
+	}
+	return strings.NewReplacer(
+		"{field}", fe.JSONPath,
+		"{path}", fe.Path,
+		"{rule}", fe.Rule,
+		"{param}", fe.Param,
+		"{value}", fmt.Sprint(fe.Value),
+	).Replace(tmpl)
+}
+
+// English — сообщения на английском.
+var English = Messages{
+	"":         "{field} failed the {rule} check",
+	"required": "{field} is required",
+	"min":      "{field} must be at least {param}",
+	"max":      "{field} must be at most {param}",
+	"len":      "{field} must have length {param}",
+	"oneof":    "{field} must be one of: {param}",
+	"email":    "{field} must be a valid email address",
+	"url":      "{field} must be an absolute URL",
+	"uuid":     "{field} must be a UUID",
+	"regexp":   "{field} has an invalid format",
+
+	"eqfield":          "{field} must match {param}",
+	"nefield":          "{field} must differ from {param}",
+	"gtfield":          "{field} must be greater than {param}",
+	"gtefield":         "{field} must not be less than {param}",
+	"ltfield":          "{field} must be less than {param}",
+	"ltefield":         "{field} must not be greater than {param}",
+	"required_if":      "{field} is required when {param}",
+	"required_unless":  "{field} is required unless {param}",
+	"required_with":    "{field} is required together with {param}",
+	"required_without": "{field} is required when {param} is empty",
+}
+
+// Russian — сообщения на русском.
+var Russian = Messages{
+	"":         "поле {field} не прошло проверку {rule}",
+	"required": "поле {field} обязательно",
+	"min":      "поле {field}: не меньше {param}",
+	"max":      "поле {field}: не больше {param}",
+	"len":      "поле {field}: длина должна быть {param}",
+	"oneof":    "поле {field}: допустимые значения — {param}",
+	"email":    "поле {field}: некорректный email",
+	"url":      "поле {field}: нужен абсолютный URL",
+	"uuid":     "поле {field}: нужен UUID",
+	"regexp":   "поле {field}: неверный формат",
+
+	"eqfield":          "поле {field} должно совпадать с {param}",
+	"nefield":          "поле {field} должно отличаться от {param}",
+	"gtfield":          "поле {field} должно быть больше {param}",
+	"gtefield":         "поле {field} должно быть не меньше {param}",
+	"ltfield":          "поле {field} должно быть меньше {param}",
+	"ltefield":         "поле {field} должно быть не больше {param}",
+	"required_if":      "поле {field} обязательно, если {param}",
+	"required_unless":  "поле {field} обязательно, кроме случая {param}",
+	"required_with":    "поле {field} обязательно вместе с {param}",
+	"required_without": "поле {field} обязательно, если не заполнено {param}",
+}
@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+type contact struct {
+	Phone string `json:"phone" validate:"required"`
+}
+
+type account struct {
+	contact                   // без тега json: поля подняты наверх
+	Name    string            `json:"name,omitempty" validate:"max=3"`
+	Nick    string            `validate:"required"` // без тега — имя Go
+	Hidden  string            `json:"-" validate:"required"`
+	Backup  contact           `json:"backup"`
+	Tags    []string          `json:"tags" validate:"dive,max=2"`
+	Labels  map[string]string `json:"labels" validate:"dive,required"`
+	Age     *int              `json:"age" validate:"required"`
+}
+
+func TestFieldErrors(t *testing.T) {
+	age := 7
+	err := New().Struct(account{
+		contact: contact{Phone: "1"},
+		Name:    "Alice",
+		Hidden:  "x",
+		Backup:  contact{},
+		Tags:    []string{"ok", "long"},
+		Labels:  map[string]string{"env": ""},
+		Age:     nil,
+	})
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v", err)
+	}
+	want := []FieldError{
+		{Path: "account.Name", JSONPath: "name", Rule: "max", Param: "3", Value: "Alice"},
+		{Path: "account.Nick", JSONPath: "Nick", Rule: "required", Value: ""},
+		{Path: "account.Backup.Phone", JSONPath: "backup.phone", Rule: "required", Value: ""},
+		{Path: "account.Tags[1]", JSONPath: "tags[1]", Rule: "max", Param: "2", Value: "long"},
+		{Path: "account.Labels[env]", JSONPath: "labels[env]", Rule: "required", Value: ""},
+		{Path: "account.Age", JSONPath: "age", Rule: "required", Value: (*int)(nil)},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(errs), len(want), errs)
+	}
+	for i := range want {
+		if *errs[i] != want[i] {
+			t.Errorf("error %d = %+v, want %+v", i, *errs[i], want[i])
+		}
+	}
+
+	// встроенная структура видна в пути Go, но не в JSON.
+	got := failures(t, New().Struct(account{Nick: "n", Hidden: "x", Backup: contact{Phone: "1"}, Age: &age}))
+	if want := []string{"account.contact.Phone required"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var fe *FieldError
+	if err := New().Struct(account{Nick: "n", Hidden: "x", Backup: contact{Phone: "1"}, Age: &age}); !errors.As(err, &fe) || fe.JSONPath != "phone" {
+		t.Errorf("embedded JSONPath: %+v", fe)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	errs := ValidationErrors{
+		{Path: "User.Name", JSONPath: "name", Rule: "required"},
+		{Path: "User.Tags", JSONPath: "tags", Rule: "max", Param: "10", Value: 11},
+	}
+	if got, want := errs.Error(), "User.Name: failed required\nUser.Tags: failed max=10"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	// отдельное нарушение достаётся и через обёртку.
+	var fe *FieldError
+	if !errors.As(fmt.Errorf("signup: %w", error(errs)), &fe) || fe != errs[0] {
+		t.Errorf("errors.As(*FieldError) = %v", fe)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	errs := ValidationErrors{
+		{Path: "User.Name", JSONPath: "name", Rule: "required"},
+		{Path: "User.Tags", JSONPath: "tags", Rule: "max", Param: "10", Value: 11},
+		{Path: "User.Code", JSONPath: "code", Rule: "even", Value: 3},
+	}
+	tests := []struct {
+		name string
+		m    Messages
+		want []string
+	}{
+		{"english", English, []string{
+			"name is required",
+			"tags must be at most 10",
+			"code failed the even check",
+		}},
+		{"russian", Russian, []string{
+			"поле name обязательно",
+			"поле tags: не больше 10",
+			"поле code не прошло проверку even",
+		}},
+		{"all placeholders", Messages{"": "{path} ({field}): {rule} {param} got {value}"}, []string{
+			"User.Name (name): required  got <nil>",
+			"User.Tags (tags): max 10 got 11",
+			"User.Code (code): even  got 3",
+		}},
+		{"no template", Messages{}, []string{
+			"User.Name: failed required",
+			"User.Tags: failed max=10",
+			"User.Code: failed even",
+		}},
+	}
+	for _, tt := range tests {
+		if got := errs.Format(tt.m); !slices.Equal(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	// у каждого встроенного правила есть свой шаблон на обоих языках.
+	for name := range builtins {
+		for lang, m := range map[string]Messages{"English": English, "Russian": Russian} {
+			if _, ok := m[name]; !ok {
+				t.Errorf("%s has no template for %q", lang, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package validate
+
+import (
+	"cmp"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// builtins — встроенные правила:
+//
+//	required      значение не нулевое; у срезов и map есть элементы
+//	min=N max=N   для чисел — значение, для строк — длина в символах,
+//	len=N         для срезов, массивов и map — число элементов
+//	oneof=a b c   значение из списка (строки и целые числа)
+//	email         адрес вида user@example.com, без отображаемого имени
+//	url           абсолютный URL со схемой и хостом
+//	uuid          UUID в канонической записи 8-4-4-4-12
+//	regexp=RE     строка целиком соответствует RE; запятая — 0x2C
+//
+// Правила, которые ссылаются на другие поля (имя или путь через точку,
+// см. Field.Lookup):
+//
+//	eqfield=F nefield=F            равно / не равно полю F
+//	gtfield=F gtefield=F           больше / не меньше поля F
+//	ltfield=F ltefield=F           меньше / не больше поля F
+//	required_if=F v [F2 v2 ...]    обязательно, если все F равны своим v
+//	required_unless=F v [...]      обязательно, если хоть одно F не равно v
+//	required_with=F [F2 ...]       обязательно, если заполнено хоть одно F
+//	required_without=F [F2 ...]    обязательно, если пусто хоть одно F
+//
+// Сравнивать можно числа, строки и time.Time. Если поле для сравнения —
+// nil‑указатель, сравнивать не с чем и правило считается выполненным.
+var builtins = map[string]RuleFunc{
+	"required": required,
+	"min":      compareRule(func(got, want float64) bool { return got >= want }),
+	"max":      compareRule(func(got, want float64) bool { return got <= want }),
+	"len":      compareRule(func(got, want float64) bool { return got == want }),
+	"oneof":    oneOf,
+	"email":    stringRule(IsEmail),
+	"url":      stringRule(IsURL),
+	"uuid":     stringRule(IsUUID),
+	"regexp":   matchRegexp,
+
+	"eqfield":  fieldRule(func(c int) bool { return c == 0 }),
+	"nefield":  fieldRule(func(c int) bool { return c != 0 }),
+	"gtfield":  fieldRule(func(c int) bool { return c > 0 }),
+	"gtefield": fieldRule(func(c int) bool { return c >= 0 }),
+	"ltfield":  fieldRule(func(c int) bool { return c < 0 }),
+	"ltefield": fieldRule(func(c int) bool { return c <= 0 }),
+
+	"required_if":      requiredIf(true),
+	"required_unless":  requiredIf(false),
+	"required_with":    requiredWith(true),
+	"required_without": requiredWith(false),
+}
+
+var uuidRE = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func required(f Field) (bool, error) {
+	return !isEmpty(f.Value), nil
+}
+
+// compareRule сравнивает «размер» значения с параметром: само число
+// или длину строки и коллекции.
+func compareRule(ok func(got, want float64) bool) RuleFunc {
+	return func(f Field) (bool, error) {
+		want, err := strconv.ParseFloat(f.Param, 64)
+		if err != nil {
+			return false, fmt.Errorf("%w: parameter %q is not a number", ErrBadRule, f.Param)
+		}
+		got, err := size(f.Value)
+		if err != nil {
+			return false, err
+		}
+		return ok(got, want), nil
+	}
+}
+
+// size — то, с чем сравнивают min, max и len.
+func size(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), nil
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return float64(v.Len()), nil
+	}
+	return 0, fmt.Errorf("%w: cannot measure %s", ErrBadRule, v.Type())
+}
+
+func oneOf(f Field) (bool, error) {
+	options := strings.Fields(f.Param)
+	if len(options) == 0 {
+		return false, fmt.Errorf("%w: oneof without options", ErrBadRule)
+	}
+
+	got, ok := scalarString(f.Value)
+	if !ok {
+		return false, fmt.Errorf("%w: oneof on %s", ErrBadRule, f.Value.Type())
+	}
+	for _, opt := range options {
+		if got == opt {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scalarString — значение строки, целого числа или bool в виде текста тега.
+func scalarString(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	}
+	return "", false
+}
+
+// stringRule применяет проверку к строке; к другим типам правило неприменимо.
+func stringRule(check func(s string) bool) RuleFunc {
+	return func(f Field) (bool, error) {
+		if f.Value.Kind() != reflect.String {
+			return false, fmt.Errorf("%w: string rule on %s", ErrBadRule, f.Value.Type())
+		}
+		return check(f.Value.String()), nil
+	}
+}
+
+// IsEmail, IsURL и IsUUID — проверки правил email, url и uuid; открыты
+// для сгенерированного кода.
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	// ParseAddress принимает и "Alice <a@example.com>": нам нужен голый адрес.
+	return err == nil && addr.Address == s
+}
+
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func IsUUID(s string) bool { return uuidRE.MatchString(s) }
+
+// regexps — скомпилированные выражения правила regexp по параметру:
+// компиляция дороже самой проверки.
+var regexps sync.Map // string -> *regexp.Regexp
+
+func matchRegexp(f Field) (bool, error) {
+	re, ok := regexps.Load(f.Param)
+	if !ok {
+		// якоря снаружи группы: "a|ab" должно целиком совпасть с "ab".
+		compiled, err := regexp.Compile(`^(?:` + f.Param + `)$`)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrBadRule, err)
+		}
+		re, _ = regexps.LoadOrStore(f.Param, compiled)
+	}
+	return stringRule(re.(*regexp.Regexp).MatchString)(f)
+}
+
+// lookup достаёт поле, на которое ссылается правило.
+func lookup(f Field, name string) (reflect.Value, error) {
+	v, ok := f.Lookup(name)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%w: no field %q", ErrBadRule, name)
+	}
+	return v, nil
+}
+
+// fieldRule сравнивает значение с другим полем.
+func fieldRule(ok func(c int) bool) RuleFunc {
+	return func(f Field) (bool, error) {
+		other, err := lookup(f, f.Param)
+		if err != nil {
+			return false, err
+		}
+		other = indirect(other)
+		if isNilPointer(other) {
+			return true, nil
+		}
+		c, err := compareValues(f.Value, other)
+		if err != nil {
+			return false, err
+		}
+		return ok(c), nil
+	}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// compareValues сравнивает два значения: -1, 0 или 1.
+func compareValues(a, b reflect.Value) (int, error) {
+	if a.Type() == timeType && b.Type() == timeType {
+		// Compare, а не ==: у одного момента бывают разные Location.
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), nil
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return cmp.Compare(x, y), nil
+		}
+	}
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return cmp.Compare(a.String(), b.String()), nil
+	}
+	return 0, fmt.Errorf("%w: cannot compare %s with %s", ErrBadRule, a.Type(), b.Type())
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// requiredIf — required_if (match=true) и required_unless (match=false):
+// параметр — пары «поле значение».
+func requiredIf(match bool) RuleFunc {
+	return func(f Field) (bool, error) {
+		pairs := strings.Fields(f.Param)
+		if len(pairs) == 0 || len(pairs)%2 != 0 {
+			return false, fmt.Errorf("%w: want field value pairs, got %q", ErrBadRule, f.Param)
+		}
+		all := true
+		for i := 0; i < len(pairs); i += 2 {
+			other, err := lookup(f, pairs[i])
+			if err != nil {
+				return false, err
+			}
+			got, ok := scalarString(indirect(other))
+			all = all && ok && got == pairs[i+1]
+		}
+		if all != match {
+			return true, nil
+		}
+		return !isEmpty(f.Value), nil
+	}
+}
+
+// requiredWith — required_with (present=true) и required_without
+// (present=false): параметр — список полей.
+func requiredWith(present bool) RuleFunc {
+	return func(f Field) (bool, error) {
+		names := strings.Fields(f.Param)
+		if len(names) == 0 {
+			return false, fmt.Errorf("%w: no fields", ErrBadRule)
+		}
+		for _, name := range names {
+			other, err := lookup(f, name)
+			if err != nil {
+				return false, err
+			}
+			if isEmpty(other) != present {
+				return !isEmpty(f.Value), nil
+			}
+		}
+		return true, nil
+	}
+}
+
+// indirect разыменовывает не‑nil указатели и интерфейсы.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,222 @@
+package validate
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type trip struct {
+	StartAt time.Time
+	EndAt   time.Time `validate:"gtfield=StartAt"`
+}
+
+type leg struct {
+	// StartAt ищется во внешних структурах, Trip.EndAt — путём от них.
+	From time.Time `validate:"gtefield=StartAt"`
+	To   time.Time `validate:"gtfield=From,ltefield=Trip.EndAt"`
+}
+
+type booking struct {
+	Trip     trip
+	Legs     []leg `validate:"dive"`
+	StartAt  *time.Time
+	Password string `validate:"min=3"`
+	Confirm  string `validate:"eqfield=Password"`
+	Old      string `validate:"nefield=Password"`
+	Min      int
+	Max      *uint
+	Seats    int8 `validate:"gtefield=Min,ltfield=Max"`
+}
+
+func TestFieldRules(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	start := now.Add(-time.Hour)
+	max := uint(4)
+	valid := func() *booking {
+		return &booking{
+			Trip:     trip{StartAt: now, EndAt: now.Add(48 * time.Hour)},
+			Legs:     []leg{{From: now.Add(time.Hour), To: now.Add(2 * time.Hour)}},
+			StartAt:  &start,
+			Password: "secret",
+			Confirm:  "secret",
+			Old:      "old",
+			Min:      1,
+			Max:      &max,
+			Seats:    2,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(b *booking)
+		want   []string
+	}{
+		{"valid", func(b *booking) {}, nil},
+		{"eqfield", func(b *booking) { b.Confirm = "secreT" }, []string{"booking.Confirm eqfield"}},
+		{"nefield", func(b *booking) { b.Old = "secret" }, []string{"booking.Old nefield"}},
+		{"time in a nested struct", func(b *booking) { b.Trip.EndAt = now.Add(2 * time.Hour) }, nil},
+		{"time in a nested struct fails", func(b *booking) { b.Trip.EndAt = now }, []string{
+			"booking.Trip.EndAt gtfield",
+			"booking.Legs[0].To ltefield",
+		}},
+		// другой часовой пояс — тот же момент.
+		{"time zones", func(b *booking) {
+			b.Legs[0].To = b.Legs[0].From.In(time.FixedZone("MSK", 3*3600))
+		}, []string{"booking.Legs[0].To gtfield"}},
+		{"field of an outer struct", func(b *booking) { b.Legs[0].From = start.Add(-time.Minute) }, []string{
+			"booking.Legs[0].From gtefield",
+		}},
+		{"nil pointer to compare with", func(b *booking) { b.StartAt, b.Max = nil, nil; b.Seats = 100 }, nil},
+		{"dotted path", func(b *booking) { b.Legs[0].To = now.Add(49 * time.Hour) }, []string{"booking.Legs[0].To ltefield"}},
+		{"numbers of different types", func(b *booking) { b.Seats = 4 }, []string{"booking.Seats ltfield"}},
+		{"gtefield on equal numbers", func(b *booking) { b.Seats = 1 }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := valid()
+			tt.mutate(b)
+			if got := failures(t, New().Struct(b)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type signup struct {
+	Type   string
+	Active *bool
+	Code   string `validate:"required_if=Type admin Active true"`
+	Reason string `validate:"required_unless=Type user"`
+	Phone  string
+	Email  string
+	Fax    string `validate:"required_with=Phone Email"`
+	Mail   string `validate:"required_without=Phone Email"`
+}
+
+func TestRequiredRules(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		in   signup
+		want []string
+	}{
+		{"nothing required", signup{Type: "user", Phone: "1", Email: "e", Fax: "f"}, nil},
+		{"required_if: all pairs match", signup{Type: "admin", Active: &yes, Reason: "r", Phone: "1", Email: "e", Fax: "f"}, []string{
+			"signup.Code required_if",
+		}},
+		{"required_if: one pair differs", signup{Type: "admin", Active: &no, Reason: "r", Phone: "1", Email: "e", Fax: "f"}, nil},
+		{"required_if: nil pointer never matches", signup{Type: "admin", Reason: "r", Phone: "1", Email: "e", Fax: "f"}, nil},
+		{"required_unless", signup{Type: "guest", Phone: "1", Email: "e", Fax: "f"}, []string{
+			"signup.Reason required_unless",
+		}},
+		{"required_with: any field set", signup{Type: "user", Email: "e"}, []string{
+			"signup.Fax required_with",
+			"signup.Mail required_without",
+		}},
+		{"required_without: any field empty", signup{Type: "user", Phone: "1", Fax: "f", Mail: "m"}, nil},
+		{"required_without: all fields empty", signup{Type: "user"}, []string{"signup.Mail required_without"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failures(t, New().Struct(tt.in)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+	}{
+		{"no such field", struct {
+			A int `validate:"eqfield=B"`
+		}{}},
+		{"unexported field", struct {
+			A int `validate:"eqfield=b"`
+			b int
+		}{}},
+		{"incomparable types", struct {
+			A int `validate:"eqfield=B"`
+			B string
+		}{}},
+		{"odd pairs", struct {
+			A string `validate:"required_if=B"`
+			B string
+		}{}},
+		{"no fields", struct {
+			A string `validate:"required_with="`
+		}{}},
+	}
+	for _, tt := range tests {
+		if err := New().Struct(tt.in); !errors.Is(err, ErrBadRule) {
+			t.Errorf("%s: err = %v, want ErrBadRule", tt.name, err)
+		}
+	}
+}
+
+type period struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type schedule struct {
+	period
+	Name  string `json:"name"`
+	Shift period `json:"shift"`
+}
+
+func TestRegisterStruct(t *testing.T) {
+	v := New()
+	v.RegisterStruct(period{}, func(sl StructLevel) {
+		p := sl.Value.Interface().(period)
+		if p.To < p.From {
+			sl.Report("To", "after_from", "From")
+		}
+	})
+	v.RegisterStruct(&schedule{}, func(sl StructLevel) {
+		s := sl.Value.Interface().(schedule)
+		if s.Name == "" {
+			// нарушение структуры целиком.
+			sl.Report("", "named", "")
+		}
+		if s.From == s.Shift.From {
+			// поле поднято из period: JSON‑имя берётся оттуда.
+			sl.Report("From", "overlap", "")
+		}
+	})
+
+	// проверка типа работает и во вложенных структурах.
+	err := v.Struct(schedule{period: period{From: 2, To: 1}, Shift: period{From: 2, To: 1}})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v", err)
+	}
+	var got []string
+	for _, fe := range errs {
+		got = append(got, strings.Join([]string{fe.Path, fe.JSONPath, fe.Rule}, "|"))
+	}
+	want := []string{
+		"schedule.period.To|to|after_from",
+		"schedule.Shift.To|shift.to|after_from",
+		"schedule||named",
+		"schedule.From|from|overlap",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if errs[0].Value != 1 {
+		t.Errorf("reported value = %v, want 1", errs[0].Value)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterStruct on a non-struct did not panic")
+		}
+	}()
+	v.RegisterStruct(42, func(StructLevel) {})
+}
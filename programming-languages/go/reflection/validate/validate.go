@@ -0,0 +1,486 @@
+// Package validate — валидация структур по тегу validate.
+//
+//	type SignupRequest struct {
+//		Name    string            `validate:"required,min=2,max=64"`
+//		Email   string            `validate:"required,email"`
+//		Role    string            `validate:"oneof=user admin"`
+//		Site    string            `validate:"omitempty,url"`
+//		Tags    []string          `validate:"max=10,dive,required,max=32"`
+//		Address *Address          `validate:"required"` // проверяется и сам Address
+//		Labels  map[string]string `validate:"dive,max=64"`
+//	}
+//
+// Правила перечисляются через запятую и применяются по порядку. Вложенные
+// и встроенные структуры (в том числе по указателю) проверяются всегда,
+// элементы срезов, массивов и map — только после dive: правила до dive
+// относятся к самому контейнеру, после — к каждому элементу.
+//
+// Правила вроде eqfield=Password и required_if=Type admin ссылаются на
+// соседние поля и поля внешних структур; то, что тегами не выразить,
+// проверяет функция RegisterStruct для всего типа.
+//
+// Нарушения Struct возвращает как ValidationErrors: у каждого есть путь
+// поля, имя из тега json, правило и значение, а тексты для людей дают
+// шаблоны Messages (English, Russian).
+//
+// Неизвестное правило — ошибка программиста, а не данных: Struct вернёт
+// её (errors.Is(err, ErrUnknownRule)) вместо результата проверки.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"reflection/structinfo"
+)
+
+// ErrUnknownRule — в теге правило, которого нет в реестре.
+var ErrUnknownRule = errors.New("validate: unknown rule")
+
+// ErrBadRule — правило неприменимо к полю: не тот тип или параметр.
+var ErrBadRule = errors.New("validate: bad rule")
+
+// Field — проверяемое значение, которое получает правило.
+type Field struct {
+	// Value — значение поля (элемента после dive); указатели уже
+	// разыменованы, если не nil.
+	Value reflect.Value
+	// Param — всё после "=" в правиле: "3" для min=3.
+	Param string
+	// Path — путь до значения: Address.City, Tags[2], Labels[env].
+	Path string
+	// Parent — структура, в которой лежит поле.
+	Parent reflect.Value
+
+	// scope — Parent и все внешние структуры, от корня к Parent.
+	scope []reflect.Value
+}
+
+// Lookup находит поле по имени или пути через точку ("Password",
+// "Booking.StartAt"): сначала в Parent, затем во внешних структурах —
+// как имя во вложенных областях видимости. Промежуточные указатели
+// разыменовываются, последний возвращается как есть.
+func (f Field) Lookup(path string) (reflect.Value, bool) {
+	for i := len(f.scope) - 1; i >= 0; i-- {
+		if v, ok := fieldByPath(f.scope[i], path); ok {
+			return v, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func fieldByPath(rv reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		// неэкспортированные поля правилам не видны, как и при обходе.
+		sf, ok := rv.Type().FieldByName(name)
+		if !ok || !sf.IsExported() {
+			return reflect.Value{}, false
+		}
+		var err error
+		if rv, err = rv.FieldByIndexErr(sf.Index); err != nil {
+			// поле встроенной структуры за nil‑указателем.
+			return reflect.Value{}, false
+		}
+	}
+	return rv, true
+}
+
+// RuleFunc проверяет значение. ok=false — данные не прошли проверку;
+// err — правило к полю неприменимо (и это ошибка в теге, а не в данных).
+type RuleFunc func(f Field) (ok bool, err error)
+
+// StructFunc — проверка структуры целиком, для правил, которые не
+// выразить тегами одного поля. Нарушения сообщаются через sl.Report.
+type StructFunc func(sl StructLevel)
+
+// StructLevel — структура, которую проверяет StructFunc.
+type StructLevel struct {
+	// Value — сама структура (не указатель).
+	Value reflect.Value
+
+	w   *walker
+	loc location
+}
+
+// Report добавляет нарушение правила name у поля field (имя Go);
+// пустой field — нарушение относится к структуре целиком.
+func (sl StructLevel) Report(field, name, param string) {
+	loc, rv := sl.loc, reflect.Value{}
+	if sf, ok := sl.Value.Type().FieldByName(field); ok {
+		// поле может быть поднято из встроенной структуры: имя JSON
+		// берём у типа, где оно объявлено.
+		owner := sl.Value.Type()
+		for _, i := range sf.Index[:len(sf.Index)-1] {
+			owner = owner.Field(i).Type
+			for owner.Kind() == reflect.Pointer {
+				owner = owner.Elem()
+			}
+		}
+		info := structinfo.Of(owner).Fields[sf.Index[len(sf.Index)-1]]
+		loc = loc.field(field, info.JSON)
+		rv, _ = sl.Value.FieldByIndexErr(sf.Index)
+	}
+	sl.w.fail(rv, loc, rule{name: name, param: param})
+}
+
+// служебные слова тега, которые не являются правилами.
+const (
+	tagDive      = "dive"
+	tagOmitEmpty = "omitempty"
+)
+
+// Validator хранит реестр правил. Нулевое значение непригодно, нужен New.
+type Validator struct {
+	mu      sync.RWMutex
+	rules   map[string]RuleFunc
+	structs map[reflect.Type]StructFunc
+	// types — разобранные правила по типам (см. rulesOf).
+	types sync.Map // reflect.Type -> *typeRules
+}
+
+// New создаёт Validator со встроенными правилами.
+func New() *Validator {
+	v := &Validator{
+		rules:   make(map[string]RuleFunc, len(builtins)),
+		structs: make(map[reflect.Type]StructFunc),
+	}
+	for name, fn := range builtins {
+		v.rules[name] = fn
+	}
+	return v
+}
+
+// Register добавляет правило или подменяет встроенное. Разобранные
+// правила типов при этом сбрасываются, так что регистрировать лучше
+// до первых проверок.
+func (v *Validator) Register(name string, fn RuleFunc) {
+	if name == "" || name == tagDive || name == tagOmitEmpty || strings.ContainsAny(name, ",=") {
+		panic(fmt.Sprintf("validate: invalid rule name %q", name))
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.rules[name] = fn
+	v.types.Clear()
+}
+
+// RegisterStruct задаёт проверку для типа sample (структуры или указателя
+// на неё). Она запускается после правил полей везде, где встречается
+// значение этого типа, в том числе во вложенных структурах.
+func (v *Validator) RegisterStruct(sample any, fn StructFunc) {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: RegisterStruct on %T, want a struct", sample))
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.structs[t] = fn
+	v.types.Clear()
+}
+
+// std — валидатор для функций пакета.
+var std = New()
+
+// Register добавляет правило в валидатор по умолчанию.
+func Register(name string, fn RuleFunc) { std.Register(name, fn) }
+
+// RegisterStruct задаёт проверку типа в валидаторе по умолчанию.
+func RegisterStruct(sample any, fn StructFunc) { std.RegisterStruct(sample, fn) }
+
+// Struct проверяет структуру валидатором по умолчанию.
+func Struct(s any) error { return std.Struct(s) }
+
+// CheckStruct запускает проверку из RegisterStruct валидатора по
+// умолчанию для s — указателя на структуру, лежащую по path и jsonPath.
+// Нужна коду от structgen: правила полей он проверяет сам, а проверки
+// типов есть только в реестре.
+func CheckStruct(s any, path, jsonPath string) ValidationErrors {
+	rv := reflect.ValueOf(s).Elem()
+
+	std.mu.RLock()
+	fn := std.structs[rv.Type()]
+	std.mu.RUnlock()
+	if fn == nil {
+		return nil
+	}
+
+	w := &walker{v: std}
+	fn(StructLevel{Value: rv, w: w, loc: location{path: path, json: jsonPath}})
+	return w.failed
+}
+
+// Struct проверяет структуру (или указатель на неё). Нарушения
+// возвращаются вместе, как ValidationErrors; ошибка в самих тегах
+// прерывает проверку и возвращается как есть.
+func (v *Validator) Struct(s any) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return fmt.Errorf("%w: nil %T", ErrBadRule, s)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is not a struct", ErrBadRule, s)
+	}
+	if !rv.CanAddr() {
+		// адресуемая копия — ради interfaceable.
+		cp := reflect.New(rv.Type()).Elem()
+		cp.Set(rv)
+		rv = cp
+	}
+
+	w := &walker{v: v, seen: make(map[visit]bool)}
+	if err := w.structFields(rv, location{path: rv.Type().Name()}); err != nil {
+		return err
+	}
+	if len(w.failed) == 0 {
+		// не nil‑срез в интерфейсе: иначе err != nil при пустом результате.
+		return nil
+	}
+	return w.failed
+}
+
+// location — где лежит значение: путь из имён Go (с типом корня) и путь
+// из имён JSON, каким его видит клиент API.
+type location struct {
+	path string
+	json string
+}
+
+func (l location) field(name, jsonName string) location {
+	return location{path: join(l.path, name), json: join(l.json, jsonName)}
+}
+
+func (l location) index(key any) location {
+	k := fmt.Sprintf("[%v]", key)
+	return location{path: l.path + k, json: l.json + k}
+}
+
+// visit — указатель на пути обхода. Одного адреса мало: у структуры и
+// её первого поля адрес общий, а проверять нужно оба.
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// walker обходит одно значение и копит нарушения.
+type walker struct {
+	v      *Validator
+	failed ValidationErrors
+	// seen — указатели на текущем пути: защита от циклов.
+	seen map[visit]bool
+	// scope — структуры на текущем пути, от корня к ближайшей.
+	scope []reflect.Value
+}
+
+func (w *walker) structFields(rv reflect.Value, loc location) error {
+	tr, err := w.v.rulesOf(rv.Type())
+	if err != nil {
+		return err
+	}
+	w.scope = append(w.scope, rv)
+	defer func() { w.scope = w.scope[:len(w.scope)-1] }()
+
+	for _, f := range tr.fields {
+		fieldLoc := loc.field(f.name, f.json)
+		if f.flatten {
+			// encoding/json поднимает поля встроенной структуры наверх.
+			fieldLoc.json = loc.json
+		}
+		if err := w.value(rv.Field(f.index), fieldLoc, f.rules); err != nil {
+			return err
+		}
+	}
+
+	if tr.check != nil {
+		tr.check(StructLevel{Value: interfaceable(rv), w: w, loc: loc})
+	}
+	return nil
+}
+
+// value применяет правила к значению и спускается внутрь него.
+func (w *walker) value(rv reflect.Value, loc location, rules []rule) error {
+	// указатели и интерфейсы разыменовываем; nil проверяет только required.
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			break
+		}
+		if rv.Kind() == reflect.Pointer {
+			key := visit{rv.Type(), rv.Pointer()}
+			if w.seen[key] {
+				return nil
+			}
+			w.seen[key] = true
+			defer delete(w.seen, key)
+		}
+		rv = rv.Elem()
+	}
+
+	for i, r := range rules {
+		switch r.name {
+		case tagOmitEmpty:
+			if isEmpty(rv) {
+				return nil
+			}
+			continue
+		case tagDive:
+			return w.dive(rv, loc, rules[i+1:])
+		}
+
+		if (!rv.IsValid() || isNilPointer(rv)) && !strings.HasPrefix(r.name, "required") {
+			// у nil проверяются только required и required_*.
+			continue
+		}
+
+		passed, err := r.fn(Field{
+			Value:  rv,
+			Param:  r.param,
+			Path:   loc.path,
+			Parent: w.scope[len(w.scope)-1],
+			scope:  w.scope[:len(w.scope):len(w.scope)],
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", loc.path, err)
+		}
+		if !passed {
+			w.fail(rv, loc, r)
+		}
+	}
+
+	if rv.Kind() == reflect.Struct {
+		return w.structFields(rv, loc)
+	}
+	return nil
+}
+
+// dive применяет оставшиеся правила к каждому элементу.
+func (w *walker) dive(rv reflect.Value, loc location, rules []rule) error {
+	if !rv.IsValid() || isNilPointer(rv) {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := w.value(rv.Index(i), loc.index(i), rules); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := w.value(iter.Value(), loc.index(iter.Key()), rules); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: dive on %s (%s)", ErrBadRule, loc.path, rv.Kind())
+	}
+	return nil
+}
+
+func (w *walker) fail(rv reflect.Value, loc location, r rule) {
+	fe := &FieldError{Path: loc.path, JSONPath: loc.json, Rule: r.name, Param: r.param}
+	if rv.IsValid() && rv.CanInterface() {
+		fe.Value = rv.Interface()
+	}
+	w.failed = append(w.failed, fe)
+}
+
+// rule — одно правило из тега.
+type rule struct {
+	name  string
+	param string
+	// fn — функция правила из реестра; у dive и omitempty её нет.
+	fn RuleFunc
+}
+
+// Rule — правило из тега: Name и Param ("min" и "3" для min=3).
+type Rule struct {
+	Name  string
+	Param string
+}
+
+// ParseTag разбирает "required,min=3,oneof=a b". Запятую внутри
+// параметра (например, в regexp) записывают как 0x2C. Экспортирован для
+// генераторов кода: тег должен читаться так же, как при проверке.
+func ParseTag(tag string) ([]Rule, error) {
+	if tag == "" || tag == "-" {
+		return nil, nil
+	}
+	var rules []Rule
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			return nil, fmt.Errorf("empty rule in %q", tag)
+		}
+		rules = append(rules, Rule{Name: name, Param: strings.ReplaceAll(param, "0x2C", ",")})
+	}
+	return rules, nil
+}
+
+func parseTag(tag string) ([]rule, error) {
+	parsed, err := ParseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]rule, len(parsed))
+	for i, r := range parsed {
+		rules[i] = rule{name: r.Name, param: r.Param}
+	}
+	return rules, nil
+}
+
+// interfaceable снимает запрет на Interface, который reflect ставит на
+// значение неэкспортированного поля. Обход заходит только в
+// неэкспортированные встроенные структуры — их поля encoding/json
+// поднимает наверх, — и проверка из RegisterStruct должна получить такое
+// значение, как любое другое. Неадресуемое значение (структура в map)
+// остаётся как есть.
+func interfaceable(rv reflect.Value) reflect.Value {
+	if rv.CanInterface() || !rv.CanAddr() {
+		return rv
+	}
+	return reflect.NewAt(rv.Type(), unsafe.Pointer(rv.UnsafeAddr())).Elem()
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func isNilPointer(rv reflect.Value) bool {
+	return (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil()
+}
+
+// isEmpty — «пустое» значение для required и omitempty: нулевое, а для
+// срезов и map — без элементов.
+func isEmpty(rv reflect.Value) bool {
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
@@ -0,0 +1,56 @@
+package validate
+
+import "testing"
+
+type benchAddress struct {
+	City string `json:"city" validate:"required,max=64"`
+	Zip  string `json:"zip" validate:"regexp=[0-9]{6}"`
+}
+
+type benchSignup struct {
+	Name     string            `json:"name" validate:"required,min=2,max=64"`
+	Email    string            `json:"email" validate:"required,email"`
+	Role     string            `json:"role" validate:"oneof=user admin"`
+	Password string            `json:"password" validate:"required,min=8"`
+	Confirm  string            `json:"confirm" validate:"eqfield=Password"`
+	Tags     []string          `json:"tags" validate:"max=10,dive,required,max=32"`
+	Address  *benchAddress     `json:"address" validate:"required"`
+	Labels   map[string]string `json:"labels" validate:"dive,max=64"`
+}
+
+var benchValue = &benchSignup{
+	Name:     "Alice",
+	Email:    "alice@example.com",
+	Role:     "admin",
+	Password: "correct horse",
+	Confirm:  "correct horse",
+	Tags:     []string{"go", "reflection"},
+	Address:  &benchAddress{City: "Moscow", Zip: "101000"},
+	Labels:   map[string]string{"team": "core"},
+}
+
+// BenchmarkStruct сравнивает проверку с кэшем правил и без него: uncached
+// сбрасывает кэши перед каждой проверкой, как было до их появления.
+// Кэш structinfo общий для пакетов и остаётся, так что разница занижена.
+func BenchmarkStruct(b *testing.B) {
+	v := New()
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v.types.Clear()
+			regexps.Clear()
+			if err := v.Struct(benchValue); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := v.Struct(benchValue); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
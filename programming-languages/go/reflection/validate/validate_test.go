@@ -0,0 +1,209 @@
+package validate
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// failures — нарушения из err в виде "Path rule".
+func failures(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("not ValidationErrors: %v", err)
+	}
+	out := make([]string, len(errs))
+	for i, fe := range errs {
+		out[i] = fe.Path + " " + fe.Rule
+	}
+	return out
+}
+
+type address struct {
+	City string `validate:"required"`
+}
+
+type base struct {
+	ID int `validate:"min=1"`
+}
+
+type profile struct {
+	base
+	Name    string            `validate:"required,max=5"`
+	Home    address           // вложенная структура проверяется без тега
+	Work    *address          `validate:"required"`
+	Tags    []string          `validate:"max=2,dive,required"`
+	Scores  [2]int            `validate:"dive,max=10"`
+	Labels  map[string]string `validate:"dive,max=3"`
+	Mirrors []*address        `validate:"dive"`
+	secret  string            `validate:"required"` // неэкспортированные поля не проверяются
+}
+
+func validProfile() *profile {
+	return &profile{
+		base:   base{ID: 1},
+		Name:   "ann",
+		Home:   address{City: "Kazan"},
+		Work:   &address{City: "Kazan"},
+		Tags:   []string{"go"},
+		Labels: map[string]string{"k": "v"},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(p *profile)
+		want   []string
+	}{
+		{"valid", func(p *profile) {}, nil},
+		{"rules in order", func(p *profile) { p.Name = "" }, []string{"profile.Name required"}},
+		{"nested struct", func(p *profile) { p.Home.City = "" }, []string{"profile.Home.City required"}},
+		{"nil pointer", func(p *profile) { p.Work = nil }, []string{"profile.Work required"}},
+		{"pointer is followed", func(p *profile) { p.Work.City = "" }, []string{
+			"profile.Work required", "profile.Work.City required",
+		}},
+		{"unexported embedded struct", func(p *profile) { p.ID = 0 }, []string{"profile.base.ID min"}},
+		{"rules before dive", func(p *profile) { p.Tags = []string{"a", "b", "c"} }, []string{"profile.Tags max"}},
+		{"dive into slice", func(p *profile) { p.Tags = []string{"go", ""} }, []string{"profile.Tags[1] required"}},
+		{"dive into array", func(p *profile) { p.Scores[1] = 11 }, []string{"profile.Scores[1] max"}},
+		{"dive into map", func(p *profile) { p.Labels["k"] = "long" }, []string{"profile.Labels[k] max"}},
+		{"dive into structs", func(p *profile) { p.Mirrors = []*address{nil, {}} }, []string{"profile.Mirrors[1].City required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := validProfile()
+			tt.mutate(p)
+			if got := failures(t, New().Struct(p)); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	v := New()
+
+	type unknown struct {
+		A string `validate:"required,nosuchrule"`
+	}
+	if err := v.Struct(unknown{}); !errors.Is(err, ErrUnknownRule) || !strings.Contains(err.Error(), `"nosuchrule"`) {
+		t.Errorf("unknown rule: err = %v", err)
+	}
+
+	type badDive struct {
+		A string `validate:"dive"`
+	}
+	if err := v.Struct(badDive{}); !errors.Is(err, ErrBadRule) {
+		t.Errorf("dive on string: err = %v", err)
+	}
+
+	type badParam struct {
+		A string `validate:"max=many"`
+	}
+	if err := v.Struct(badParam{}); !errors.Is(err, ErrBadRule) {
+		t.Errorf("bad parameter: err = %v", err)
+	}
+
+	if err := v.Struct((*profile)(nil)); !errors.Is(err, ErrBadRule) {
+		t.Errorf("nil pointer: err = %v", err)
+	}
+	if err := v.Struct(42); !errors.Is(err, ErrBadRule) {
+		t.Errorf("not a struct: err = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	type code struct {
+		A string `validate:"even"`
+		B string `validate:"required"`
+	}
+
+	v := New()
+	if err := v.Struct(code{}); !errors.Is(err, ErrUnknownRule) {
+		t.Fatalf("before Register: err = %v", err)
+	}
+
+	v.Register("even", func(f Field) (bool, error) { return f.Value.Len()%2 == 0, nil })
+	// подмена встроенного правила сбрасывает уже разобранные типы.
+	v.Register("required", func(f Field) (bool, error) { return f.Value.String() == "yes", nil })
+
+	got := failures(t, v.Struct(code{A: "abc", B: "no"}))
+	if want := []string{"code.A even", "code.B required"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// у другого валидатора свой реестр.
+	if err := New().Struct(code{}); !errors.Is(err, ErrUnknownRule) {
+		t.Errorf("registry leaked into New(): err = %v", err)
+	}
+
+	for _, name := range []string{"", "dive", "omitempty", "a,b", "a=b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			v.Register(name, required)
+		}()
+	}
+}
+
+type node struct {
+	Name string `validate:"required"`
+	Next *node
+}
+
+func TestCycle(t *testing.T) {
+	a := &node{Name: "a"}
+	b := &node{Next: a}
+	a.Next = b
+
+	// a → b → a: второй раз a уже на пути и не обходится.
+	got := failures(t, New().Struct(a))
+	if want := []string{"node.Next.Name required"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+type holder struct {
+	L   address
+	Ref *address
+}
+
+type outer struct {
+	H *holder
+}
+
+func TestCycleSameAddress(t *testing.T) {
+	// &h и &h.L — один адрес, но разные значения: Ref не цикл.
+	h := &holder{}
+	h.Ref = &h.L
+	got := failures(t, New().Struct(outer{H: h}))
+	want := []string{"outer.H.L.City required", "outer.H.Ref.City required"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnexportedEmbeddedStructLevel(t *testing.T) {
+	v := New()
+	v.RegisterStruct(base{}, func(sl StructLevel) {
+		// неэкспортированное поле: reflect без interfaceable запретил бы Interface.
+		if sl.Value.Interface().(base).ID > 10 {
+			sl.Report("ID", "small", "")
+		}
+	})
+	// и по значению, и по указателю.
+	for _, p := range []any{profile{base: base{ID: 11}}, &profile{base: base{ID: 11}}} {
+		got := failures(t, v.Struct(p))
+		if !slices.Contains(got, "profile.base.ID small") {
+			t.Errorf("%T: got %q", p, got)
+		}
+	}
+}